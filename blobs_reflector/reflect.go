@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"log"
 	"os"
-	"os/user"
 	"path/filepath"
+	"sync"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
 	"github.com/lbryio/reflector.go/cmd"
@@ -18,13 +18,14 @@ import (
 )
 
 var dbHandle *db.SQL
+var dbHandleMux sync.Mutex
 
-func ReflectAndClean() error {
-	err := reflectBlobs()
+func ReflectAndClean(instance util.Instance) error {
+	err := reflectBlobs(instance)
 	if err != nil {
 		return err
 	}
-	return util.CleanupLbrynet()
+	return instance.CleanupLbrynet()
 }
 
 func loadConfig(path string) (cmd.Config, error) {
@@ -42,13 +43,13 @@ func loadConfig(path string) (cmd.Config, error) {
 	return c, err
 }
 
-func reflectBlobs() error {
+func reflectBlobs(instance util.Instance) error {
 	if util.IsBlobReflectionOff() {
 		return nil
 	}
 	logrus.Infoln("reflecting blobs...")
 	//make sure lbrynet is off
-	running, err := util.IsLbrynetRunning()
+	running, err := instance.IsLbrynetRunning()
 	if err != nil {
 		return err
 	}
@@ -66,23 +67,22 @@ func reflectBlobs() error {
 	if err != nil {
 		return errors.Err(err)
 	}
+	dbHandleMux.Lock()
 	if dbHandle == nil {
 		dbHandle = new(db.SQL)
 		err = dbHandle.Connect(config.DBConn)
 		if err != nil {
+			dbHandle = nil
+			dbHandleMux.Unlock()
 			return errors.Err(err)
 		}
 	}
+	dbHandleMux.Unlock()
 	st := store.NewDBBackedStore(store.NewS3Store(config.AwsID, config.AwsSecret, config.BucketRegion, config.BucketName), dbHandle, false)
 
 	uploadWorkers := 10
 	uploader := reflector.NewUploader(dbHandle, st, uploadWorkers, false, false)
-	usr, err := user.Current()
-	if err != nil {
-		return errors.Err(err)
-	}
-	blobsDir := usr.HomeDir + "/.lbrynet/blobfiles/"
-	err = uploader.Upload(blobsDir)
+	err = uploader.Upload(instance.GetBlobsDir())
 	if err != nil {
 		return errors.Err(err)
 	}
@@ -24,16 +24,112 @@ type S3Configs struct {
 	Endpoint string `json:"endpoint"`
 }
 type Configs struct {
-	SlackToken            string    `json:"slack_token"`
-	SlackChannel          string    `json:"slack_channel"`
-	InternalApisEndpoint  string    `json:"internal_apis_endpoint"`
-	InternalApisAuthToken string    `json:"internal_apis_auth_token"`
-	LbrycrdString         string    `json:"lbrycrd_string"`
-	LoggingEndpoint       string    `json:"logging_endpoint"`
-	UseVpn                bool      `json:"use_vpn"`
-	WalletS3Config        S3Configs `json:"wallet_s3_config"`
-	BlockchaindbS3Config  S3Configs `json:"blockchaindb_s3_config"`
-	ThumbnailsS3Config    S3Configs `json:"thumbnails_s3_config"`
+	SlackToken              string              `json:"slack_token"`
+	SlackChannel            string              `json:"slack_channel"`
+	InternalApisEndpoint    string              `json:"internal_apis_endpoint"`
+	InternalApisAuthToken   string              `json:"internal_apis_auth_token"`
+	LbrycrdString           string              `json:"lbrycrd_string"`
+	LoggingEndpoint         string              `json:"logging_endpoint"`
+	UseVpn                  bool                `json:"use_vpn"`
+	WalletS3Config          S3Configs           `json:"wallet_s3_config"`
+	BlockchaindbS3Config    S3Configs           `json:"blockchaindb_s3_config"`
+	ThumbnailsS3Config      S3Configs           `json:"thumbnails_s3_config"`
+	ErrorRulesPath          string              `json:"error_rules_path"`
+	LocalJobStorePath       string              `json:"local_job_store_path"`
+	APIRetry                APIRetryConfig      `json:"api_retry"`
+	StatusOutboxPath        string              `json:"status_outbox_path"`
+	AdminToken              string              `json:"admin_token"`
+	PlaylistCollectionsPath string              `json:"playlist_collections_path"`
+	NewUploadsFeed          FeedConfig          `json:"new_uploads_feed"`
+	MetadataCache           MetadataCacheConfig `json:"metadata_cache"`
+	DeferredVideos          DeferredConfig      `json:"deferred_videos"`
+	Cookies                 CookiesConfig       `json:"cookies"`
+	// ChannelMetadataProviders are tried in order to get the metadata of youtube channels: "scraper" and "yt-dlp"
+	ChannelMetadataProviders []string `json:"channel_metadata_providers"`
+	// TranscodeProfiles are the ffmpeg settings channels can pick with their transcode_profile, keyed by name
+	TranscodeProfiles map[string]TranscodeProfileConfig `json:"transcode_profiles"`
+	ThumbnailStore    ThumbnailStoreConfig              `json:"thumbnail_store"`
+}
+
+// ThumbnailStoreConfig controls where the thumbnails are mirrored and the URL they are published with
+type ThumbnailStoreConfig struct {
+	// Type is "s3" (the default, using thumbnails_s3_config), "local" or "http"
+	Type string `json:"type"`
+	// PublicURL is the base URL the thumbnails are served from, the name of the thumbnail is appended to it
+	PublicURL    string `json:"public_url"`
+	CacheControl string `json:"cache_control"`
+	// Dir is where the local store writes the thumbnails
+	Dir string `json:"dir"`
+	// UploadURL is the base URL the http store PUTs the thumbnails to, with Headers (e.g. Authorization)
+	UploadURL string            `json:"upload_url"`
+	Headers   map[string]string `json:"headers"`
+	// LegacyHosts are the base URLs of stores used before, whose thumbnails still identify the claims published by ytsync
+	LegacyHosts []string `json:"legacy_hosts"`
+}
+
+// TranscodeProfileConfig controls how downloaded videos are transcoded to H.264/AAC mp4. Bitrates use the ffmpeg
+// format (e.g. "2500k", "3M")
+type TranscodeProfileConfig struct {
+	// MaxHeight scales down taller videos, 0 keeps the resolution
+	MaxHeight    int    `json:"max_height"`
+	VideoBitrate string `json:"video_bitrate"`
+	AudioBitrate string `json:"audio_bitrate"`
+	CRF          int    `json:"crf"`
+	Preset       string `json:"preset"`
+	// Threads limits the CPU threads used by ffmpeg, 0 lets ffmpeg decide
+	Threads int `json:"threads"`
+	// Always transcodes the videos that are already H.264/AAC within the caps
+	Always bool `json:"always"`
+}
+
+// CookiesConfig lists the cookie profiles handed to yt-dlp. Without profiles, cookies.txt is used for everything
+type CookiesConfig struct {
+	Profiles []CookieProfileConfig `json:"profiles"`
+	// ProbeURL is fetched with every profile on startup to check that its session is still valid
+	ProbeURL string `json:"probe_url"`
+	// AgeProbeURL is an age restricted video fetched with the age verified profiles on startup
+	AgeProbeURL string `json:"age_probe_url"`
+}
+
+// CookieProfileConfig is a cookies.txt exported from the browser session of a youtube account
+type CookieProfileConfig struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	BackupPath  string `json:"backup_path"`
+	AgeVerified bool   `json:"age_verified"`
+}
+
+// DeferredConfig controls when the videos that aren't ready yet (premieres, lives) are queued again. Durations use the
+// time.ParseDuration format (e.g. "1h")
+type DeferredConfig struct {
+	StatePath      string `json:"state_path"`
+	InitialBackoff string `json:"initial_backoff"`
+	MaxBackoff     string `json:"max_backoff"`
+}
+
+// MetadataCacheConfig controls the cache of video metadata. Durations use the time.ParseDuration format (e.g. "5h")
+type MetadataCacheConfig struct {
+	Dir            string `json:"dir"`
+	TTL            string `json:"ttl"`
+	UnavailableTTL string `json:"unavailable_ttl"`
+	MaxSizeMB      int    `json:"max_size_mb"`
+}
+
+// FeedConfig controls the detection of new uploads from the channel feeds. Durations use the time.ParseDuration format (e.g. "24h")
+type FeedConfig struct {
+	Disabled         bool   `json:"disabled"`
+	FullScanInterval string `json:"full_scan_interval"`
+	StatePath        string `json:"state_path"`
+}
+
+// APIRetryConfig overrides the default retry policy of the internal-apis client. Durations use the time.ParseDuration format (e.g. "30s")
+type APIRetryConfig struct {
+	InitialBackoff string  `json:"initial_backoff"`
+	MaxBackoff     string  `json:"max_backoff"`
+	Multiplier     float64 `json:"multiplier"`
+	Jitter         float64 `json:"jitter"`
+	MaxAttempts    int     `json:"max_attempts"`
+	MaxElapsed     string  `json:"max_elapsed"`
 }
 
 var Configuration *Configs
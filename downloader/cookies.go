@@ -0,0 +1,283 @@
+package downloader
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/lbryio/ytsync/v5/configs"
+	"github.com/lbryio/ytsync/v5/ip_manager"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ageConfirmationMsg = "Sign in to confirm your age"
+	invalidCookiesMsg  = "cookies are no longer valid"
+	defaultProbeURL    = "https://www.youtube.com/watch?v=jNQXAC9IVRw"
+)
+
+// ErrNoCookieProfile is returned when every cookie profile expired, or none can see age restricted videos in an
+// age restricted run
+var ErrNoCookieProfile = errors.Base("no usable cookie profile left")
+
+// CookieProfile is a cookies.txt exported from the browser session of a youtube account
+type CookieProfile struct {
+	Name string
+	Path string
+	// BackupPath is copied over Path when Path was emptied, it is optional
+	BackupPath string
+	// AgeVerified profiles belong to accounts that can watch age restricted videos
+	AgeVerified bool
+}
+
+// Args returns the yt-dlp arguments loading the profile
+func (p *CookieProfile) Args() []string {
+	return []string{"--cookies", p.Path}
+}
+
+type cookieProfileState struct {
+	profile CookieProfile
+	expired bool
+}
+
+// CookieJar hands out cookie profiles to yt-dlp calls. Each source IP sticks to one profile, and the IPs are spread
+// over the profiles, so that youtube doesn't see an account coming from every IP of the pool
+type CookieJar struct {
+	mux           sync.Mutex
+	profiles      []*cookieProfileState
+	assignments   map[string]*cookieProfileState
+	ageRestricted bool
+	probeURL      string
+	ageProbeURL   string
+}
+
+// NewCookieJar returns a jar handing out the given profiles
+func NewCookieJar(profiles []CookieProfile) *CookieJar {
+	j := &CookieJar{
+		assignments: make(map[string]*cookieProfileState),
+		probeURL:    defaultProbeURL,
+	}
+	for _, p := range profiles {
+		j.profiles = append(j.profiles, &cookieProfileState{profile: p})
+	}
+	return j
+}
+
+// CookieJarFromConfig returns the jar configured in cookies. Without profiles, cookies.txt is used with
+// cookies-backup.txt as its backup
+func CookieJarFromConfig(c configs.CookiesConfig) (*CookieJar, error) {
+	if len(c.Profiles) == 0 {
+		j := defaultCookieJar()
+		j.ageProbeURL = c.AgeProbeURL
+		if c.ProbeURL != "" {
+			j.probeURL = c.ProbeURL
+		}
+		return j, nil
+	}
+	profiles := make([]CookieProfile, 0, len(c.Profiles))
+	names := make(map[string]bool, len(c.Profiles))
+	for i, p := range c.Profiles {
+		if p.Path == "" {
+			return nil, errors.Err("cookie profile %d has no path", i)
+		}
+		name := p.Name
+		if name == "" {
+			name = p.Path
+		}
+		if names[name] {
+			return nil, errors.Err("cookie profile %s is configured twice", name)
+		}
+		names[name] = true
+		profiles = append(profiles, CookieProfile{Name: name, Path: p.Path, BackupPath: p.BackupPath, AgeVerified: p.AgeVerified})
+	}
+	j := NewCookieJar(profiles)
+	j.ageProbeURL = c.AgeProbeURL
+	if c.ProbeURL != "" {
+		j.probeURL = c.ProbeURL
+	}
+	return j, nil
+}
+
+func defaultCookieJar() *CookieJar {
+	return NewCookieJar([]CookieProfile{{Name: "default", Path: "cookies.txt", BackupPath: "cookies-backup.txt"}})
+}
+
+var cookieJar = defaultCookieJar()
+
+// SetCookieJar replaces the jar used for every yt-dlp call
+func SetCookieJar(jar *CookieJar) {
+	cookieJar = jar
+}
+
+// GetCookieJar returns the jar used for every yt-dlp call
+func GetCookieJar() *CookieJar {
+	return cookieJar
+}
+
+// SetAgeRestricted restricts the jar to the age verified profiles, for the runs syncing age restricted videos
+func (j *CookieJar) SetAgeRestricted(ageRestricted bool) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.ageRestricted = ageRestricted
+}
+
+// Pick returns the profile to use from sourceAddress: the one already used from that address while it's valid,
+// otherwise the valid profile used from the fewest addresses
+func (j *CookieJar) Pick(sourceAddress string) (*CookieProfile, error) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	for {
+		state := j.assignments[sourceAddress]
+		if state == nil || !j.usable(state) {
+			state = j.leastUsed()
+			if state == nil {
+				return nil, errors.Err(ErrNoCookieProfile)
+			}
+		}
+		err := restoreCookies(&state.profile)
+		if err != nil {
+			logrus.Errorf("cookie profile %s is unusable: %s", state.profile.Name, err.Error())
+			state.expired = true
+			continue
+		}
+		j.assignments[sourceAddress] = state
+		profile := state.profile
+		return &profile, nil
+	}
+}
+
+func (j *CookieJar) usable(state *cookieProfileState) bool {
+	return !state.expired && (!j.ageRestricted || state.profile.AgeVerified)
+}
+
+func (j *CookieJar) leastUsed() *cookieProfileState {
+	uses := make(map[*cookieProfileState]int, len(j.profiles))
+	for _, state := range j.assignments {
+		uses[state]++
+	}
+	var best *cookieProfileState
+	for _, state := range j.profiles {
+		if !j.usable(state) {
+			continue
+		}
+		if best == nil || uses[state] < uses[best] {
+			best = state
+		}
+	}
+	return best
+}
+
+// MarkExpired stops handing out the profile until the next run
+func (j *CookieJar) MarkExpired(name string, reason string) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	for _, state := range j.profiles {
+		if state.profile.Name == name && !state.expired {
+			state.expired = true
+			logrus.Errorf("cookie profile %s expired: %s", name, reason)
+		}
+	}
+}
+
+// ReportOutput looks for signs of an expired session in the output of a yt-dlp call made with the profile. An age
+// verified profile asked to confirm its age isn't signed in anymore. It reports whether the profile was marked as expired
+func (j *CookieJar) ReportOutput(profile *CookieProfile, output string) bool {
+	if profile == nil {
+		return false
+	}
+	if strings.Contains(output, invalidCookiesMsg) || (profile.AgeVerified && strings.Contains(output, ageConfirmationMsg)) {
+		j.MarkExpired(profile.Name, output)
+		return true
+	}
+	return false
+}
+
+// Validate fetches the probe URL with every profile, and the age probe URL with the age verified ones, to expire
+// the profiles whose session isn't valid anymore. Each probe goes through an IP of the pool that sticks to the profile
+func (j *CookieJar) Validate(stopChan stop.Chan, pool *ip_manager.IPPool) error {
+	j.mux.Lock()
+	profiles := make([]*cookieProfileState, len(j.profiles))
+	copy(profiles, j.profiles)
+	j.mux.Unlock()
+	for _, state := range profiles {
+		profile := state.profile
+		err := restoreCookies(&profile)
+		if err != nil {
+			j.MarkExpired(profile.Name, err.Error())
+			continue
+		}
+		urls := []string{j.probeURL}
+		if profile.AgeVerified && j.ageProbeURL != "" {
+			urls = append(urls, j.ageProbeURL)
+		}
+		for _, url := range urls {
+			output, err := j.probe(profile, url, stopChan, pool)
+			if err != nil {
+				return err
+			}
+			if j.ReportOutput(&profile, output) {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (j *CookieJar) probe(profile CookieProfile, url string, stopChan stop.Chan, pool *ip_manager.IPPool) (string, error) {
+	sourceAddress, err := getIPFromPool(profile.Name, stopChan, pool)
+	if err != nil {
+		return "", err
+	}
+	defer pool.ReleaseIP(sourceAddress)
+	j.mux.Lock()
+	if _, ok := j.assignments[sourceAddress]; !ok {
+		for _, state := range j.profiles {
+			if state.profile.Name == profile.Name {
+				j.assignments[sourceAddress] = state
+			}
+		}
+	}
+	j.mux.Unlock()
+	args := append([]string{"--skip-download", "--print", "%(id)s", "--source-address", sourceAddress, url}, profile.Args()...)
+	process, err := GetYtDlpRunner().Start(args)
+	if err != nil {
+		return "", err
+	}
+	errorLogCh := make(chan []byte, 1)
+	go func() {
+		errorLog, _ := io.ReadAll(process.Stderr())
+		errorLogCh <- errorLog
+	}()
+	_, _ = io.Copy(io.Discard, process.Stdout())
+	errorLog := <-errorLogCh
+	err = process.Wait()
+	if err != nil {
+		logrus.Warnf("probe of cookie profile %s failed: %s", profile.Name, string(errorLog))
+	}
+	return string(errorLog), nil
+}
+
+// restoreCookies copies the backup of the profile over its cookies when they were emptied, which yt-dlp sometimes does
+func restoreCookies(profile *CookieProfile) error {
+	fi, err := os.Stat(profile.Path)
+	if err != nil {
+		return errors.Err(err)
+	}
+	if fi.Size() > 0 {
+		return nil
+	}
+	if profile.BackupPath == "" {
+		return errors.Err("cookies of %s were cleared out and there is no backup", profile.Name)
+	}
+	logrus.Errorf("cookies of %s were cleared out. Attempting a restore from %s", profile.Name, profile.BackupPath)
+	input, err := os.ReadFile(profile.BackupPath)
+	if err != nil {
+		return errors.Err(err)
+	}
+	return errors.Err(os.WriteFile(profile.Path, input, 0644))
+}
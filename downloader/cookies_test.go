@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/ytsync/v5/configs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCookies(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestCookieJarRotation(t *testing.T) {
+	dir := t.TempDir()
+	jar, err := CookieJarFromConfig(configs.CookiesConfig{Profiles: []configs.CookieProfileConfig{
+		{Name: "a", Path: writeCookies(t, dir, "a.txt", "# a")},
+		{Name: "b", Path: writeCookies(t, dir, "b.txt", "# b")},
+		{Name: "adult", Path: writeCookies(t, dir, "adult.txt", "# adult"), AgeVerified: true},
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	first, err := jar.Pick("10.0.0.1")
+	assert.NoError(t, err)
+	second, err := jar.Pick("10.0.0.2")
+	assert.NoError(t, err)
+	third, err := jar.Pick("10.0.0.3")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "adult"}, []string{first.Name, second.Name, third.Name})
+	// an IP keeps its profile
+	again, err := jar.Pick("10.0.0.2")
+	assert.NoError(t, err)
+	assert.Equal(t, second.Name, again.Name)
+
+	// profiles that aren't age verified are expected to be asked to confirm their age
+	assert.False(t, jar.ReportOutput(first, "ERROR: [youtube] abc: Sign in to confirm your age"))
+	assert.True(t, jar.ReportOutput(&CookieProfile{Name: "adult", AgeVerified: true}, "ERROR: [youtube] abc: Sign in to confirm your age"))
+	assert.True(t, jar.ReportOutput(second, "WARNING: The provided YouTube account cookies are no longer valid"))
+	assert.False(t, jar.ReportOutput(nil, "cookies are no longer valid"))
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		p, err := jar.Pick(ip)
+		assert.NoError(t, err)
+		assert.Equal(t, first.Name, p.Name)
+	}
+
+	jar.SetAgeRestricted(true)
+	_, err = jar.Pick("10.0.0.1")
+	assert.True(t, errors.Is(err, ErrNoCookieProfile))
+}
+
+func TestCookieJarRestoresBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := writeCookies(t, dir, "cookies.txt", "")
+	backup := writeCookies(t, dir, "cookies-backup.txt", "# backup")
+	jar := NewCookieJar([]CookieProfile{
+		{Name: "empty", Path: writeCookies(t, dir, "empty.txt", "")},
+		{Name: "restored", Path: path, BackupPath: backup},
+	})
+	p, err := jar.Pick("10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "restored", p.Name)
+	assert.Equal(t, []string{"--cookies", path}, p.Args())
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "# backup", string(content))
+}
+
+func TestCookieJarFromConfig(t *testing.T) {
+	jar, err := CookieJarFromConfig(configs.CookiesConfig{})
+	if assert.NoError(t, err) && assert.Len(t, jar.profiles, 1) {
+		assert.Equal(t, "cookies.txt", jar.profiles[0].profile.Path)
+	}
+	_, err = CookieJarFromConfig(configs.CookiesConfig{Profiles: []configs.CookieProfileConfig{{Name: "a"}}})
+	assert.Error(t, err)
+	_, err = CookieJarFromConfig(configs.CookiesConfig{Profiles: []configs.CookieProfileConfig{{Name: "a", Path: "a.txt"}, {Name: "a", Path: "b.txt"}}})
+	assert.Error(t, err)
+}
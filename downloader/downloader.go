@@ -1,11 +1,8 @@
 package downloader
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
-	"os"
-	"os/exec"
 	"path"
 	"strings"
 	"time"
@@ -13,20 +10,102 @@ import (
 	"github.com/lbryio/ytsync/v5/downloader/ytdl"
 	"github.com/lbryio/ytsync/v5/ip_manager"
 	"github.com/lbryio/ytsync/v5/shared"
-	util2 "github.com/lbryio/ytsync/v5/util"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
 	"github.com/lbryio/lbry.go/v2/extras/stop"
-	"github.com/lbryio/lbry.go/v2/extras/util"
 
 	"github.com/sirupsen/logrus"
 )
 
+// PlaylistItem is an entry of a playlist listed by yt-dlp
+type PlaylistItem struct {
+	ID  string
+	URL string
+	// Extractor is the yt-dlp extractor of the item (Vimeo, PeerTube...), IDs are only unique within an extractor
+	Extractor string
+}
+
+// GetPlaylistItems lists the most recent entries of a playlist (or channel) on any site supported by yt-dlp
+func GetPlaylistItems(playlistURL string, maxVideos int, stopChan stop.Chan, pool *ip_manager.IPPool) ([]PlaylistItem, error) {
+	args := []string{"--skip-download", playlistURL, "--flat-playlist", "--print", "%(ie_key,extractor_key)s\t%(id)s\t%(webpage_url,url)s", "--playlist-end", fmt.Sprintf("%d", maxVideos)}
+	lines, err := run(playlistURL, args, stopChan, pool)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	return parsePlaylistItems(lines, maxVideos), nil
+}
+
+// Playlist is a playlist created by a youtube channel
+type Playlist struct {
+	ID    string
+	Title string
+}
+
+// GetChannelPlaylists lists the playlists of a youtube channel
+func GetChannelPlaylists(channelID string, stopChan stop.Chan, pool *ip_manager.IPPool) ([]Playlist, error) {
+	args := []string{"--skip-download", "https://www.youtube.com/channel/" + channelID + "/playlists", "--flat-playlist", "--print", "%(id)s\t%(title)s"}
+	lines, err := run(channelID, args, stopChan, pool)
+	if err != nil {
+		if strings.Contains(err.Error(), "This channel does not have a") {
+			return nil, nil
+		}
+		return nil, errors.Err(err)
+	}
+	return parsePlaylists(lines), nil
+}
+
+// GetChannelJSON returns the metadata yt-dlp has about a youtube channel (title, description, avatar, banner,
+// handle...) as JSON, without listing its videos
+func GetChannelJSON(channelID string, stopChan stop.Chan, pool *ip_manager.IPPool) ([]byte, error) {
+	args := []string{"--skip-download", "https://www.youtube.com/channel/" + channelID, "--flat-playlist", "--playlist-items", "0", "--dump-single-json"}
+	lines, err := run(channelID, args, stopChan, pool)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// PlaylistURL returns the URL of a youtube playlist
+func PlaylistURL(playlistID string) string {
+	return "https://www.youtube.com/playlist?list=" + playlistID
+}
+
+func parsePlaylists(lines []string) []Playlist {
+	var playlists []Playlist
+	for _, line := range lines {
+		parts := strings.SplitN(strings.TrimSpace(line), "\t", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[0] == "NA" {
+			continue
+		}
+		playlists = append(playlists, Playlist{ID: parts[0], Title: parts[1]})
+	}
+	return playlists
+}
+
+func parsePlaylistItems(lines []string, maxVideos int) []PlaylistItem {
+	var items []PlaylistItem
+	for _, line := range lines {
+		parts := strings.SplitN(strings.TrimSpace(line), "\t", 3)
+		if len(parts) != 3 || parts[1] == "" || parts[1] == "NA" || parts[2] == "NA" {
+			continue
+		}
+		if len(items) >= maxVideos {
+			break
+		}
+		extractor := parts[0]
+		if extractor == "NA" {
+			extractor = "Generic"
+		}
+		items = append(items, PlaylistItem{ID: parts[1], URL: parts[2], Extractor: extractor})
+	}
+	return items
+}
+
 func GetPlaylistVideoIDs(channelName string, maxVideos int, stopChan stop.Chan, pool *ip_manager.IPPool) ([]string, error) {
 	tabs := []string{"videos", "shorts", "streams"}
 	var videoIDs []string
 	for _, tab := range tabs {
-		args := []string{"--skip-download", "https://www.youtube.com/channel/" + channelName + "/" + tab, "--get-id", "--flat-playlist", "--cookies", "cookies.txt", "--playlist-end", fmt.Sprintf("%d", maxVideos)}
+		args := []string{"--skip-download", "https://www.youtube.com/channel/" + channelName + "/" + tab, "--get-id", "--flat-playlist", "--playlist-end", fmt.Sprintf("%d", maxVideos)}
 		ids, err := run(channelName, args, stopChan, pool)
 		if err != nil {
 			if strings.Contains(err.Error(), "This channel does not have a") {
@@ -52,38 +131,32 @@ func GetPlaylistVideoIDs(channelName string, maxVideos int, stopChan stop.Chan,
 
 const releaseTimeFormat = "2006-01-02, 15:04:05 (MST)"
 
-func GetVideoInformation(videoID string, stopChan stop.Chan, pool *ip_manager.IPPool) (*ytdl.YtdlVideo, error) {
+func GetVideoInformation(videoID string, cache *MetadataCache, stopChan stop.Chan, pool *ip_manager.IPPool) (*ytdl.YtdlVideo, error) {
+	return GetVideoInformationFromURL(videoID, fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID), cache, stopChan, pool)
+}
+
+// GetVideoInformationFromURL returns the metadata of the video at videoURL, from the cache when it's fresh enough
+func GetVideoInformationFromURL(videoID string, videoURL string, cache *MetadataCache, stopChan stop.Chan, pool *ip_manager.IPPool) (*ytdl.YtdlVideo, error) {
+	video, hit, err := cache.lookup(videoID, time.Now())
+	if hit {
+		return video, err
+	}
 	args := []string{
 		"--skip-download",
 		"--no-warnings",
 		"--write-info-json",
-		fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID),
-		"--cookies",
-		"cookies.txt",
+		videoURL,
 		"-o",
-		path.Join(util2.GetVideoMetadataDir(), videoID),
+		path.Join(cache.Dir(), videoID),
 	}
-	_, err := run(videoID, args, stopChan, pool)
+	_, err = run(videoID, args, stopChan, pool)
 	if err != nil {
-		return nil, errors.Err(err)
+		err = errors.Err(err)
+		cache.storeUnavailable(videoID, err)
+		return nil, err
 	}
-
-	f, err := os.Open(path.Join(util2.GetVideoMetadataDir(), videoID+".info.json"))
-	if err != nil {
-		return nil, errors.Err(err)
-	}
-	// defer the closing of our jsonFile so that we can parse it later on
-	defer f.Close()
-	// read our opened jsonFile as a byte array.
-	byteValue, _ := io.ReadAll(f)
-
-	var video *ytdl.YtdlVideo
-	err = json.Unmarshal(byteValue, &video)
-	if err != nil {
-		return nil, errors.Err(err)
-	}
-
-	return video, nil
+	cache.stored(videoID)
+	return parseInfoJSON(cache.infoPath(videoID))
 }
 
 const (
@@ -108,17 +181,25 @@ func run(use string, args []string, stopChan stop.Chan, pool *ip_manager.IPPool)
 		}
 		argsForCommand := append(args, "--source-address", sourceAddress)
 		argsForCommand = append(argsForCommand, useragent...)
-		binary := "yt-dlp"
-		cmd := exec.Command(binary, argsForCommand...)
+		// listings and metadata mostly work without cookies, only age restricted videos need them
+		cookies, err := GetCookieJar().Pick(sourceAddress)
+		if err != nil {
+			logrus.Warnf("running yt-dlp without cookies: %s", err.Error())
+		} else {
+			argsForCommand = append(argsForCommand, cookies.Args()...)
+		}
 
-		res, err := runCmd(cmd, stopChan)
+		res, err := runCmd(argsForCommand, stopChan)
 		pool.ReleaseIP(sourceAddress)
 		if err == nil {
 			return res, nil
 		}
-		lastError = err
+		lastError = shared.Classify(err)
+		if GetCookieJar().ReportOutput(cookies, err.Error()) {
+			continue
+		}
 		if strings.Contains(err.Error(), youtubeDlError) {
-			if util.SubstringInSlice(err.Error(), shared.ErrorsNoRetry) {
+			if !shared.IsRetryable(lastError) {
 				break
 			}
 			if strings.Contains(err.Error(), extractionError) {
@@ -142,41 +223,32 @@ func nextUA(current []string) []string {
 	return []string{"--user-agent", ChromeUA}
 }
 
-func runCmd(cmd *exec.Cmd, stopChan stop.Chan) ([]string, error) {
-	logrus.Infof("running yt-dlp cmd: %s", strings.Join(cmd.Args, " "))
-	var err error
-	stderr, err := cmd.StderrPipe()
+func runCmd(args []string, stopChan stop.Chan) ([]string, error) {
+	logrus.Infof("running yt-dlp cmd: yt-dlp %s", strings.Join(args, " "))
+	process, err := GetYtDlpRunner().Start(args)
 	if err != nil {
-		return nil, errors.Err(err)
-	}
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, errors.Err(err)
-	}
-	err = cmd.Start()
-	if err != nil {
-		return nil, errors.Err(err)
+		return nil, err
 	}
-	outLog, err := io.ReadAll(stdout)
+	outLog, err := io.ReadAll(process.Stdout())
 	if err != nil {
 		return nil, errors.Err(err)
 	}
-	errorLog, err := io.ReadAll(stderr)
+	errorLog, err := io.ReadAll(process.Stderr())
 	if err != nil {
 		return nil, errors.Err(err)
 	}
 	done := make(chan error, 1)
 	go func() {
-		done <- cmd.Wait()
+		done <- process.Wait()
 	}()
 
 	select {
 	case <-stopChan:
-		err := cmd.Process.Kill()
+		err := process.Kill()
 		if err != nil {
 			return nil, errors.Prefix("failed to kill command after stopper cancellation", err)
 		}
-		return nil, errors.Err("interrupted by user")
+		return nil, errors.Err(ip_manager.ErrInterruptedByUser)
 	case err := <-done:
 		if err != nil {
 			//return nil, errors.Prefix("yt-dlp "+strings.Join(cmd.Args, " ")+" ["+string(errorLog)+"]", err)
@@ -193,7 +265,7 @@ func getIPFromPool(use string, stopChan stop.Chan, pool *ip_manager.IPPool) (sou
 			if errors.Is(err, ip_manager.ErrAllThrottled) {
 				select {
 				case <-stopChan:
-					return "", errors.Err("interrupted by user")
+					return "", errors.Err(ip_manager.ErrInterruptedByUser)
 
 				default:
 					time.Sleep(ip_manager.IPCooldownPeriod)
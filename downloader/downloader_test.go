@@ -1,40 +1,144 @@
 package downloader
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/lbryio/ytsync/v5/configs"
 	"github.com/lbryio/ytsync/v5/ip_manager"
+	"github.com/lbryio/ytsync/v5/sdk"
+	"github.com/lbryio/ytsync/v5/shared"
 
+	"github.com/lbryio/lbry.go/v2/extras/errors"
 	"github.com/lbryio/lbry.go/v2/extras/stop"
-	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
+// replayYtDlp makes yt-dlp calls replay the fixtures recorded in testdata/ytdlp until the test ends
+func replayYtDlp(t *testing.T, fixtures ...string) *FixtureRunner {
+	paths := make([]string, 0, len(fixtures))
+	for _, f := range fixtures {
+		paths = append(paths, filepath.Join("testdata", "ytdlp", f+".json"))
+	}
+	runner, err := NewFixtureRunnerFromFiles(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := GetYtDlpRunner()
+	SetYtDlpRunner(runner)
+	t.Cleanup(func() { SetYtDlpRunner(previous) })
+	return runner
+}
+
 func TestGetPlaylistVideoIDs(t *testing.T) {
+	runner := replayYtDlp(t, "channel_videos", "channel_shorts", "channel_streams")
 	stopGrp := stop.New()
 	ip, err := ip_manager.GetIPPool(stopGrp)
 	if !assert.NoError(t, err) {
 		return
 	}
-	videoIDs, err := GetPlaylistVideoIDs("UCJ0-OtVpF0wOKEqT2Z1HEtA", 50, stopGrp.Ch(), ip)
+	videoIDs, err := GetPlaylistVideoIDs("UCJ0-OtVpF0wOKEqT2Z1HEtA", 2, stopGrp.Ch(), ip)
 	if !assert.NoError(t, err) {
 		return
 	}
-	for _, id := range videoIDs {
-		println(id)
-	}
+	// the channel has no shorts tab, and each tab is cut at the limit
+	assert.Equal(t, []string{"dQw4w9WgXcQ", "2AdVR5wCqVU", "x8VYWazR5mE"}, videoIDs)
+	assert.Len(t, runner.Runs, 3)
 }
 
 func TestGetVideoInformation(t *testing.T) {
+	replayYtDlp(t, "video_info")
+	previous := configs.Configuration
+	configs.Configuration = &configs.Configs{LocalJobStorePath: t.TempDir()}
+	defer func() { configs.Configuration = previous }()
+	// the release date is looked up in the job store, the local one doesn't need the network
+	assert.NoError(t, sdk.InitJobStore())
 	s := stop.New()
 	ip, err := ip_manager.GetIPPool(s)
 	assert.NoError(t, err)
-	video, err := GetVideoInformation("2AdVR5wCqVU", s.Ch(), ip)
+	cache, err := NewMetadataCache(t.TempDir(), time.Hour, time.Hour, 1024*1024, false)
+	assert.NoError(t, err)
+	video, err := GetVideoInformation("2AdVR5wCqVU", cache, s.Ch(), ip)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "2AdVR5wCqVU", video.ID)
+	assert.Equal(t, 212, video.Duration)
+	assert.True(t, video.IsYoutube())
+	assert.Equal(t, time.Date(2015, 6, 20, 0, 0, 0, 0, time.UTC), video.GetUploadTime())
+
+	// the metadata is cached, yt-dlp isn't run again
+	SetYtDlpRunner(NewFixtureRunner())
+	_, err = GetVideoInformation("2AdVR5wCqVU", cache, s.Ch(), ip)
 	assert.NoError(t, err)
-	assert.NotNil(t, video)
-	logrus.Info(video.ID)
-	assert.NoError(t, configs.Init("./config.json"))
-	time := video.GetUploadTime()
-	assert.NotNil(t, time)
+}
+
+func TestParsePlaylistItems(t *testing.T) {
+	lines := []string{
+		"Vimeo\tabc123\thttps://vimeo.com/abc123",
+		"Vimeo\tNA\thttps://vimeo.com/unknown",
+		"Vimeo\tdef456\tNA",
+		"",
+		"NA\tghi789\thttps://example.com/ghi789",
+		"Vimeo\tjkl012\thttps://vimeo.com/jkl012",
+	}
+	items := parsePlaylistItems(lines, 2)
+	assert.Equal(t, []PlaylistItem{
+		{ID: "abc123", URL: "https://vimeo.com/abc123", Extractor: "Vimeo"},
+		{ID: "ghi789", URL: "https://example.com/ghi789", Extractor: "Generic"},
+	}, items)
+}
+
+func TestParsePlaylists(t *testing.T) {
+	playlists := parsePlaylists([]string{
+		"PL1\tMy first playlist",
+		"NA\tbroken",
+		"PL2\tTabs\tin the title",
+	})
+	assert.Equal(t, []Playlist{
+		{ID: "PL1", Title: "My first playlist"},
+		{ID: "PL2", Title: "Tabs\tin the title"},
+	}, playlists)
+}
+
+func TestParseFeed(t *testing.T) {
+	data, err := os.ReadFile("testdata/feed.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	entries, err := parseFeed(data)
+	assert.NoError(t, err)
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+	// sorted from the most recent
+	assert.Equal(t, "x8VYWazR5mE", entries[0].VideoID)
+	assert.Equal(t, "4mLhTb9AzZo", entries[1].VideoID)
+	assert.Equal(t, "Building a workshop, part 2", entries[1].Title)
+	assert.Equal(t, time.Date(2024, 5, 12, 15, 0, 8, 0, time.UTC), entries[1].Published.UTC())
+	assert.Equal(t, "kJQP7kiw5Fk", entries[2].VideoID)
+
+	data, err = os.ReadFile("testdata/feed_empty.xml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	entries, err = parseFeed(data)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = parseFeed([]byte("<html><body>Our systems have detected unusual traffic"))
+	assert.Error(t, err)
+}
+
+func TestFetchFeedThrottled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	_, err := fetchFeed(server.URL, "127.0.0.1")
+	assert.True(t, errors.Is(err, shared.ErrThrottled))
 }
@@ -0,0 +1,117 @@
+package downloader
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/ip_manager"
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+)
+
+const (
+	channelFeedURL = "https://www.youtube.com/feeds/videos.xml?channel_id="
+	maxFeedSize    = 5 * 1024 * 1024
+)
+
+// errFeedThrottled is returned when youtube rate limits the IP the feed is requested from
+var errFeedThrottled = shared.NewClassifiedError(shared.CategoryThrottled, errors.Base("feed request failed with status 429"))
+
+// FeedEntry is an upload listed in the Atom feed of a youtube channel
+type FeedEntry struct {
+	VideoID   string
+	Title     string
+	Published time.Time
+}
+
+type atomFeed struct {
+	Entries []struct {
+		VideoID   string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+		Title     string `xml:"title"`
+		Published string `xml:"published"`
+	} `xml:"entry"`
+}
+
+// GetChannelFeed returns the latest uploads of a channel (videos, shorts and streams), most recent first.
+// The feed only lists about 15 entries but costs a single request instead of three yt-dlp listings
+func GetChannelFeed(channelID string, stopChan stop.Chan, pool *ip_manager.IPPool) ([]FeedEntry, error) {
+	var lastError error
+	for attempts := 0; attempts < maxAttempts; attempts++ {
+		sourceAddress, err := getIPFromPool(channelID, stopChan, pool)
+		if err != nil {
+			return nil, err
+		}
+		data, err := fetchFeed(channelFeedURL+channelID, sourceAddress)
+		if err == nil {
+			pool.ReleaseIP(sourceAddress)
+			return parseFeed(data)
+		}
+		if errors.Is(err, shared.ErrThrottled) {
+			pool.SetThrottled(sourceAddress)
+		}
+		pool.ReleaseIP(sourceAddress)
+		lastError = err
+	}
+	return nil, lastError
+}
+
+func fetchFeed(url string, sourceAddress string) ([]byte, error) {
+	dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(sourceAddress)}}
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	req.Header.Set("User-Agent", ChromeUA)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusTooManyRequests {
+		return nil, errors.Err(errFeedThrottled)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Err("feed request failed with status %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxFeedSize))
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	return data, nil
+}
+
+func parseFeed(data []byte) ([]FeedEntry, error) {
+	var feed atomFeed
+	err := xml.Unmarshal(data, &feed)
+	if err != nil {
+		return nil, errors.Prefix("could not parse the channel feed", err)
+	}
+	entries := make([]FeedEntry, 0, len(feed.Entries))
+	for _, e := range feed.Entries {
+		if e.VideoID == "" {
+			continue
+		}
+		published, err := time.Parse(time.RFC3339, e.Published)
+		if err != nil {
+			return nil, errors.Prefix("invalid publication date for "+e.VideoID, err)
+		}
+		entries = append(entries, FeedEntry{VideoID: e.VideoID, Title: e.Title, Published: published})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Published.After(entries[j].Published) })
+	return entries, nil
+}
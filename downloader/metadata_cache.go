@@ -0,0 +1,316 @@
+package downloader
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/configs"
+	"github.com/lbryio/ytsync/v5/downloader/ytdl"
+	"github.com/lbryio/ytsync/v5/ip_manager"
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	infoJSONExtension    = ".info.json"
+	unavailableExtension = ".unavailable"
+
+	defaultMetadataCacheDir = "./videos_metadata"
+	// youtube format URLs expire after about 6 hours and downloads reuse the ones in the info JSON
+	defaultMetadataTTL            = 5 * time.Hour
+	defaultUnavailableMetadataTTL = 24 * time.Hour
+	defaultMetadataCacheSizeMB    = 1024
+)
+
+// MetadataCache keeps the info JSON of videos between runs, so that videos that failed or are still pending don't
+// cost a yt-dlp call every time their channel is synced. Entries are files named after the video ID: <id>.info.json
+// as written by yt-dlp, or <id>.unavailable holding the error yt-dlp returned. Their modification time is the time
+// they were fetched
+type MetadataCache struct {
+	dir            string
+	ttl            time.Duration
+	unavailableTTL time.Duration
+	maxSize        int64
+	// refreshedAfter ignores the entries fetched before it, set by --refresh-metadata
+	refreshedAfter time.Time
+	// ageRestricted ignores the cached unavailability of videos, which was found without age verified cookies
+	ageRestricted bool
+
+	mux  sync.Mutex
+	size int64
+	// pinned counts the users of the info JSON of each video, pinned entries are never evicted
+	pinned map[string]int
+}
+
+// NewMetadataCache opens the cache kept in dir. With refresh, entries fetched before now are ignored
+func NewMetadataCache(dir string, ttl time.Duration, unavailableTTL time.Duration, maxSize int64, refresh bool) (*MetadataCache, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	c := &MetadataCache{
+		dir:            dir,
+		ttl:            ttl,
+		unavailableTTL: unavailableTTL,
+		maxSize:        maxSize,
+		pinned:         make(map[string]int),
+	}
+	if refresh {
+		c.refreshedAfter = time.Now()
+	}
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		c.size += e.Size()
+	}
+	return c, nil
+}
+
+// MetadataCacheFromConfig opens the cache configured in metadata_cache
+func MetadataCacheFromConfig(c configs.MetadataCacheConfig, refresh bool) (*MetadataCache, error) {
+	dir := c.Dir
+	if dir == "" {
+		dir = defaultMetadataCacheDir
+	}
+	ttl, err := parseDuration(c.TTL, defaultMetadataTTL)
+	if err != nil {
+		return nil, errors.Prefix("invalid metadata_cache.ttl", err)
+	}
+	unavailableTTL, err := parseDuration(c.UnavailableTTL, defaultUnavailableMetadataTTL)
+	if err != nil {
+		return nil, errors.Prefix("invalid metadata_cache.unavailable_ttl", err)
+	}
+	maxSizeMB := c.MaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultMetadataCacheSizeMB
+	}
+	return NewMetadataCache(dir, ttl, unavailableTTL, int64(maxSizeMB)*1024*1024, refresh)
+}
+
+func parseDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// SetAgeRestricted makes the runs syncing age restricted videos fetch the videos found unavailable by other runs
+// again, and keeps them from caching their own unavailability
+func (c *MetadataCache) SetAgeRestricted(ageRestricted bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.ageRestricted = ageRestricted
+}
+
+func (c *MetadataCache) isAgeRestricted() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.ageRestricted
+}
+
+// Dir returns the directory holding the info JSON files
+func (c *MetadataCache) Dir() string {
+	return c.dir
+}
+
+func (c *MetadataCache) infoPath(videoID string) string {
+	return filepath.Join(c.dir, videoID+infoJSONExtension)
+}
+
+func (c *MetadataCache) unavailablePath(videoID string) string {
+	return filepath.Join(c.dir, videoID+unavailableExtension)
+}
+
+// fresh reports whether an entry fetched at fetchedAt can still be used
+func (c *MetadataCache) fresh(fetchedAt time.Time, ttl time.Duration, now time.Time) bool {
+	return !fetchedAt.Before(c.refreshedAfter) && now.Sub(fetchedAt) < ttl
+}
+
+// lookup returns the cached metadata of the video, or the error it was unavailable with. hit is false when the video
+// must be fetched again
+func (c *MetadataCache) lookup(videoID string, now time.Time) (video *ytdl.YtdlVideo, hit bool, err error) {
+	if fi, statErr := os.Stat(c.unavailablePath(videoID)); statErr == nil && !c.isAgeRestricted() {
+		if c.fresh(fi.ModTime(), c.unavailableTTL, now) {
+			reason, readErr := os.ReadFile(c.unavailablePath(videoID))
+			if readErr == nil {
+				return nil, true, shared.Classify(errors.Err(string(reason)))
+			}
+		}
+		c.remove(c.unavailablePath(videoID))
+	}
+	fi, statErr := os.Stat(c.infoPath(videoID))
+	if statErr != nil {
+		return nil, false, nil
+	}
+	if !c.fresh(fi.ModTime(), c.ttl, now) {
+		c.remove(c.infoPath(videoID))
+		return nil, false, nil
+	}
+	video, err = parseInfoJSON(c.infoPath(videoID))
+	if err != nil {
+		// yt-dlp was interrupted while writing it
+		logrus.Warnf("dropping the unreadable metadata of %s: %s", videoID, err.Error())
+		c.remove(c.infoPath(videoID))
+		return nil, false, nil
+	}
+	return video, true, nil
+}
+
+// stored accounts for the info JSON yt-dlp just wrote
+func (c *MetadataCache) stored(videoID string) {
+	_ = os.Remove(c.unavailablePath(videoID))
+	fi, err := os.Stat(c.infoPath(videoID))
+	if err != nil {
+		return
+	}
+	c.grow(fi.Size())
+}
+
+// storeUnavailable remembers that the video could not be fetched, when the error says it won't be for a while
+func (c *MetadataCache) storeUnavailable(videoID string, reason error) {
+	if !shared.IsCausedByVideo(reason) || c.isAgeRestricted() {
+		return
+	}
+	message := reason.Error()
+	err := os.WriteFile(c.unavailablePath(videoID), []byte(message), 0644)
+	if err != nil {
+		logrus.Warnf("could not cache the unavailability of %s: %s", videoID, err.Error())
+		return
+	}
+	c.grow(int64(len(message)))
+}
+
+func (c *MetadataCache) grow(size int64) {
+	c.mux.Lock()
+	c.size += size
+	overLimit := c.size > c.maxSize
+	c.mux.Unlock()
+	if overLimit {
+		c.evict()
+	}
+}
+
+func (c *MetadataCache) remove(path string) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if os.Remove(path) == nil {
+		c.mux.Lock()
+		c.size -= fi.Size()
+		c.mux.Unlock()
+	}
+}
+
+// Invalidate drops the cached metadata of the video, so that it is fetched again the next time it is needed
+func (c *MetadataCache) Invalidate(videoID string) {
+	c.remove(c.infoPath(videoID))
+	c.remove(c.unavailablePath(videoID))
+}
+
+// evict removes the oldest entries until the cache is back under 90% of its size limit
+func (c *MetadataCache) evict() {
+	entries, err := c.entries()
+	if err != nil {
+		logrus.Errorf("could not list the metadata cache: %s", err.Error())
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime().Before(entries[j].ModTime()) })
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.size = 0
+	for _, e := range entries {
+		c.size += e.Size()
+	}
+	target := c.maxSize / 10 * 9
+	evicted := 0
+	for _, e := range entries {
+		if c.size <= target {
+			break
+		}
+		if c.pinned[strings.TrimSuffix(strings.TrimSuffix(e.Name(), infoJSONExtension), unavailableExtension)] > 0 {
+			continue
+		}
+		if os.Remove(filepath.Join(c.dir, e.Name())) == nil {
+			c.size -= e.Size()
+			evicted++
+		}
+	}
+	logrus.Infof("evicted %d entries from the metadata cache", evicted)
+}
+
+func (c *MetadataCache) entries() ([]os.FileInfo, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	var entries []os.FileInfo
+	for _, d := range dirEntries {
+		if d.IsDir() || !(strings.HasSuffix(d.Name(), infoJSONExtension) || strings.HasSuffix(d.Name(), unavailableExtension)) {
+			continue
+		}
+		fi, err := d.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, fi)
+	}
+	return entries, nil
+}
+
+// InfoPath returns the path of fresh metadata for the video, to be used with --load-info-json. It is fetched again
+// when it expired or was evicted since it was first fetched. The entry isn't evicted until release is called
+func (c *MetadataCache) InfoPath(videoID string, videoURL string, stopChan stop.Chan, pool *ip_manager.IPPool) (infoPath string, release func(), err error) {
+	release = c.pin(videoID)
+	_, err = GetVideoInformationFromURL(videoID, videoURL, c, stopChan, pool)
+	if err != nil {
+		release()
+		return "", nil, err
+	}
+	return c.infoPath(videoID), release, nil
+}
+
+// pin keeps the entry of the video from being evicted until the returned function is called
+func (c *MetadataCache) pin(videoID string) func() {
+	c.mux.Lock()
+	c.pinned[videoID]++
+	c.mux.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mux.Lock()
+			defer c.mux.Unlock()
+			c.pinned[videoID]--
+			if c.pinned[videoID] <= 0 {
+				delete(c.pinned, videoID)
+			}
+		})
+	}
+}
+
+func parseInfoJSON(infoPath string) (*ytdl.YtdlVideo, error) {
+	data, err := os.ReadFile(infoPath)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	var video *ytdl.YtdlVideo
+	err = json.Unmarshal(data, &video)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	if video == nil {
+		return nil, errors.Err("empty metadata in %s", infoPath)
+	}
+	return video, nil
+}
@@ -0,0 +1,169 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeInfo stands in for yt-dlp writing the info JSON of a video fetched at fetchedAt
+func writeInfo(t *testing.T, c *MetadataCache, videoID string, fetchedAt time.Time) {
+	assert.NoError(t, os.WriteFile(c.infoPath(videoID), []byte(`{"id": "`+videoID+`", "title": "a title", "duration": 60}`), 0644))
+	assert.NoError(t, os.Chtimes(c.infoPath(videoID), fetchedAt, fetchedAt))
+	c.stored(videoID)
+}
+
+func TestMetadataCache(t *testing.T) {
+	c, err := NewMetadataCache(t.TempDir(), time.Hour, 24*time.Hour, 1024*1024, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	now := time.Now()
+	_, hit, err := c.lookup("vid1", now)
+	assert.False(t, hit)
+	assert.NoError(t, err)
+
+	writeInfo(t, c, "vid1", now.Add(-30*time.Minute))
+	video, hit, err := c.lookup("vid1", now)
+	assert.True(t, hit)
+	assert.NoError(t, err)
+	assert.Equal(t, "a title", video.Title)
+
+	// expired entries are dropped
+	_, hit, _ = c.lookup("vid1", now.Add(time.Hour))
+	assert.False(t, hit)
+	_, err = os.Stat(c.infoPath("vid1"))
+	assert.True(t, os.IsNotExist(err))
+
+	// so are the ones yt-dlp didn't finish writing
+	assert.NoError(t, os.WriteFile(c.infoPath("vid2"), []byte(`{"id": "vi`), 0644))
+	_, hit, _ = c.lookup("vid2", now)
+	assert.False(t, hit)
+
+	// unavailable videos are remembered with their own TTL, other errors aren't
+	c.storeUnavailable("vid3", errors.Err("ERROR: [youtube] vid3: Private video. Sign in if you've been granted access to this video"))
+	c.storeUnavailable("vid4", errors.Err("connection reset by peer"))
+	// errors of the same categories caused by the network or the IP aren't either
+	c.storeUnavailable("vid5", errors.Err("ERROR: unable to download video data: HTTP Error 403: Forbidden"))
+	_, hit, err = c.lookup("vid3", now.Add(12*time.Hour))
+	assert.True(t, hit)
+	assert.True(t, errors.Is(err, shared.ErrUnavailableVideo))
+	_, hit, _ = c.lookup("vid3", now.Add(25*time.Hour))
+	assert.False(t, hit)
+	_, hit, _ = c.lookup("vid4", now)
+	assert.False(t, hit)
+	_, hit, _ = c.lookup("vid5", now)
+	assert.False(t, hit)
+}
+
+func TestMetadataCacheRefresh(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewMetadataCache(dir, time.Hour, time.Hour, 1024*1024, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	writeInfo(t, c, "vid1", time.Now().Add(-time.Minute))
+
+	refreshed, err := NewMetadataCache(dir, time.Hour, time.Hour, 1024*1024, true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, hit, _ := refreshed.lookup("vid1", time.Now())
+	assert.False(t, hit)
+	// what is fetched during the run is used
+	writeInfo(t, refreshed, "vid1", time.Now())
+	_, hit, _ = refreshed.lookup("vid1", time.Now())
+	assert.True(t, hit)
+}
+
+func TestMetadataCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewMetadataCache(dir, time.Hour, time.Hour, 200, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	now := time.Now()
+	// each entry takes 51 bytes
+	writeInfo(t, c, "vid01", now.Add(-4*time.Minute))
+	writeInfo(t, c, "vid02", now.Add(-3*time.Minute))
+	writeInfo(t, c, "vid03", now.Add(-2*time.Minute))
+	writeInfo(t, c, "vid04", now.Add(-1*time.Minute))
+
+	// the oldest entries went away until the cache got under 90% of its limit
+	_, err = os.Stat(c.infoPath("vid01"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(c.infoPath("vid02"))
+	assert.NoError(t, err)
+	_, err = os.Stat(c.infoPath("vid04"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(153), c.size)
+
+	// the size is counted again when the cache is opened
+	reopened, err := NewMetadataCache(dir, time.Hour, time.Hour, 200, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(153), reopened.size)
+	_, err = os.Stat(filepath.Join(dir, "vid03.info.json"))
+	assert.NoError(t, err)
+}
+
+func TestMetadataCacheEvictionSkipsPinnedEntries(t *testing.T) {
+	c, err := NewMetadataCache(t.TempDir(), time.Hour, time.Hour, 200, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	now := time.Now()
+	release := c.pin("vid01")
+	writeInfo(t, c, "vid01", now.Add(-4*time.Minute))
+	writeInfo(t, c, "vid02", now.Add(-3*time.Minute))
+	writeInfo(t, c, "vid03", now.Add(-2*time.Minute))
+	writeInfo(t, c, "vid04", now.Add(-1*time.Minute))
+
+	// the oldest entry is in use by a download, the next one goes instead
+	_, err = os.Stat(c.infoPath("vid01"))
+	assert.NoError(t, err)
+	_, err = os.Stat(c.infoPath("vid02"))
+	assert.True(t, os.IsNotExist(err))
+
+	release()
+	release()
+	assert.Empty(t, c.pinned)
+}
+
+func TestMetadataCacheInvalidate(t *testing.T) {
+	c, err := NewMetadataCache(t.TempDir(), time.Hour, time.Hour, 1024*1024, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	now := time.Now()
+	writeInfo(t, c, "vid1", now.Add(-time.Minute))
+	c.Invalidate("vid1")
+	_, hit, _ := c.lookup("vid1", now)
+	assert.False(t, hit)
+	assert.Equal(t, int64(0), c.size)
+}
+
+func TestMetadataCacheAgeRestricted(t *testing.T) {
+	c, err := NewMetadataCache(t.TempDir(), time.Hour, time.Hour, 1024*1024, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	now := time.Now()
+	c.storeUnavailable("vid1", errors.Err("ERROR: [youtube] vid1: This video contains content from SomeLabel"))
+	c.storeUnavailable("vid2", errors.Err("ERROR: [youtube] vid2: Sign in to confirm your age"))
+	_, hit, _ := c.lookup("vid2", now)
+	assert.False(t, hit)
+
+	// the runs with age verified cookies fetch the videos again and don't remember their failures
+	c.SetAgeRestricted(true)
+	_, hit, _ = c.lookup("vid1", now)
+	assert.False(t, hit)
+	c.storeUnavailable("vid3", errors.Err("ERROR: [youtube] vid3: Private video"))
+	_, err = os.Stat(c.unavailablePath("vid3"))
+	assert.True(t, os.IsNotExist(err))
+}
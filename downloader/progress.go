@@ -0,0 +1,120 @@
+package downloader
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressPrefix marks the progress lines among the rest of the output of yt-dlp
+const progressPrefix = "[ytsync-progress] "
+
+// ProgressArgs make yt-dlp print a JSON progress event on its own line every time it reports progress
+var ProgressArgs = []string{"--newline", "--progress-template", "download:" + progressPrefix + "%(progress)j"}
+
+// ProgressEvent is a progress report of yt-dlp about one of the files of a download
+type ProgressEvent struct {
+	// Status is downloading, finished or error
+	Status   string
+	Filename string
+	Bytes    int64
+	// Total is the size of the file, or an estimate when yt-dlp doesn't know it. It is 0 when there is no estimate
+	Total int64
+	// Speed in bytes per second, 0 when it's unknown
+	Speed float64
+	// ETA is -1 when it's unknown
+	ETA time.Duration
+	// Fragment is the index of the fragment being downloaded, 0 for downloads that aren't fragmented
+	Fragment  int
+	Fragments int
+}
+
+// yt-dlp reports the values it doesn't know as null and some sizes as floats
+type rawProgressEvent struct {
+	Status             string   `json:"status"`
+	Filename           string   `json:"filename"`
+	DownloadedBytes    float64  `json:"downloaded_bytes"`
+	TotalBytes         *float64 `json:"total_bytes"`
+	TotalBytesEstimate *float64 `json:"total_bytes_estimate"`
+	Speed              *float64 `json:"speed"`
+	ETA                *float64 `json:"eta"`
+	FragmentIndex      *float64 `json:"fragment_index"`
+	FragmentCount      *float64 `json:"fragment_count"`
+}
+
+// ParseProgressLine returns the progress event printed on the line, if it's one of the lines requested with ProgressArgs
+func ParseProgressLine(line string) (ProgressEvent, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, progressPrefix) {
+		return ProgressEvent{}, false
+	}
+	var raw rawProgressEvent
+	err := json.Unmarshal([]byte(strings.TrimPrefix(line, progressPrefix)), &raw)
+	if err != nil {
+		return ProgressEvent{}, false
+	}
+	e := ProgressEvent{
+		Status:   raw.Status,
+		Filename: raw.Filename,
+		Bytes:    int64(raw.DownloadedBytes),
+		ETA:      -1,
+	}
+	if raw.TotalBytes != nil {
+		e.Total = int64(*raw.TotalBytes)
+	} else if raw.TotalBytesEstimate != nil {
+		e.Total = int64(*raw.TotalBytesEstimate)
+	}
+	if raw.Speed != nil {
+		e.Speed = *raw.Speed
+	}
+	if raw.ETA != nil {
+		e.ETA = time.Duration(*raw.ETA * float64(time.Second))
+	}
+	if raw.FragmentIndex != nil {
+		e.Fragment = int(*raw.FragmentIndex)
+	}
+	if raw.FragmentCount != nil {
+		e.Fragments = int(*raw.FragmentCount)
+	}
+	return e, true
+}
+
+// DownloadProgress is the progress of a whole download
+type DownloadProgress struct {
+	Bytes int64
+	// Total only accounts for the files yt-dlp started downloading
+	Total int64
+	// Speed, ETA and fragments are those of the file being downloaded
+	Speed     float64
+	ETA       time.Duration
+	Fragment  int
+	Fragments int
+}
+
+// ProgressAggregator sums the progress of the files of a download: the video and audio formats are downloaded one
+// after the other, each with its own progress
+type ProgressAggregator struct {
+	mux   sync.Mutex
+	files map[string]ProgressEvent
+}
+
+// Update accounts for the event and returns the progress of the whole download
+func (a *ProgressAggregator) Update(e ProgressEvent) DownloadProgress {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.files == nil {
+		a.files = make(map[string]ProgressEvent)
+	}
+	a.files[e.Filename] = e
+	p := DownloadProgress{Speed: e.Speed, ETA: e.ETA, Fragment: e.Fragment, Fragments: e.Fragments}
+	for _, f := range a.files {
+		p.Bytes += f.Bytes
+		if f.Total > f.Bytes {
+			p.Total += f.Total
+		} else {
+			p.Total += f.Bytes
+		}
+	}
+	return p
+}
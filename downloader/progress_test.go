@@ -0,0 +1,41 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProgressLine(t *testing.T) {
+	_, ok := ParseProgressLine("[download] Destination: /tmp/abc.f137.mp4")
+	assert.False(t, ok)
+
+	e, ok := ParseProgressLine(`[ytsync-progress] {"status":"downloading","filename":"/tmp/abc.f137.mp4","downloaded_bytes":1024,"total_bytes":null,"total_bytes_estimate":4096.5,"speed":2048.0,"eta":2,"fragment_index":3,"fragment_count":10}`)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, "downloading", e.Status)
+	assert.Equal(t, int64(1024), e.Bytes)
+	assert.Equal(t, int64(4096), e.Total)
+	assert.Equal(t, 2048.0, e.Speed)
+	assert.Equal(t, 2*time.Second, e.ETA)
+	assert.Equal(t, 3, e.Fragment)
+	assert.Equal(t, 10, e.Fragments)
+
+	e, ok = ParseProgressLine(`[ytsync-progress] {"status":"downloading","filename":"/tmp/abc.f140.m4a","downloaded_bytes":10,"total_bytes":null,"total_bytes_estimate":null,"speed":null,"eta":null}`)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, int64(0), e.Total)
+	assert.Equal(t, time.Duration(-1), e.ETA)
+}
+
+func TestProgressAggregator(t *testing.T) {
+	var a ProgressAggregator
+	a.Update(ProgressEvent{Filename: "video", Bytes: 4096, Total: 4096})
+	p := a.Update(ProgressEvent{Filename: "audio", Bytes: 512, Total: 1024, Speed: 100})
+	assert.Equal(t, int64(4608), p.Bytes)
+	assert.Equal(t, int64(5120), p.Total)
+	assert.Equal(t, 100.0, p.Speed)
+}
@@ -0,0 +1,333 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+)
+
+// YtDlpRunner starts yt-dlp processes. ExecRunner runs the real binary, FixtureRunner replays recorded runs so that
+// the code around yt-dlp can be tested without network access
+type YtDlpRunner interface {
+	Start(args []string) (YtDlpProcess, error)
+}
+
+// YtDlpProcess is a running yt-dlp process
+type YtDlpProcess interface {
+	Stdout() io.Reader
+	Stderr() io.Reader
+	// Wait returns an error mentioning the exit status when yt-dlp exits with a non-zero code, like exec.Cmd.Wait
+	Wait() error
+	Kill() error
+}
+
+var ytDlpRunner YtDlpRunner = ExecRunner{}
+
+// SetYtDlpRunner replaces the runner used for every yt-dlp call
+func SetYtDlpRunner(runner YtDlpRunner) {
+	ytDlpRunner = runner
+}
+
+// GetYtDlpRunner returns the runner used for every yt-dlp call, the real binary unless SetYtDlpRunner was called
+func GetYtDlpRunner() YtDlpRunner {
+	return ytDlpRunner
+}
+
+// ExecRunner runs the yt-dlp binary found in the PATH, or Binary when it's set
+type ExecRunner struct {
+	Binary string
+}
+
+func (r ExecRunner) Start(args []string) (YtDlpProcess, error) {
+	binary := r.Binary
+	if binary == "" {
+		binary = "yt-dlp"
+	}
+	cmd := exec.Command(binary, args...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	return &execProcess{cmd: cmd, stdout: stdout, stderr: stderr}, nil
+}
+
+type execProcess struct {
+	cmd    *exec.Cmd
+	stdout io.Reader
+	stderr io.Reader
+}
+
+func (p *execProcess) Stdout() io.Reader { return p.stdout }
+func (p *execProcess) Stderr() io.Reader { return p.stderr }
+func (p *execProcess) Wait() error       { return p.cmd.Wait() }
+func (p *execProcess) Kill() error       { return p.cmd.Process.Kill() }
+
+// Fixture is a recorded yt-dlp run
+type Fixture struct {
+	// Match lists the arguments a run must have to be replayed by this fixture, usually the URL
+	Match    []string `json:"match"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr"`
+	ExitCode int      `json:"exit_code"`
+	// Files are the files yt-dlp wrote, keyed by their name relative to the output template (-o): with -o /tmp/abc,
+	// ".info.json" stands for /tmp/abc.info.json
+	Files map[string][]byte `json:"files,omitempty"`
+}
+
+func (f *Fixture) matches(args []string) bool {
+	for _, m := range f.Match {
+		found := false
+		for _, a := range args {
+			if a == m {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadFixture reads a fixture written by RecordingRunner
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	var f Fixture
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return nil, errors.Prefix("invalid fixture "+path, err)
+	}
+	return &f, nil
+}
+
+// FixtureRunner replays recorded runs. Each run replays the first fixture matching its arguments; fixtures that
+// match are used up in order, except for the last one which answers every following run
+type FixtureRunner struct {
+	mux      sync.Mutex
+	fixtures []*Fixture
+	// Runs records the arguments of every run
+	Runs [][]string
+}
+
+// NewFixtureRunner returns a runner replaying the given fixtures
+func NewFixtureRunner(fixtures ...*Fixture) *FixtureRunner {
+	return &FixtureRunner{fixtures: fixtures}
+}
+
+// NewFixtureRunnerFromFiles returns a runner replaying the fixtures stored at paths
+func NewFixtureRunnerFromFiles(paths ...string) (*FixtureRunner, error) {
+	r := &FixtureRunner{}
+	for _, p := range paths {
+		f, err := LoadFixture(p)
+		if err != nil {
+			return nil, err
+		}
+		r.fixtures = append(r.fixtures, f)
+	}
+	return r, nil
+}
+
+func (r *FixtureRunner) Start(args []string) (YtDlpProcess, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.Runs = append(r.Runs, args)
+	matching := -1
+	for i, f := range r.fixtures {
+		if !f.matches(args) {
+			continue
+		}
+		if matching >= 0 {
+			// another fixture follows this one, use this one up
+			fixture := r.fixtures[matching]
+			r.fixtures = append(r.fixtures[:matching], r.fixtures[matching+1:]...)
+			return &fixtureProcess{fixture: fixture, output: outputTemplate(args)}, nil
+		}
+		matching = i
+	}
+	if matching < 0 {
+		return nil, errors.Err("no fixture matches yt-dlp %s", strings.Join(args, " "))
+	}
+	return &fixtureProcess{fixture: r.fixtures[matching], output: outputTemplate(args)}, nil
+}
+
+type fixtureProcess struct {
+	fixture *Fixture
+	output  string
+	mux     sync.Mutex
+	killed  bool
+}
+
+func (p *fixtureProcess) Stdout() io.Reader { return strings.NewReader(p.fixture.Stdout) }
+func (p *fixtureProcess) Stderr() io.Reader { return strings.NewReader(p.fixture.Stderr) }
+
+func (p *fixtureProcess) Wait() error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.killed {
+		return errors.Err("signal: killed")
+	}
+	for name, content := range p.fixture.Files {
+		if p.output == "" {
+			return errors.Err("the fixture has files but yt-dlp was given no output template")
+		}
+		err := os.WriteFile(p.output+name, content, 0644)
+		if err != nil {
+			return errors.Err(err)
+		}
+	}
+	if p.fixture.ExitCode != 0 {
+		return errors.Err("exit status %d", p.fixture.ExitCode)
+	}
+	return nil
+}
+
+func (p *fixtureProcess) Kill() error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.killed = true
+	return nil
+}
+
+// RecordingRunner runs yt-dlp through Runner and saves every run as a fixture in Dir, named after the order of the runs
+type RecordingRunner struct {
+	Runner YtDlpRunner
+	Dir    string
+
+	mux   sync.Mutex
+	count int
+}
+
+func (r *RecordingRunner) Start(args []string) (YtDlpProcess, error) {
+	p, err := r.Runner.Start(args)
+	if err != nil {
+		return nil, err
+	}
+	r.mux.Lock()
+	r.count++
+	path := filepath.Join(r.Dir, fmt.Sprintf("%03d.json", r.count))
+	r.mux.Unlock()
+	rp := &recordingProcess{
+		YtDlpProcess: p,
+		path:         path,
+		output:       outputTemplate(args),
+		fixture:      Fixture{Match: fixtureMatch(args)},
+	}
+	rp.existing = rp.outputFiles()
+	return rp, nil
+}
+
+type recordingProcess struct {
+	YtDlpProcess
+	path     string
+	output   string
+	existing map[string]bool
+	fixture  Fixture
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+}
+
+func (p *recordingProcess) Stdout() io.Reader {
+	return io.TeeReader(p.YtDlpProcess.Stdout(), &p.stdout)
+}
+
+func (p *recordingProcess) Stderr() io.Reader {
+	return io.TeeReader(p.YtDlpProcess.Stderr(), &p.stderr)
+}
+
+func (p *recordingProcess) Wait() error {
+	waitErr := p.YtDlpProcess.Wait()
+	p.fixture.Stdout = p.stdout.String()
+	p.fixture.Stderr = p.stderr.String()
+	if exitErr, ok := waitErr.(*exec.ExitError); ok {
+		p.fixture.ExitCode = exitErr.ExitCode()
+	} else if waitErr != nil {
+		p.fixture.ExitCode = -1
+	}
+	for name := range p.outputFiles() {
+		if p.existing[name] {
+			continue
+		}
+		content, err := os.ReadFile(p.output + name)
+		if err != nil {
+			return errors.Err(err)
+		}
+		if p.fixture.Files == nil {
+			p.fixture.Files = make(map[string][]byte)
+		}
+		p.fixture.Files[name] = content
+	}
+	data, err := json.MarshalIndent(p.fixture, "", "  ")
+	if err != nil {
+		return errors.Err(err)
+	}
+	err = os.WriteFile(p.path, data, 0644)
+	if err != nil {
+		return errors.Err(err)
+	}
+	return waitErr
+}
+
+// outputFiles lists the files named after the output template, relative to it
+func (p *recordingProcess) outputFiles() map[string]bool {
+	files := make(map[string]bool)
+	if p.output == "" {
+		return files
+	}
+	entries, err := os.ReadDir(filepath.Dir(p.output))
+	if err != nil {
+		return files
+	}
+	prefix := filepath.Base(p.output)
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) {
+			files[strings.TrimPrefix(e.Name(), prefix)] = true
+		}
+	}
+	return files
+}
+
+// outputTemplate returns the path given to -o, the files yt-dlp writes are named after it
+func outputTemplate(args []string) string {
+	for i, a := range args {
+		switch {
+		case (a == "-o" || a == "--output") && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(a, "-o") && len(a) > 2 && !strings.HasPrefix(a, "--"):
+			return a[2:]
+		}
+	}
+	return ""
+}
+
+// fixtureMatch picks the arguments identifying a run: the URLs and whether it was a simulation
+func fixtureMatch(args []string) []string {
+	var match []string
+	for _, a := range args {
+		if strings.Contains(a, "://") || a == "-s" || a == "--simulate" || a == "--skip-download" {
+			match = append(match, a)
+		}
+	}
+	sort.Strings(match)
+	return match
+}
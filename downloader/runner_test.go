@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+	"github.com/lbryio/ytsync/v5/ip_manager"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	fixtures := t.TempDir()
+	// sh stands in for yt-dlp: $0 is -o and $1 the output template
+	recorder := &RecordingRunner{Runner: ExecRunner{Binary: "/bin/sh"}, Dir: fixtures}
+	script := `echo "[info] writing metadata"; echo "ERROR: boom" >&2; echo '{"id": "abc"}' > "$1.info.json"; exit 1`
+	output := filepath.Join(t.TempDir(), "abc")
+	args := []string{"-c", script, "-o", output, "https://www.youtube.com/watch?v=abc"}
+	previous := GetYtDlpRunner()
+	SetYtDlpRunner(recorder)
+	defer SetYtDlpRunner(previous)
+
+	_, err := runCmd(args, nil)
+	if !assert.Error(t, err) {
+		return
+	}
+	assert.Contains(t, err.Error(), "ERROR: boom")
+	assert.Contains(t, err.Error(), "exit status 1")
+
+	fixture, err := LoadFixture(filepath.Join(fixtures, "001.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"https://www.youtube.com/watch?v=abc"}, fixture.Match)
+	assert.Equal(t, "[info] writing metadata\n", fixture.Stdout)
+	assert.Equal(t, "ERROR: boom\n", fixture.Stderr)
+	assert.Equal(t, 1, fixture.ExitCode)
+	assert.Equal(t, map[string][]byte{".info.json": []byte("{\"id\": \"abc\"}\n")}, fixture.Files)
+
+	// the replay behaves like the recorded run, in another directory
+	replayOutput := filepath.Join(t.TempDir(), "abc")
+	SetYtDlpRunner(NewFixtureRunner(fixture))
+	_, err = runCmd([]string{"--skip-download", "-o", replayOutput, "https://www.youtube.com/watch?v=abc"}, nil)
+	if !assert.Error(t, err) {
+		return
+	}
+	assert.Contains(t, err.Error(), "ERROR: boom")
+	assert.Contains(t, err.Error(), "exit status 1")
+	content, err := os.ReadFile(replayOutput + ".info.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"id\": \"abc\"}\n", string(content))
+
+	_, err = runCmd([]string{"https://www.youtube.com/watch?v=other"}, nil)
+	assert.Error(t, err)
+}
+
+func TestFixtureRunnerOrder(t *testing.T) {
+	videoURL := "https://www.youtube.com/watch?v=abc"
+	runner := NewFixtureRunner(
+		&Fixture{Match: []string{videoURL}, Stderr: "ERROR: HTTP Error 429: Too Many Requests", ExitCode: 1},
+		&Fixture{Match: []string{videoURL, "-s"}, Stdout: "simulated"},
+		&Fixture{Match: []string{videoURL}, Stdout: "line1\r\nline2"},
+	)
+	previous := GetYtDlpRunner()
+	SetYtDlpRunner(runner)
+	defer SetYtDlpRunner(previous)
+
+	_, err := runCmd([]string{videoURL}, nil)
+	assert.Error(t, err)
+	lines, err := runCmd([]string{videoURL}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line1", "line2"}, lines)
+	// the last fixture keeps answering
+	lines, err = runCmd([]string{videoURL}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line1", "line2"}, lines)
+	lines, err = runCmd([]string{videoURL, "-s"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"simulated"}, lines)
+	assert.Len(t, runner.Runs, 4)
+}
+
+func TestRunCmdInterrupted(t *testing.T) {
+	previous := GetYtDlpRunner()
+	SetYtDlpRunner(ExecRunner{Binary: "/bin/sh"})
+	defer SetYtDlpRunner(previous)
+
+	stopped := stop.New()
+	stopped.Stop()
+	// the process is killed once its output is closed
+	_, err := runCmd([]string{"-c", "exec >&- 2>&-; sleep 10"}, stopped.Ch())
+	assert.True(t, errors.Is(err, ip_manager.ErrInterruptedByUser))
+}
+
+func TestOutputTemplate(t *testing.T) {
+	assert.Equal(t, "/tmp/abc", outputTemplate([]string{"--no-warnings", "-o/tmp/abc", "url"}))
+	assert.Equal(t, "/tmp/abc", outputTemplate([]string{"-o", "/tmp/abc"}))
+	assert.Equal(t, "/tmp/abc", outputTemplate([]string{"--output", "/tmp/abc"}))
+	assert.Equal(t, "", outputTemplate([]string{"--skip-download", "url"}))
+	assert.Equal(t, []string{"-s", "https://vimeo.com/1"}, fixtureMatch(strings.Fields("--no-warnings https://vimeo.com/1 -s")))
+}
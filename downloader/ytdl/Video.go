@@ -24,10 +24,17 @@ type YtdlVideo struct {
 	Availability      string `json:"availability"`
 	ReleaseDate       string `json:"release_date"`
 	UploadDate        string `json:"upload_date"`
+	Thumbnail         string `json:"thumbnail"`
+	WebpageURL        string `json:"webpage_url"`
+	// Subtitles are uploaded by the creator, AutomaticCaptions are generated by youtube. Both are keyed by language
+	Subtitles         map[string][]SubtitleTrack `json:"subtitles"`
+	AutomaticCaptions map[string][]SubtitleTrack `json:"automatic_captions"`
+	Chapters          []Chapter                  `json:"chapters"`
+	// ExtractorKey is the yt-dlp extractor the metadata comes from, "Youtube" for youtube videos
+	ExtractorKey string `json:"extractor_key"`
 
 	//WasLive           bool        `json:"was_live"`
 	//Formats              interface{}   `json:"formats"`
-	//Thumbnail            string        `json:"thumbnail"`
 	//Uploader             string        `json:"uploader"`
 	//UploaderID           string        `json:"uploader_id"`
 	//UploaderURL          string        `json:"uploader_url"`
@@ -35,11 +42,7 @@ type YtdlVideo struct {
 	//ViewCount            int           `json:"view_count"`
 	//AverageRating        interface{}   `json:"average_rating"`
 	//AgeLimit             int           `json:"age_limit"`
-	//WebpageURL           string        `json:"webpage_url"`
 	//PlayableInEmbed      bool          `json:"playable_in_embed"`
-	//AutomaticCaptions    interface{}   `json:"automatic_captions"`
-	//Subtitles            interface{}   `json:"subtitles"`
-	//Chapters             interface{}   `json:"chapters"`
 	//LikeCount            int           `json:"like_count"`
 	//Channel              string        `json:"channel"`
 	//ChannelFollowerCount int           `json:"channel_follower_count"`
@@ -47,7 +50,6 @@ type YtdlVideo struct {
 	//WebpageURLBasename   string        `json:"webpage_url_basename"`
 	//WebpageURLDomain     string        `json:"webpage_url_domain"`
 	//Extractor            string        `json:"extractor"`
-	//ExtractorKey         string        `json:"extractor_key"`
 	//Playlist             interface{}   `json:"playlist"`
 	//PlaylistIndex        interface{}   `json:"playlist_index"`
 	//DisplayID            string        `json:"display_id"`
@@ -81,6 +83,20 @@ type YtdlVideo struct {
 	//Type                 string        `json:"_type"`
 }
 
+// SubtitleTrack is one of the formats a subtitle language is available in
+type SubtitleTrack struct {
+	Ext  string `json:"ext"`
+	URL  string `json:"url"`
+	Name string `json:"name"`
+}
+
+// Chapter is a section of a video, its times are in seconds from the start
+type Chapter struct {
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+	Title     string  `json:"title"`
+}
+
 type Thumbnail struct {
 	URL        string `json:"url"`
 	Preference int    `json:"preference"`
@@ -95,6 +111,12 @@ func (v *YtdlVideo) GetThumbnailUrl() string {
 	return fmt.Sprintf("https://i.ytimg.com/vi/%s/hqdefault.jpg", v.ID)
 }
 
+// IsYoutube reports whether the metadata comes from youtube. Metadata saved before the extractor was recorded is
+// assumed to come from youtube
+func (v *YtdlVideo) IsYoutube() bool {
+	return v.ExtractorKey == "" || v.ExtractorKey == "Youtube"
+}
+
 func (v *YtdlVideo) GetUploadTime() time.Time {
 	//priority list:
 	// release timestamp from yt
@@ -111,13 +133,15 @@ func (v *YtdlVideo) GetUploadTime() time.Time {
 	}
 	//get morty timestamp
 	var mortyReleaseTimestamp time.Time
-	mortyRelease, err := sdk.GetAPIsConfigs().GetReleasedDate(v.ID)
-	if err != nil {
-		logrus.Error(err)
-	} else if mortyRelease != nil {
-		mortyReleaseTimestamp, err = time.ParseInLocation(time.RFC3339, mortyRelease.ReleaseTime, time.UTC)
+	if v.IsYoutube() {
+		mortyRelease, err := sdk.GetJobStore().GetReleasedDate(v.ID)
 		if err != nil {
 			logrus.Error(err)
+		} else if mortyRelease != nil {
+			mortyReleaseTimestamp, err = time.ParseInLocation(time.RFC3339, mortyRelease.ReleaseTime, time.UTC)
+			if err != nil {
+				logrus.Error(err)
+			}
 		}
 	}
 
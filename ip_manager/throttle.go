@@ -79,6 +79,8 @@ func (i *IPPool) UpdateIps() error {
 	if err != nil {
 		return err
 	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
 	newIPsMap := make(map[string]bool)
 	for _, ip := range currentIPs {
 		newIPsMap[ip.IP] = true
@@ -126,6 +128,19 @@ func AllInUse(ips []throttledIP) bool {
 	return true
 }
 
+// FreeIPs returns the number of IPs that are neither in use nor throttled
+func (i *IPPool) FreeIPs() int {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	free := 0
+	for _, ip := range i.ips {
+		if !ip.InUse && !ip.Throttled {
+			free++
+		}
+	}
+	return free
+}
+
 func (i *IPPool) ReleaseIP(ip string) {
 	i.lock.Lock()
 	defer i.lock.Unlock()
@@ -9,6 +9,8 @@ import (
 
 	"github.com/lbryio/ytsync/v5/configs"
 	"github.com/lbryio/ytsync/v5/manager"
+	"github.com/lbryio/ytsync/v5/progress"
+	"github.com/lbryio/ytsync/v5/sdk"
 	"github.com/lbryio/ytsync/v5/shared"
 	ytUtils "github.com/lbryio/ytsync/v5/util"
 
@@ -38,6 +40,8 @@ func main() {
 	customFormatter.FullTimestamp = true
 	log.SetFormatter(customFormatter)
 	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/status", progress.Handler())
+	http.Handle("/status.html", progress.HTMLHandler())
 	go func() {
 		log.Error(http.ListenAndServe(":2112", nil))
 	}()
@@ -61,13 +65,18 @@ func main() {
 	cmd.Flags().BoolVar(&cliFlags.UpgradeMetadata, "upgrade-metadata", false, "Upgrade videos if they're on the old metadata version")
 	cmd.Flags().BoolVar(&cliFlags.DisableTransfers, "no-transfers", false, "Skips the transferring process of videos, channels and supports")
 	cmd.Flags().BoolVar(&cliFlags.QuickSync, "quick", false, "Look up only the last 50 videos from youtube")
+	cmd.Flags().BoolVar(&cliFlags.FullScan, "full-scan", false, "List all the videos of each channel instead of looking for new uploads in the channel feed")
+	cmd.Flags().BoolVar(&cliFlags.RefreshMetadata, "refresh-metadata", false, "Fetch the metadata of every video again instead of using the metadata cache")
+	cmd.Flags().BoolVar(&cliFlags.SyncPlaylists, "sync-playlists", false, "Publish the playlists of each channel as collections once its videos are synced")
 	cmd.Flags().BoolVar(&cliFlags.WithDeletedOnYoutube, "with-youtube-deleted", false, "Fetch/process channels that have been deleted on Youtube too")
 	cmd.Flags().StringVar(&cliFlags.Status, "status", "", "Specify which queue to pull from. Overrides --update")
 	cmd.Flags().StringVar(&cliFlags.SecondaryStatus, "status2", "", "Specify which secondary queue to pull from.")
 	cmd.Flags().StringVar(&cliFlags.ChannelID, "channelID", "", "If specified, only this channel will be synced.")
+	cmd.Flags().StringVar(&cliFlags.SourceURL, "source-url", "", "Sync the channel from this URL (any site supported by yt-dlp, or file:// for a local directory) instead of youtube. Requires --channelID")
 	cmd.Flags().Int64Var(&cliFlags.SyncFrom, "after", time.Unix(0, 0).Unix(), "Specify from when to pull jobs [Unix time](Default: 0)")
 	cmd.Flags().Int64Var(&cliFlags.SyncUntil, "before", time.Now().AddDate(1, 0, 0).Unix(), "Specify until when to pull jobs [Unix time](Default: current Unix time)")
 	cmd.Flags().IntVar(&cliFlags.ConcurrentJobs, "concurrent-jobs", 1, "how many jobs to process concurrently")
+	cmd.Flags().IntVar(&cliFlags.ConcurrentChannels, "concurrent-channels", 1, "how many channels to sync in parallel. Each channel needs its own daemon (see README)")
 	cmd.Flags().IntVar(&cliFlags.VideosLimit, "videos-limit", 0, "how many videos to process per channel (leave 0 for automatic detection)")
 	cmd.Flags().IntVar(&cliFlags.MaxVideoSize, "max-size", 2048, "Maximum video size to process (in MB)")
 	cmd.Flags().IntVar(&maxVideoLength, "max-length", 2, "Maximum video length to process (in hours)")
@@ -100,18 +109,37 @@ func ytSync(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if cliFlags.ConcurrentChannels < 1 {
+		log.Errorln("setting --concurrent-channels less than 1 doesn't make sense")
+		return
+	}
+
+	if cliFlags.SourceURL != "" && !cliFlags.IsSingleChannelSync() {
+		log.Errorln("--source-url requires --channelID")
+		return
+	}
+
 	if cliFlags.Limit < 0 {
 		log.Errorln("setting --limit less than 0 (unlimited) doesn't make sense")
 		return
 	}
 	cliFlags.MaxVideoLength = time.Duration(maxVideoLength) * time.Hour
 
-	if configs.Configuration.InternalApisEndpoint == "" {
-		log.Errorln("An Internal APIs Endpoint was not defined")
-		return
+	if configs.Configuration.LocalJobStorePath == "" {
+		if configs.Configuration.InternalApisEndpoint == "" {
+			log.Errorln("An Internal APIs Endpoint was not defined")
+			return
+		}
+		if configs.Configuration.InternalApisAuthToken == "" {
+			log.Errorln("An Internal APIs auth token was not defined")
+			return
+		}
+	} else {
+		log.Infof("Using the local job store in %s instead of internal-apis", configs.Configuration.LocalJobStorePath)
 	}
-	if configs.Configuration.InternalApisAuthToken == "" {
-		log.Errorln("An Internal APIs auth token was not defined")
+	err = sdk.InitJobStore()
+	if err != nil {
+		log.Errorf("could not initialize the job store: %s", errors.FullTrace(err))
 		return
 	}
 	if configs.Configuration.WalletS3Config.ID == "" || configs.Configuration.WalletS3Config.Region == "" || configs.Configuration.WalletS3Config.Bucket == "" || configs.Configuration.WalletS3Config.Secret == "" || configs.Configuration.WalletS3Config.Endpoint == "" {
@@ -122,6 +150,17 @@ func ytSync(cmd *cobra.Command, args []string) {
 		log.Errorln("Blockchain DBs S3 configuration is incomplete")
 		return
 	}
+	if configs.Configuration.UseVpn && cliFlags.ConcurrentChannels > 1 {
+		log.Errorln("the VPN is shared by the whole host and cannot be used with --concurrent-channels")
+		return
+	}
+	if configs.Configuration.ErrorRulesPath != "" {
+		err = shared.LoadErrorRules(configs.Configuration.ErrorRulesPath)
+		if err != nil {
+			log.Errorf("could not load the error classification rules: %s", errors.FullTrace(err))
+			return
+		}
+	}
 	if configs.Configuration.LbrycrdString == "" {
 		log.Infoln("Using default (local) lbrycrd instance. Set lbrycrd_string if you want to use something else")
 	}
@@ -132,6 +171,11 @@ func ytSync(cmd *cobra.Command, args []string) {
 		cliFlags,
 		blobsDir,
 	)
+	if configs.Configuration.AdminToken != "" {
+		http.Handle("/admin/", sm.AdminHandler(configs.Configuration.AdminToken))
+	} else {
+		log.Infoln("admin_token is not set, the admin API is disabled. Use the .freeze and .update files instead")
+	}
 	err = sm.Start()
 	if err != nil {
 		ytUtils.SendErrorToSlack(errors.FullTrace(err))
@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AdminHandler serves the admin API under /admin/. Every request must carry the token as "Authorization: Bearer <token>"
+func (s *SyncManager) AdminHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/state", allowMethod(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.View())
+	}))
+	mux.HandleFunc("/admin/pause", allowMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		s.Pause()
+		writeJSON(w, http.StatusOK, s.View())
+	}))
+	mux.HandleFunc("/admin/resume", allowMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		s.Resume()
+		writeJSON(w, http.StatusOK, s.View())
+	}))
+	mux.HandleFunc("/admin/drain", allowMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		s.Drain()
+		writeJSON(w, http.StatusOK, s.View())
+	}))
+	mux.HandleFunc("/admin/abort", allowMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		err := s.AbortChannel(r.FormValue("channel_id"))
+		if err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, s.View())
+	}))
+	mux.HandleFunc("/admin/skip-video", allowMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		videoID := r.FormValue("video_id")
+		if videoID == "" {
+			writeError(w, http.StatusBadRequest, "video_id is required")
+			return
+		}
+		s.SkipVideo(videoID)
+		writeJSON(w, http.StatusOK, s.View())
+	}))
+	mux.HandleFunc("/admin/sync-next", allowMethod(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		channelID := r.FormValue("channel_id")
+		if channelID == "" {
+			writeError(w, http.StatusBadRequest, "channel_id is required")
+			return
+		}
+		s.SyncNext(channelID)
+		writeJSON(w, http.StatusOK, s.View())
+	}))
+	return requireToken(token, mux)
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		log.Infof("admin API: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func allowMethod(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Errorf("failed to write the admin API response: %s", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
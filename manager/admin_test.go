@@ -0,0 +1,104 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+	"github.com/lbryio/ytsync/v5/shared"
+	logUtils "github.com/lbryio/ytsync/v5/util"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testManager() *SyncManager {
+	return &SyncManager{
+		stopGroup: stop.New(),
+		instances: []logUtils.Instance{logUtils.DefaultInstance},
+		control:   newControlState(),
+	}
+}
+
+func adminRequest(t *testing.T, handler http.Handler, method, path, token string, form url.Values) (int, ManagerView) {
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var view ManagerView
+	if rec.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &view))
+	}
+	return rec.Code, view
+}
+
+func TestAdminAPIRequiresToken(t *testing.T) {
+	handler := testManager().AdminHandler("secret")
+	code, _ := adminRequest(t, handler, http.MethodGet, "/admin/state", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = adminRequest(t, handler, http.MethodGet, "/admin/state", "wrong", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = adminRequest(t, handler, http.MethodPost, "/admin/state", "secret", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _ = adminRequest(t, handler, http.MethodGet, "/admin/state", "secret", nil)
+	assert.Equal(t, http.StatusOK, code)
+
+	// an empty token never authenticates
+	code, _ = adminRequest(t, testManager().AdminHandler(""), http.MethodGet, "/admin/state", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestAdminAPIControls(t *testing.T) {
+	m := testManager()
+	handler := m.AdminHandler("secret")
+
+	code, view := adminRequest(t, handler, http.MethodPost, "/admin/pause", "secret", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, view.Paused)
+	assert.True(t, m.isPaused())
+	_, view = adminRequest(t, handler, http.MethodPost, "/admin/resume", "secret", nil)
+	assert.False(t, view.Paused)
+
+	_, view = adminRequest(t, handler, http.MethodPost, "/admin/skip-video", "secret", url.Values{"video_id": {"vid1"}})
+	assert.Equal(t, []string{"vid1"}, view.SkippedVideos)
+	assert.True(t, m.isVideoSkipped("vid1"))
+	code, _ = adminRequest(t, handler, http.MethodPost, "/admin/skip-video", "secret", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	adminRequest(t, handler, http.MethodPost, "/admin/sync-next", "secret", url.Values{"channel_id": {"UCabc"}})
+	_, view = adminRequest(t, handler, http.MethodPost, "/admin/sync-next", "secret", url.Values{"channel_id": {"UCabc"}})
+	assert.Equal(t, []string{"UCabc"}, view.SyncNext)
+	assert.Equal(t, []string{"UCabc"}, m.popSyncNext())
+	assert.Empty(t, m.popSyncNext())
+
+	_, view = adminRequest(t, handler, http.MethodPost, "/admin/drain", "secret", nil)
+	assert.True(t, view.Draining)
+}
+
+func TestAdminAPIAbort(t *testing.T) {
+	m := testManager()
+	handler := m.AdminHandler("secret")
+	code, _ := adminRequest(t, handler, http.MethodPost, "/admin/abort", "secret", nil)
+	assert.Equal(t, http.StatusConflict, code)
+
+	m.enqueueChannel(&shared.YoutubeChannel{ChannelId: "UCabc", DesiredChannelName: "@abc"})
+	chanToSync := &m.channelsToSync[0]
+	m.syncStarted(chanToSync)
+	code, view := adminRequest(t, handler, http.MethodPost, "/admin/abort", "secret", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, view.Syncing, 1)
+	assert.True(t, view.Syncing[0].Aborted)
+	assert.True(t, chanToSync.IsInterrupted())
+	assert.True(t, chanToSync.isAborted())
+	// the other pipelines keep going
+	assert.False(t, m.isInterrupted())
+
+	m.syncEnded(chanToSync, true)
+	assert.Equal(t, 1, m.View().ProcessedChannels)
+}
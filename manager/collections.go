@@ -0,0 +1,256 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/downloader"
+	"github.com/lbryio/ytsync/v5/namer"
+	"github.com/lbryio/ytsync/v5/sdk"
+	logUtils "github.com/lbryio/ytsync/v5/util"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/jsonrpc"
+
+	log "github.com/sirupsen/logrus"
+	rpc "github.com/ybbus/jsonrpc/v2"
+)
+
+// maxPlaylistItems bounds how many videos of a playlist end up in its collection
+const maxPlaylistItems = 1000
+
+// collectionClient calls the collection methods of the daemon, which the lbry.go client doesn't have
+type collectionClient struct {
+	conn rpc.RPCClient
+}
+
+func newCollectionClient(address string) *collectionClient {
+	return &collectionClient{
+		conn: rpc.NewClientWithOpts(address, &rpc.RPCClientOpts{
+			HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+		}),
+	}
+}
+
+type collectionOptions struct {
+	Title        string
+	ClaimAddress string
+	ChannelID    string
+	AccountID    string
+}
+
+// call runs a collection method and returns the ID of the collection claim it created or updated
+func (c *collectionClient) call(method string, params map[string]interface{}) (string, error) {
+	res, err := c.conn.Call(method, params)
+	if err != nil {
+		return "", errors.Err(err)
+	}
+	if res.Error != nil {
+		return "", errors.Err(jsonrpc.WrapError(res.Error))
+	}
+	var summary jsonrpc.TransactionSummary
+	err = jsonrpc.Decode(res.Result, &summary)
+	if err != nil {
+		return "", errors.Err(err)
+	}
+	if len(summary.Outputs) == 0 {
+		return "", errors.Err("%s returned no outputs", method)
+	}
+	return summary.Outputs[0].ClaimID, nil
+}
+
+func (c *collectionClient) create(n *namer.Namer, bid float64, claims []string, options collectionOptions) (string, error) {
+	for {
+		claimID, err := c.call("collection_create", map[string]interface{}{
+			"name":                n.GetNextName(options.Title),
+			"bid":                 fmt.Sprintf("%.6f", bid),
+			"claims":              claims,
+			"title":               options.Title,
+			"claim_address":       options.ClaimAddress,
+			"channel_id":          options.ChannelID,
+			"funding_account_ids": []string{options.AccountID},
+			"blocking":            true,
+		})
+		if err != nil && strings.Contains(err.Error(), "failed: Multiple claims (") {
+			continue
+		}
+		return claimID, err
+	}
+}
+
+func (c *collectionClient) update(claimID string, claims []string, options collectionOptions) (string, error) {
+	return c.call("collection_update", map[string]interface{}{
+		"claim_id":            claimID,
+		"claims":              claims,
+		"clear_claims":        true,
+		"title":               options.Title,
+		"channel_id":          options.ChannelID,
+		"funding_account_ids": []string{options.AccountID},
+		"blocking":            true,
+	})
+}
+
+// walletCollection is a collection found in the wallet by collection_list
+type walletCollection struct {
+	ClaimID string `json:"claim_id"`
+	Value   struct {
+		Title  string   `json:"title"`
+		Claims []string `json:"claims"`
+	} `json:"value"`
+	SigningChannel *struct {
+		ClaimID string `json:"claim_id"`
+	} `json:"signing_channel"`
+}
+
+// list returns the collections of the account
+func (c *collectionClient) list(accountID string) ([]walletCollection, error) {
+	var collections []walletCollection
+	for page := 1; ; page++ {
+		res, err := c.conn.Call("collection_list", map[string]interface{}{
+			"account_id": accountID,
+			"page":       page,
+			"page_size":  50,
+		})
+		if err != nil {
+			return nil, errors.Err(err)
+		}
+		if res.Error != nil {
+			return nil, errors.Err(jsonrpc.WrapError(res.Error))
+		}
+		var list struct {
+			Items      []walletCollection `json:"items"`
+			TotalPages int                `json:"total_pages"`
+		}
+		err = jsonrpc.Decode(res.Result, &list)
+		if err != nil {
+			return nil, errors.Err(err)
+		}
+		collections = append(collections, list.Items...)
+		if page >= list.TotalPages {
+			return collections, nil
+		}
+	}
+}
+
+// findCollection returns the collection of the wallet signed by the channel with the title of the playlist, so that a
+// playlist published from another host isn't published again. taken holds the collections already used by other
+// playlists
+func findCollection(collections []walletCollection, channelClaimID string, title string, taken map[string]bool) (sdk.PlaylistCollection, bool) {
+	for _, c := range collections {
+		if c.SigningChannel == nil || c.SigningChannel.ClaimID != channelClaimID || c.Value.Title != title || taken[c.ClaimID] {
+			continue
+		}
+		return sdk.PlaylistCollection{ClaimID: c.ClaimID, Claims: c.Value.Claims}, true
+	}
+	return sdk.PlaylistCollection{}, false
+}
+
+// playlistClaims returns the claims of the published videos of a playlist, in playlist order
+func playlistClaims(items []downloader.PlaylistItem, syncedVideos map[string]sdk.SyncedVideo) []string {
+	claims := make([]string, 0, len(items))
+	for _, item := range items {
+		sv, ok := syncedVideos[item.ID]
+		if !ok || !sv.Published || sv.ClaimID == "" || slices.Contains(claims, sv.ClaimID) {
+			continue
+		}
+		claims = append(claims, sv.ClaimID)
+	}
+	return claims
+}
+
+// syncPlaylists publishes the playlists of the channel as collections of the videos synced so far.
+// Collections are updated when their videos change and left alone otherwise. Playlists missing from the collection
+// index are looked up in the wallet by title, as the index is kept on the host and the wallet may have moved
+func (s *Sync) syncPlaylists() error {
+	start := time.Now()
+	playlists, err := downloader.GetChannelPlaylists(s.DbChannelData.ChannelId, s.grp.Ch(), s.Manager.ipPool)
+	if err != nil {
+		return err
+	}
+	da, err := s.getDefaultAccount()
+	if err != nil {
+		return err
+	}
+	client := newCollectionClient(s.instance.LBRYNetAddress())
+	// the collections of the wallet are only listed when a playlist isn't in the index
+	var walletCollections []walletCollection
+	walletListed := false
+	taken := make(map[string]bool)
+	for _, p := range playlists {
+		if existing, ok := s.Manager.collections.Get(s.DbChannelData.ChannelId, p.ID); ok {
+			taken[existing.ClaimID] = true
+		}
+	}
+	created, updated := 0, 0
+	for _, p := range playlists {
+		if s.IsInterrupted() {
+			break
+		}
+		items, err := downloader.GetPlaylistItems(downloader.PlaylistURL(p.ID), maxPlaylistItems, s.grp.Ch(), s.Manager.ipPool)
+		if err != nil {
+			logUtils.SendErrorToSlack("could not list playlist %s of %s: %s", p.ID, s.DbChannelData.ChannelId, err.Error())
+			continue
+		}
+		s.syncedVideosMux.RLock()
+		claims := playlistClaims(items, s.syncedVideos)
+		s.syncedVideosMux.RUnlock()
+		if len(claims) == 0 {
+			continue
+		}
+		existing, ok := s.Manager.collections.Get(s.DbChannelData.ChannelId, p.ID)
+		if !ok {
+			if !walletListed {
+				s.walletMux.RLock()
+				walletCollections, err = client.list(da)
+				s.walletMux.RUnlock()
+				if err != nil {
+					return err
+				}
+				walletListed = true
+			}
+			existing, ok = findCollection(walletCollections, s.DbChannelData.ChannelClaimID, p.Title, taken)
+			if ok {
+				taken[existing.ClaimID] = true
+				err = s.Manager.collections.Set(s.DbChannelData.ChannelId, p.ID, existing)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if ok && slices.Equal(existing.Claims, claims) {
+			continue
+		}
+		options := collectionOptions{
+			Title:        p.Title,
+			ClaimAddress: s.DbChannelData.PublishAddress.Address,
+			ChannelID:    s.DbChannelData.ChannelClaimID,
+			AccountID:    da,
+		}
+		s.walletMux.RLock()
+		var claimID string
+		if ok {
+			claimID, err = client.update(existing.ClaimID, claims, options)
+		} else {
+			claimID, err = client.create(s.namer, publishAmount, claims, options)
+		}
+		s.walletMux.RUnlock()
+		if err != nil {
+			logUtils.SendErrorToSlack("could not publish playlist %s of %s as a collection: %s", p.ID, s.DbChannelData.ChannelId, err.Error())
+			continue
+		}
+		if ok {
+			updated++
+		} else {
+			created++
+		}
+		err = s.Manager.collections.Set(s.DbChannelData.ChannelId, p.ID, sdk.PlaylistCollection{ClaimID: claimID, Claims: claims})
+		if err != nil {
+			return err
+		}
+	}
+	log.Infof("playlists of %s synced in %s: %d collections created, %d updated", s.DbChannelData.ChannelId, time.Since(start), created, updated)
+	return nil
+}
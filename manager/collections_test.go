@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lbryio/ytsync/v5/downloader"
+	"github.com/lbryio/ytsync/v5/namer"
+	"github.com/lbryio/ytsync/v5/sdk"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaylistClaims(t *testing.T) {
+	synced := map[string]sdk.SyncedVideo{
+		"vid1": {VideoID: "vid1", Published: true, ClaimID: "claim1"},
+		"vid2": {VideoID: "vid2", Published: false, FailureReason: "too long"},
+		"vid3": {VideoID: "vid3", Published: true, ClaimID: "claim3"},
+	}
+	items := []downloader.PlaylistItem{{ID: "vid3"}, {ID: "vid2"}, {ID: "unknown"}, {ID: "vid1"}, {ID: "vid3"}}
+	assert.Equal(t, []string{"claim3", "claim1"}, playlistClaims(items, synced))
+	assert.Empty(t, playlistClaims(nil, synced))
+}
+
+func TestCollectionClient(t *testing.T) {
+	var requests []map[string]interface{}
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+		params := req["params"].(map[string]interface{})
+		if req["method"] == "collection_create" && params["name"] == "my-playlist" {
+			_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 0, "error": {"code": -32500, "message": "failed: Multiple claims (2) found", "data": {"name": "Exception"}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 0, "result": {"txid": "tx", "outputs": [{"claim_id": "collection1", "name": "my-playlist-2"}]}}`))
+	}))
+	defer daemon.Close()
+
+	client := newCollectionClient(daemon.URL)
+	options := collectionOptions{Title: "My Playlist", ClaimAddress: "bAddress", ChannelID: "channel1", AccountID: "account1"}
+	n := namer.NewNamer()
+	n.SetNames(map[string]bool{})
+	claimID, err := client.create(n, 0.002, []string{"claim1", "claim2"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "collection1", claimID)
+	// the first name was taken
+	assert.Len(t, requests, 2)
+	params := requests[1]["params"].(map[string]interface{})
+	assert.Equal(t, "collection_create", requests[1]["method"])
+	assert.NotEqual(t, "my-playlist", params["name"])
+	assert.Equal(t, "0.002000", params["bid"])
+	assert.Equal(t, []interface{}{"claim1", "claim2"}, params["claims"])
+	assert.Equal(t, "channel1", params["channel_id"])
+	assert.Equal(t, "bAddress", params["claim_address"])
+
+	claimID, err = client.update("collection1", []string{"claim2"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, "collection1", claimID)
+	params = requests[2]["params"].(map[string]interface{})
+	assert.Equal(t, "collection_update", requests[2]["method"])
+	assert.Equal(t, "collection1", params["claim_id"])
+	assert.Equal(t, true, params["clear_claims"])
+	assert.Equal(t, []interface{}{"claim2"}, params["claims"])
+}
+
+func TestCollectionClientList(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "collection_list", req["method"])
+		params := req["params"].(map[string]interface{})
+		assert.Equal(t, "account1", params["account_id"])
+		if params["page"] == float64(1) {
+			_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 0, "result": {"page": 1, "total_pages": 2, "items": [{"claim_id": "collection1", "value": {"title": "My Playlist", "claims": ["claim1"]}, "signing_channel": {"claim_id": "channel1"}}]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 0, "result": {"page": 2, "total_pages": 2, "items": [{"claim_id": "collection2", "value": {"title": "Other"}}]}}`))
+	}))
+	defer daemon.Close()
+
+	collections, err := newCollectionClient(daemon.URL).list("account1")
+	assert.NoError(t, err)
+	if assert.Len(t, collections, 2) {
+		assert.Equal(t, "collection1", collections[0].ClaimID)
+		assert.Equal(t, []string{"claim1"}, collections[0].Value.Claims)
+		assert.Nil(t, collections[1].SigningChannel)
+	}
+}
+
+func TestFindCollection(t *testing.T) {
+	var collections []walletCollection
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"claim_id": "unsigned", "value": {"title": "My Playlist"}},
+		{"claim_id": "other-channel", "value": {"title": "My Playlist"}, "signing_channel": {"claim_id": "channel2"}},
+		{"claim_id": "first", "value": {"title": "My Playlist", "claims": ["claim1"]}, "signing_channel": {"claim_id": "channel1"}},
+		{"claim_id": "second", "value": {"title": "My Playlist", "claims": ["claim2"]}, "signing_channel": {"claim_id": "channel1"}}
+	]`), &collections))
+
+	taken := map[string]bool{}
+	c, ok := findCollection(collections, "channel1", "My Playlist", taken)
+	assert.True(t, ok)
+	assert.Equal(t, sdk.PlaylistCollection{ClaimID: "first", Claims: []string{"claim1"}}, c)
+	// a collection is only recovered for one playlist
+	taken["first"] = true
+	c, ok = findCollection(collections, "channel1", "My Playlist", taken)
+	assert.True(t, ok)
+	assert.Equal(t, "second", c.ClaimID)
+	_, ok = findCollection(collections, "channel1", "Renamed", taken)
+	assert.False(t, ok)
+}
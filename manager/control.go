@@ -0,0 +1,255 @@
+package manager
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/progress"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// errAbortedByOperator is the failure reason of channels aborted through the admin API
+var errAbortedByOperator = errors.Base("sync aborted by an operator")
+
+// controlState holds the requests made by operators, either through the admin API or the marker files
+type controlState struct {
+	mux       sync.Mutex
+	paused    bool
+	draining  bool
+	syncNext  []string
+	skipped   map[string]bool
+	running   map[string]*runningSync
+	processed int
+	// wake interrupts the pauses of the manager loop when there is something new to do
+	wake chan struct{}
+}
+
+type runningSync struct {
+	sync      *Sync
+	startedAt time.Time
+}
+
+func newControlState() *controlState {
+	return &controlState{
+		skipped: make(map[string]bool),
+		running: make(map[string]*runningSync),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+func (c *controlState) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ChannelSyncView describes a channel that is being synced
+type ChannelSyncView struct {
+	ChannelID   string    `json:"channel_id"`
+	ChannelName string    `json:"channel_name"`
+	Instance    int       `json:"instance"`
+	StartedAt   time.Time `json:"started_at"`
+	Aborted     bool      `json:"aborted"`
+}
+
+// ManagerView is a snapshot of what the manager is doing
+type ManagerView struct {
+	Paused            bool              `json:"paused"`
+	Draining          bool              `json:"draining"`
+	SyncNext          []string          `json:"sync_next"`
+	SkippedVideos     []string          `json:"skipped_videos"`
+	Syncing           []ChannelSyncView `json:"syncing"`
+	ProcessedChannels int               `json:"processed_channels"`
+	Pipelines         int               `json:"pipelines"`
+}
+
+// Pause stops the manager from starting new channels. Channels already being synced are not affected
+func (s *SyncManager) Pause() {
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	s.control.paused = true
+	log.Infoln("Manager paused by an operator")
+}
+
+// Resume undoes Pause. It doesn't override a .freeze file
+func (s *SyncManager) Resume() {
+	s.control.mux.Lock()
+	s.control.paused = false
+	s.control.mux.Unlock()
+	s.control.notify()
+	log.Infoln("Manager resumed by an operator")
+}
+
+// Drain makes the manager exit once the channels being synced are done, like the .update file does
+func (s *SyncManager) Drain() {
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	s.control.draining = true
+	log.Infoln("Manager will exit after the current channels")
+}
+
+// AbortChannel stops the sync of a channel and marks it as failed. When channelID is empty and a single channel
+// is being synced, that channel is aborted
+func (s *SyncManager) AbortChannel(channelID string) error {
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	if channelID == "" {
+		if len(s.control.running) != 1 {
+			return errors.Err("%d channels are being synced, a channel ID is required", len(s.control.running))
+		}
+		for id := range s.control.running {
+			channelID = id
+		}
+	}
+	rs, ok := s.control.running[channelID]
+	if !ok {
+		return errors.Err("channel %s is not being synced", channelID)
+	}
+	rs.sync.abort()
+	log.Infof("Sync of %s aborted by an operator", channelID)
+	return nil
+}
+
+// SkipVideo makes every channel skip the video instead of processing it, until ytsync is restarted.
+// A video that is already being processed is not interrupted
+func (s *SyncManager) SkipVideo(videoID string) {
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	s.control.skipped[videoID] = true
+	log.Infof("Video %s will be skipped", videoID)
+}
+
+// SyncNext queues the channel ahead of the job store queues
+func (s *SyncManager) SyncNext(channelID string) {
+	s.control.mux.Lock()
+	for _, id := range s.control.syncNext {
+		if id == channelID {
+			s.control.mux.Unlock()
+			return
+		}
+	}
+	s.control.syncNext = append(s.control.syncNext, channelID)
+	s.control.mux.Unlock()
+	s.control.notify()
+	log.Infof("Channel %s will be synced next", channelID)
+}
+
+// View returns a snapshot of what the manager is doing
+func (s *SyncManager) View() ManagerView {
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	view := ManagerView{
+		Paused:            s.isPausedLocked(),
+		Draining:          s.control.draining,
+		SyncNext:          append([]string{}, s.control.syncNext...),
+		SkippedVideos:     make([]string, 0, len(s.control.skipped)),
+		Syncing:           make([]ChannelSyncView, 0, len(s.control.running)),
+		ProcessedChannels: s.control.processed,
+		Pipelines:         len(s.instances),
+	}
+	for id := range s.control.skipped {
+		view.SkippedVideos = append(view.SkippedVideos, id)
+	}
+	sort.Strings(view.SkippedVideos)
+	for _, rs := range s.control.running {
+		view.Syncing = append(view.Syncing, ChannelSyncView{
+			ChannelID:   rs.sync.DbChannelData.ChannelId,
+			ChannelName: rs.sync.DbChannelData.DesiredChannelName,
+			Instance:    rs.sync.instance.ID,
+			StartedAt:   rs.startedAt,
+			Aborted:     rs.sync.aborted,
+		})
+	}
+	sort.Slice(view.Syncing, func(i, j int) bool {
+		return view.Syncing[i].StartedAt.Before(view.Syncing[j].StartedAt)
+	})
+	return view
+}
+
+// isPaused reports whether new channels must not be started, through Pause or a .freeze file
+func (s *SyncManager) isPaused() bool {
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	return s.isPausedLocked()
+}
+
+func (s *SyncManager) isPausedLocked() bool {
+	if s.control.paused {
+		return true
+	}
+	_, err := os.Stat(".freeze")
+	return err == nil
+}
+
+// shouldDrain reports whether the manager must exit once the current channels are done, through Drain or an .update file.
+// The .update file is consumed
+func (s *SyncManager) shouldDrain() bool {
+	if _, err := os.Stat(".update"); err == nil {
+		if err := os.Remove(".update"); err != nil {
+			log.Errorf("something went wrong while trying to remove the .update file: %s", errors.FullTrace(err))
+		}
+		s.Drain()
+	}
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	return s.control.draining
+}
+
+func (s *SyncManager) isVideoSkipped(videoID string) bool {
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	return s.control.skipped[videoID]
+}
+
+// popSyncNext returns the channels requested through SyncNext and clears the list
+func (s *SyncManager) popSyncNext() []string {
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	next := s.control.syncNext
+	s.control.syncNext = nil
+	return next
+}
+
+func (s *SyncManager) syncStarted(chanToSync *Sync) {
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	s.control.running[chanToSync.DbChannelData.ChannelId] = &runningSync{sync: chanToSync, startedAt: time.Now()}
+	progress.TrackChannel(*chanToSync.DbChannelData, chanToSync.instance.ID)
+}
+
+func (s *SyncManager) syncEnded(chanToSync *Sync, counted bool) {
+	s.control.mux.Lock()
+	defer s.control.mux.Unlock()
+	delete(s.control.running, chanToSync.DbChannelData.ChannelId)
+	progress.ForChannel(chanToSync.DbChannelData.ChannelId).Done()
+	if counted {
+		s.control.processed++
+	}
+}
+
+// sleep pauses the manager loop until d has passed, an operator request came in or the manager is stopped
+func (s *SyncManager) sleep(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-s.control.wake:
+	case <-s.stopGroup.Ch():
+	}
+}
+
+// abort stops the sync of the channel without stopping the other pipelines. It must be called with the control lock held
+func (s *Sync) abort() {
+	s.aborted = true
+	s.grp.Stop()
+}
+
+func (s *Sync) isAborted() bool {
+	s.Manager.control.mux.Lock()
+	defer s.Manager.control.mux.Unlock()
+	return s.aborted
+}
@@ -2,53 +2,103 @@ package manager
 
 import (
 	"fmt"
-	"os"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/lbryio/ytsync/v5/blobs_reflector"
 	"github.com/lbryio/ytsync/v5/configs"
+	"github.com/lbryio/ytsync/v5/downloader"
+	"github.com/lbryio/ytsync/v5/ip_manager"
 	"github.com/lbryio/ytsync/v5/namer"
 	"github.com/lbryio/ytsync/v5/sdk"
 	"github.com/lbryio/ytsync/v5/shared"
+	"github.com/lbryio/ytsync/v5/thumbs"
+	"github.com/lbryio/ytsync/v5/transcoder"
 	logUtils "github.com/lbryio/ytsync/v5/util"
+	"github.com/lbryio/ytsync/v5/ytapi"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
 	"github.com/lbryio/lbry.go/v2/extras/util"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// defaultStatusOutboxPath is used when status_outbox_path is not set in the configuration
+const defaultStatusOutboxPath = "status_outbox.jsonl"
+
+// defaultCollectionIndexPath is used when playlist_collections_path is not set in the configuration
+const defaultCollectionIndexPath = "playlist_collections.json"
+
 type SyncManager struct {
 	CliFlags   shared.SyncFlags
-	ApiConfig  *sdk.APIConfig
+	JobStore   sdk.JobStore
 	LbrycrdDsn string
 
 	blobsDir       string
 	channelsToSync []Sync
+	stopGroup      *stop.Group
+	ipPool         *ip_manager.IPPool
+	instances      []logUtils.Instance
+	statusOutbox   *sdk.StatusOutbox
+	collections    *sdk.CollectionIndex
+	fullScans      *sdk.FullScanLog
+	deferred       *sdk.DeferredVideos
+	// channelMetadata gets the title, description and images of the youtube channels
+	channelMetadata ytapi.ChannelMetadataProvider
+	metadataCache   *downloader.MetadataCache
+	// transcodeProfiles are the ffmpeg settings the channels can pick
+	transcodeProfiles transcoder.Profiles
+	control           *controlState
 }
 
 func NewSyncManager(cliFlags shared.SyncFlags, blobsDir string) *SyncManager {
+	instances := []logUtils.Instance{logUtils.DefaultInstance}
+	if cliFlags.ConcurrentChannels > 1 {
+		instances = make([]logUtils.Instance, 0, cliFlags.ConcurrentChannels)
+		for i := 1; i <= cliFlags.ConcurrentChannels; i++ {
+			instances = append(instances, logUtils.Instance{ID: i})
+		}
+	}
 	return &SyncManager{
 		CliFlags:   cliFlags,
 		blobsDir:   blobsDir,
 		LbrycrdDsn: configs.Configuration.LbrycrdString,
-		ApiConfig:  sdk.GetAPIsConfigs(),
+		JobStore:   sdk.GetJobStore(),
+		stopGroup:  stop.New(),
+		instances:  instances,
+		control:    newControlState(),
 	}
 }
 func (s *SyncManager) enqueueChannel(channel *shared.YoutubeChannel) {
 	s.channelsToSync = append(s.channelsToSync, Sync{
 		DbChannelData: channel,
 		Manager:       s,
-		namer:         namer.NewNamer(),
+		// every pipeline gets its own group so that it can be cancelled without affecting the others
+		grp:   s.stopGroup.Child(),
+		namer: namer.NewNamer(),
 		hardVideoFailure: hardVideoFailure{
 			lock: &sync.Mutex{},
 		},
 	})
 }
 
+// isInterrupted reports whether the whole manager was asked to stop
+func (s *SyncManager) isInterrupted() bool {
+	select {
+	case <-s.stopGroup.Ch():
+		return true
+	default:
+		return false
+	}
+}
+
+// isConcurrent reports whether channels are synced by more than one pipeline at the same time
+func (s *SyncManager) isConcurrent() bool {
+	return len(s.instances) > 1
+}
+
 func (s *SyncManager) Start() error {
 	if logUtils.ShouldCleanOnStartup() {
 		err := logUtils.CleanForStartup()
@@ -56,18 +106,99 @@ func (s *SyncManager) Start() error {
 			return err
 		}
 	}
+	for _, instance := range s.instances {
+		err := instance.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	// the pool is shared by all pipelines and must outlive any single one of them
+	ipPool, err := ip_manager.GetIPPool(s.stopGroup)
+	if err != nil {
+		return err
+	}
+	s.ipPool = ipPool
+	cookies, err := downloader.CookieJarFromConfig(configs.Configuration.Cookies)
+	if err != nil {
+		return err
+	}
+	// age restricted videos can only be synced with the profiles of accounts that confirmed their age
+	cookies.SetAgeRestricted(s.CliFlags.Status == shared.StatusAgeRestricted)
+	err = cookies.Validate(s.stopGroup.Ch(), ipPool)
+	if err != nil {
+		return err
+	}
+	downloader.SetCookieJar(cookies)
+	thumbnailStore, err := thumbs.StoreFromConfig(configs.Configuration.ThumbnailStore, configs.Configuration.ThumbnailsS3Config)
+	if err != nil {
+		return err
+	}
+	thumbs.SetStore(thumbnailStore, configs.Configuration.ThumbnailStore.LegacyHosts)
+	s.channelMetadata, err = ytapi.NewChannelMetadataProvider(configs.Configuration.ChannelMetadataProviders, s.stopGroup.Ch(), ipPool)
+	if err != nil {
+		return err
+	}
+	if api, ok := s.JobStore.(*sdk.APIConfig); ok {
+		// an interrupted sync must not be kept alive by a retrying api call
+		api.BindStopGroup(s.stopGroup)
+	}
+	outboxPath := configs.Configuration.StatusOutboxPath
+	if outboxPath == "" {
+		outboxPath = defaultStatusOutboxPath
+	}
+	s.statusOutbox, err = sdk.NewStatusOutbox(outboxPath, s.JobStore)
+	if err != nil {
+		return err
+	}
+	// replay what the previous run could not send before anything reads the video statuses
+	err = s.statusOutbox.Flush()
+	if err != nil {
+		logUtils.SendErrorToSlack("could not replay the video status outbox, will try again later: %s", err.Error())
+	}
+	s.statusOutbox.Start()
+	defer func() {
+		err := s.statusOutbox.Close()
+		if err != nil {
+			logUtils.SendErrorToSlack("video status updates are left in %s for the next run: %s", outboxPath, err.Error())
+		}
+	}()
+
+	// the cache is shared by all pipelines, a video is only ever processed by one of them
+	s.metadataCache, err = downloader.MetadataCacheFromConfig(configs.Configuration.MetadataCache, s.CliFlags.RefreshMetadata)
+	if err != nil {
+		return err
+	}
+	s.metadataCache.SetAgeRestricted(s.CliFlags.Status == shared.StatusAgeRestricted)
+	s.fullScans, err = sdk.FullScanLogFromConfig(configs.Configuration.NewUploadsFeed)
+	if err != nil {
+		return err
+	}
+	s.deferred, err = sdk.DeferredVideosFromConfig(configs.Configuration.DeferredVideos)
+	if err != nil {
+		return err
+	}
+	s.transcodeProfiles, err = transcoder.ProfilesFromConfig(configs.Configuration.TranscodeProfiles)
+	if err != nil {
+		return err
+	}
+	if s.CliFlags.SyncPlaylists {
+		collectionsPath := configs.Configuration.PlaylistCollectionsPath
+		if collectionsPath == "" {
+			collectionsPath = defaultCollectionIndexPath
+		}
+		s.collections, err = sdk.NewCollectionIndex(collectionsPath)
+		if err != nil {
+			return err
+		}
+	}
 
 	var lastChannelProcessed string
 	var secondLastChannelProcessed string
 	syncCount := 0
 	for {
-		manualSuspension := false
-		if _, err := os.Stat(".freeze"); err == nil {
-			manualSuspension = true
-		}
-		if manualSuspension {
+		if s.isPaused() {
 			log.Info("Manual suspension detected. Waiting 2 minutes before checking again.")
-			time.Sleep(2 * time.Minute)
+			s.sleep(2 * time.Minute)
 			continue
 		}
 		s.channelsToSync = make([]Sync, 0, 10) // reset sync queue
@@ -78,15 +209,32 @@ func (s *SyncManager) Start() error {
 		shouldInterruptLoop := false
 
 		if s.CliFlags.IsSingleChannelSync() {
-			channels, err := s.ApiConfig.FetchChannels("", &s.CliFlags)
+			channels, err := s.JobStore.FetchChannels("", &s.CliFlags)
 			if err != nil {
 				return errors.Err(err)
 			}
 			if len(channels) != 1 {
 				return errors.Err("Expected 1 channel, %d returned", len(channels))
 			}
+			if s.CliFlags.SourceURL != "" {
+				channels[0].SourceURL = s.CliFlags.SourceURL
+			}
 			s.enqueueChannel(&channels[0])
 			shouldInterruptLoop = true
+		} else if next := s.popSyncNext(); len(next) > 0 {
+			// channels requested by an operator skip the queues
+			for _, channelID := range next {
+				flags := s.CliFlags
+				flags.ChannelID = channelID
+				channels, err := s.JobStore.FetchChannels("", &flags)
+				if err != nil {
+					logUtils.SendErrorToSlack("could not fetch channel %s requested through the admin API: %s", channelID, err.Error())
+					continue
+				}
+				for _, c := range channels {
+					s.enqueueChannel(&c)
+				}
+			}
 		} else {
 			var queuesToSync []string
 			if s.CliFlags.Status != "" {
@@ -100,8 +248,20 @@ func (s *SyncManager) Start() error {
 				queuesToSync = append(queuesToSync, s.CliFlags.SecondaryStatus)
 			}
 		queues:
-			for _, q := range queuesToSync {
-				channels, err := s.ApiConfig.FetchChannels(q, &s.CliFlags)
+			for i := 0; i < len(queuesToSync); i++ {
+				q := queuesToSync[i]
+				channels, err := s.JobStore.FetchChannels(q, &s.CliFlags)
+				if errors.Is(err, sdk.ErrAPIUnavailable) && !s.isInterrupted() {
+					logUtils.SendErrorToSlack("could not fetch the %s queue, trying again in 5 minutes: %s", q, err.Error())
+					s.sleep(5 * time.Minute)
+					if s.isInterrupted() {
+						shouldInterruptLoop = true
+						break queues
+					}
+					// fetch the same queue again, moving on would skip its channels until the next round
+					i--
+					continue queues
+				}
 				if err != nil {
 					return err
 				}
@@ -109,7 +269,8 @@ func (s *SyncManager) Start() error {
 				for _, c := range channels {
 					s.enqueueChannel(&c)
 					queueAll := q == shared.StatusFailed || q == shared.StatusSyncing
-					if !queueAll {
+					// fill every pipeline before moving on
+					if !queueAll && len(s.channelsToSync) >= len(s.instances) {
 						break queues
 					}
 				}
@@ -118,13 +279,37 @@ func (s *SyncManager) Start() error {
 		}
 		if len(s.channelsToSync) == 0 {
 			log.Infoln("No channels to sync. Pausing 5 minutes!")
-			time.Sleep(5 * time.Minute)
+			s.sleep(5 * time.Minute)
 		}
-		for _, chanToSync := range s.channelsToSync {
-			if _, err := os.Stat(".update"); err == nil {
-				if err := os.Remove(".update"); err != nil {
-					log.Errorf("something went wrong while trying to remove the .update file: %s", errors.FullTrace(err))
-				}
+
+		// each free instance sits in the channel until a pipeline picks it up
+		freeInstances := make(chan logUtils.Instance, len(s.instances))
+		for _, instance := range s.instances {
+			freeInstances <- instance
+		}
+		var pipelines sync.WaitGroup
+		var resultsMux sync.Mutex
+		var fatalErr error
+		interrupted := false
+
+		for i := range s.channelsToSync {
+			chanToSync := &s.channelsToSync[i]
+			instance := <-freeInstances
+
+			resultsMux.Lock()
+			shouldStop := fatalErr != nil || interrupted || (s.CliFlags.Limit != 0 && syncCount >= s.CliFlags.Limit)
+			resultsMux.Unlock()
+			if shouldStop {
+				freeInstances <- instance
+				shouldInterruptLoop = true
+				break
+			}
+			if s.isPaused() && i > 0 {
+				freeInstances <- instance
+				break
+			}
+			if s.shouldDrain() {
+				freeInstances <- instance
 				shouldInterruptLoop = true
 				break
 			}
@@ -132,58 +317,44 @@ func (s *SyncManager) Start() error {
 				util.SendToSlack("We just killed a sync for %s to stop looping! (%s)", chanToSync.DbChannelData.DesiredChannelName, chanToSync.DbChannelData.ChannelId)
 				stopTheLoops := errors.Err("Found channel %s running 3 times, set it to failed, and reprocess later", chanToSync.DbChannelData.DesiredChannelName)
 				chanToSync.setChannelTerminationStatus(&stopTheLoops)
+				freeInstances <- instance
 				continue
 			}
 			secondLastChannelProcessed = lastChannelProcessed
 			lastChannelProcessed = chanToSync.DbChannelData.ChannelId
-			shouldNotCount := false
-			logUtils.SendInfoToSlack("Syncing %s (%s) to LBRY! total processed channels since startup: %d", chanToSync.DbChannelData.DesiredChannelName, chanToSync.DbChannelData.ChannelId, syncCount+1)
-			err := chanToSync.FullCycle()
 
-			if err != nil {
-				if strings.Contains(err.Error(), "quotaExceeded") {
-					logUtils.SleepUntilQuotaReset()
-				}
-				fatalErrors := []string{
-					"default_wallet already exists",
-					"WALLET HAS NOT BEEN MOVED TO THE WALLET BACKUP DIR",
-					"NotEnoughFunds",
-					"no space left on device",
-					"there was a problem uploading the wallet",
-					"the channel in the wallet is different than the channel in the database",
-					"this channel does not belong to this wallet!",
-					"You already have a stream claim published under the name",
-				}
+			chanToSync.instance = instance
+			resultsMux.Lock()
+			logUtils.SendInfoToSlack("Syncing %s (%s) to LBRY on %s! total processed channels since startup: %d", chanToSync.DbChannelData.DesiredChannelName, chanToSync.DbChannelData.ChannelId, instance, syncCount+1)
+			resultsMux.Unlock()
+			s.syncStarted(chanToSync)
+			pipelines.Add(1)
+			go func() {
+				defer pipelines.Done()
+				defer func() { freeInstances <- instance }()
+				counted, err := s.syncChannel(chanToSync)
+				s.syncEnded(chanToSync, counted)
 
-				if util.SubstringInSlice(err.Error(), fatalErrors) {
-					return errors.Prefix("@Nikooo777 this requires manual intervention! Exiting...", err)
+				resultsMux.Lock()
+				defer resultsMux.Unlock()
+				if err != nil && fatalErr == nil {
+					fatalErr = err
 				}
-				shouldNotCount = strings.Contains(err.Error(), "this youtube channel is being managed by another server")
-				if !shouldNotCount {
-					logUtils.SendInfoToSlack("A non fatal error was reported by the sync process.\n%s", errors.FullTrace(err))
+				if counted {
+					syncCount++
 				}
-			}
-			err = logUtils.CleanupMetadata()
-			if err != nil {
-				log.Errorf("something went wrong while trying to clear out the video metadata directory: %s", errors.FullTrace(err))
-			}
-			err = blobs_reflector.ReflectAndClean()
-			if err != nil {
-				return errors.Prefix("@Nikooo777 something went wrong while reflecting blobs", err)
-			}
-			logUtils.SendInfoToSlack("%s (%s) reached an end. Total processed channels since startup: %d", chanToSync.DbChannelData.DesiredChannelName, chanToSync.DbChannelData.ChannelId, syncCount+1)
-			if !shouldNotCount {
-				syncCount++
-			}
-
-			if _, err := os.Stat(".freeze"); err == nil {
-				break
-			}
-
-			if chanToSync.IsInterrupted() || (s.CliFlags.Limit != 0 && syncCount >= s.CliFlags.Limit) {
-				shouldInterruptLoop = true
-				break
-			}
+				logUtils.SendInfoToSlack("%s (%s) reached an end. Total processed channels since startup: %d", chanToSync.DbChannelData.DesiredChannelName, chanToSync.DbChannelData.ChannelId, syncCount)
+				if chanToSync.IsInterrupted() && !chanToSync.isAborted() {
+					interrupted = true
+				}
+			}()
+		}
+		pipelines.Wait()
+		if fatalErr != nil {
+			return fatalErr
+		}
+		if interrupted || s.shouldDrain() || (s.CliFlags.Limit != 0 && syncCount >= s.CliFlags.Limit) {
+			shouldInterruptLoop = true
 		}
 		if shouldInterruptLoop || s.CliFlags.SingleRun {
 			break
@@ -192,15 +363,44 @@ func (s *SyncManager) Start() error {
 	return nil
 }
 
-func (s *SyncManager) checkUsedSpace() error {
-	usedPctile, err := GetUsedSpace(logUtils.GetBlobsDir())
+// syncChannel runs a full cycle for a channel on the instance assigned to it and cleans the instance up afterward.
+// It reports whether the run counts towards --limit and returns an error only when the whole manager must stop.
+func (s *SyncManager) syncChannel(chanToSync *Sync) (bool, error) {
+	shouldNotCount := false
+	err := shared.Classify(chanToSync.FullCycle())
 	if err != nil {
-		return errors.Err(err)
+		if errors.Is(err, shared.ErrQuotaExceeded) {
+			logUtils.SleepUntilQuotaReset()
+		}
+		if errors.Is(err, shared.ErrNeedsManualIntervention) {
+			return false, errors.Prefix("@Nikooo777 this requires manual intervention! Exiting...", err)
+		}
+		shouldNotCount = errors.Is(err, sdk.ErrChannelManagedElsewhere) || errors.Is(err, sdk.ErrAPIUnavailable)
+		if !shouldNotCount {
+			logUtils.SendInfoToSlack("A non fatal error was reported by the sync process.\n%s", errors.FullTrace(err))
+		}
 	}
-	if usedPctile >= 0.90 && !s.CliFlags.SkipSpaceCheck {
-		return errors.Err(fmt.Sprintf("more than 90%% of the space has been used. use --skip-space-check to ignore. Used: %.1f%%", usedPctile*100))
+	err = blobs_reflector.ReflectAndClean(chanToSync.instance)
+	if err != nil {
+		return false, errors.Prefix("@Nikooo777 something went wrong while reflecting blobs", err)
+	}
+	return !shouldNotCount, nil
+}
+
+// errNotEnoughSpace is returned when the disk holding the blobs is too full to keep syncing
+var errNotEnoughSpace = shared.NewClassifiedError(shared.CategoryFatalHost, errors.Base("more than 90%% of the space has been used. use --skip-space-check to ignore"))
+
+func (s *SyncManager) checkUsedSpace() error {
+	for _, instance := range s.instances {
+		usedPctile, err := GetUsedSpace(instance.GetBlobsDir())
+		if err != nil {
+			return errors.Prefix(instance.String(), err)
+		}
+		if usedPctile >= 0.90 && !s.CliFlags.SkipSpaceCheck {
+			return errors.Prefix(fmt.Sprintf("%s used: %.1f%%", instance, usedPctile*100), errNotEnoughSpace)
+		}
+		log.Infof("disk usage (%s): %.1f%%", instance, usedPctile*100)
 	}
-	log.Infof("disk usage: %.1f%%", usedPctile*100)
 	return nil
 }
 
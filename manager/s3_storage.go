@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/lbryio/ytsync/v5/configs"
+	"github.com/lbryio/ytsync/v5/shared"
 	"github.com/lbryio/ytsync/v5/util"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
@@ -172,43 +173,28 @@ func (s *Sync) downloadBlockchainDB() error {
 	return nil
 }
 
+// errDefaultWalletExists means that a previous sync left its wallet behind: it must be moved away by hand
+var errDefaultWalletExists = shared.NewClassifiedError(shared.CategoryNeedsManualIntervention, errors.Base("default_wallet already exists"))
+
 func (s *Sync) getWalletPaths() (defaultWallet, tempWallet string, key *string, err error) {
-	defaultWallet = os.Getenv("HOME") + "/.lbryum/wallets/default_wallet"
-	tempWallet = os.Getenv("HOME") + "/.lbryum/wallets/tmp_wallet"
+	defaultWallet = s.instance.GetDefaultWalletPath()
+	tempWallet = filepath.Join(filepath.Dir(defaultWallet), "tmp_wallet")
 	key = aws.String("/wallets/" + s.DbChannelData.ChannelId)
 	if util.IsRegTest() {
-		defaultWallet = os.Getenv("HOME") + "/.lbryum_regtest/wallets/default_wallet"
-		tempWallet = os.Getenv("HOME") + "/.lbryum_regtest/wallets/tmp_wallet"
 		key = aws.String("/regtest/" + s.DbChannelData.ChannelId)
 	}
 
-	lbryumDir := os.Getenv("LBRYUM_DIR")
-	if lbryumDir != "" {
-		defaultWallet = lbryumDir + "/wallets/default_wallet"
-		tempWallet = lbryumDir + "/wallets/tmp_wallet"
-	}
-
 	if _, err := os.Stat(defaultWallet); !os.IsNotExist(err) {
-		return "", "", nil, errors.Err("default_wallet already exists")
+		return "", "", nil, errors.Err(errDefaultWalletExists)
 	}
 	return
 }
 
 func (s *Sync) getBlockchainDBPaths() (defaultDB, tempDB string, key *string, err error) {
-	lbryumDir := os.Getenv("LBRYUM_DIR")
-	if lbryumDir == "" {
-		if util.IsRegTest() {
-			lbryumDir = os.Getenv("HOME") + "/.lbryum_regtest"
-		} else {
-			lbryumDir = os.Getenv("HOME") + "/.lbryum"
-		}
-	}
-	defaultDB = lbryumDir + "/lbc_mainnet/blockchain.db"
-	tempDB = lbryumDir + "/lbc_mainnet/tmp_blockchain.tar"
+	defaultDB = s.instance.GetBlockchainDBPath()
+	tempDB = filepath.Join(filepath.Dir(defaultDB), "tmp_blockchain.tar")
 	key = aws.String("/blockchain_dbs/" + s.DbChannelData.ChannelId + ".tar")
 	if util.IsRegTest() {
-		defaultDB = lbryumDir + "/lbc_regtest/blockchain.db"
-		tempDB = lbryumDir + "/lbc_regtest/tmp_blockchain.tar"
 		key = aws.String("/regtest_dbs/" + s.DbChannelData.ChannelId + ".tar")
 	}
 	return
@@ -216,7 +202,7 @@ func (s *Sync) getBlockchainDBPaths() (defaultDB, tempDB string, key *string, er
 
 func (s *Sync) uploadWallet() error {
 	log.Println("uploading wallet to S3...")
-	defaultWalletDir := util.GetDefaultWalletPath()
+	defaultWalletDir := s.instance.GetDefaultWalletPath()
 	key := aws.String("/wallets/" + s.DbChannelData.ChannelId)
 	if util.IsRegTest() {
 		key = aws.String("/regtest/" + s.DbChannelData.ChannelId)
@@ -10,11 +10,8 @@ import (
 	"github.com/lbryio/lbry.go/v2/extras/errors"
 	"github.com/lbryio/lbry.go/v2/extras/jsonrpc"
 	"github.com/lbryio/lbry.go/v2/extras/util"
-	"github.com/lbryio/ytsync/v5/ip_manager"
 	"github.com/lbryio/ytsync/v5/shared"
-	"github.com/lbryio/ytsync/v5/timing"
 	logUtils "github.com/lbryio/ytsync/v5/util"
-	"github.com/lbryio/ytsync/v5/ytapi"
 
 	"github.com/lbryio/ytsync/v5/tags_manager"
 	"github.com/lbryio/ytsync/v5/thumbs"
@@ -54,7 +51,7 @@ func (s *Sync) enableAddressReuse() error {
 func (s *Sync) walletSetup() error {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("walletSetup").Add(time.Since(start))
+		s.timings.TimedComponent("walletSetup").Add(time.Since(start))
 	}(start)
 	//prevent unnecessary concurrent execution and publishing while refilling/reallocating UTXOs
 	s.walletMux.Lock()
@@ -189,7 +186,7 @@ func (s *Sync) walletSetup() error {
 func (s *Sync) getDefaultAccount() (string, error) {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("getDefaultAccount").Add(time.Since(start))
+		s.timings.TimedComponent("getDefaultAccount").Add(time.Since(start))
 	}(start)
 	if s.defaultAccountID == "" {
 		accountsResponse, err := s.daemon.AccountList(1, 50)
@@ -219,7 +216,7 @@ func (s *Sync) getDefaultAccount() (string, error) {
 func (s *Sync) ensureEnoughUTXOs() error {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("ensureEnoughUTXOs").Add(time.Since(start))
+		s.timings.TimedComponent("ensureEnoughUTXOs").Add(time.Since(start))
 	}(start)
 	defaultAccount, err := s.getDefaultAccount()
 	if err != nil {
@@ -309,7 +306,7 @@ func (s *Sync) ensureEnoughUTXOs() error {
 }
 
 func (s *Sync) waitForNewBlock() error {
-	defer func(start time.Time) { timing.TimedComponent("waitForNewBlock").Add(time.Since(start)) }(time.Now())
+	defer func(start time.Time) { s.timings.TimedComponent("waitForNewBlock").Add(time.Since(start)) }(time.Now())
 
 	log.Printf("regtest: %t, docker: %t", logUtils.IsRegTest(), logUtils.IsUsingDocker())
 	status, err := s.daemon.Status()
@@ -364,7 +361,7 @@ func (s *Sync) GenerateRegtestBlock() error {
 }
 
 func (s *Sync) ensureChannelOwnership() error {
-	defer func(start time.Time) { timing.TimedComponent("ensureChannelOwnership").Add(time.Since(start)) }(time.Now())
+	defer func(start time.Time) { s.timings.TimedComponent("ensureChannelOwnership").Add(time.Since(start)) }(time.Now())
 
 	if s.DbChannelData.DesiredChannelName == "" {
 		return errors.Err("no channel name set")
@@ -430,39 +427,19 @@ func (s *Sync) ensureChannelOwnership() error {
 			return err
 		}
 	}
-	ipPool, err := ip_manager.GetIPPool(s.grp)
+	channelInfo, err := s.Manager.channelMetadata.ChannelMetadata(s.DbChannelData.ChannelId)
 	if err != nil {
 		return err
 	}
-	channelInfo, err := ytapi.ChannelInfo(s.DbChannelData.ChannelId, 0, ipPool)
-	if err != nil {
-		if strings.Contains(err.Error(), "invalid character 'e' looking for beginning of value") {
-			logUtils.SendInfoToSlack("failed to get channel data for %s. Waiting 1 minute to retry", s.DbChannelData.ChannelId)
-			time.Sleep(1 * time.Minute)
-			channelInfo, err = ytapi.ChannelInfo(s.DbChannelData.ChannelId, 1, ipPool)
-			if err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
-	}
-
-	if len(channelInfo.Header.C4TabbedHeaderRenderer.Avatar.Thumbnails) == 0 {
-		return errors.Err("no thumbnails found for channel")
-	}
 
-	thumbnail := channelInfo.Header.C4TabbedHeaderRenderer.Avatar.Thumbnails[len(channelInfo.Header.C4TabbedHeaderRenderer.Avatar.Thumbnails)-1].URL
-	thumbnailURL, err := thumbs.MirrorThumbnail(thumbnail, s.DbChannelData.ChannelId)
+	thumbnailURL, _, err := thumbs.MirrorThumbnail(channelInfo.AvatarURL, s.DbChannelData.ChannelId)
 	if err != nil {
 		return err
 	}
 
 	var bannerURL *string
-	if channelInfo.Header.C4TabbedHeaderRenderer.Banner.Thumbnails != nil {
-		bURL, err := thumbs.MirrorThumbnail(channelInfo.Header.C4TabbedHeaderRenderer.Banner.Thumbnails[len(channelInfo.Header.C4TabbedHeaderRenderer.Banner.Thumbnails)-1].URL,
-			"banner-"+s.DbChannelData.ChannelId,
-		)
+	if channelInfo.BannerURL != "" {
+		bURL, _, err := thumbs.MirrorThumbnail(channelInfo.BannerURL, "banner-"+s.DbChannelData.ChannelId)
 		if err != nil {
 			return err
 		}
@@ -475,14 +452,14 @@ func (s *Sync) ensureChannelOwnership() error {
 	}
 
 	var locations []jsonrpc.Location = nil
-	if channelInfo.Topbar.DesktopTopbarRenderer.CountryCode != "" {
-		locations = []jsonrpc.Location{{Country: &channelInfo.Topbar.DesktopTopbarRenderer.CountryCode}}
+	if channelInfo.Country != "" {
+		locations = []jsonrpc.Location{{Country: &channelInfo.Country}}
 	}
 	var c *jsonrpc.TransactionSummary
 	var recoveredChannelClaimID string
 	claimCreateOptions := jsonrpc.ClaimCreateOptions{
-		Title:        &channelInfo.Microformat.MicroformatDataRenderer.Title,
-		Description:  &channelInfo.Metadata.ChannelMetadataRenderer.Description,
+		Title:        &channelInfo.Title,
+		Description:  &channelInfo.Description,
 		Tags:         tags_manager.GetTagsForChannel(s.DbChannelData.ChannelId),
 		Languages:    languages,
 		Locations:    locations,
@@ -536,7 +513,7 @@ func (s *Sync) ensureChannelOwnership() error {
 		}
 		s.DbChannelData.ChannelClaimID = c.Outputs[0].ClaimID
 	}
-	return s.Manager.ApiConfig.SetChannelClaimID(s.DbChannelData.ChannelId, s.DbChannelData.ChannelClaimID)
+	return s.Manager.JobStore.SetChannelClaimID(s.DbChannelData.ChannelId, s.DbChannelData.ChannelClaimID)
 }
 
 // getChannelClaimIDForTimedOutCreation is a raw function that returns the only channel that exists in the wallet
@@ -562,7 +539,7 @@ func (s *Sync) getChannelClaimIDForTimedOutCreation() (string, error) {
 func (s *Sync) addCredits(amountToAdd float64) error {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("addCredits").Add(time.Since(start))
+		s.timings.TimedComponent("addCredits").Add(time.Since(start))
 	}(start)
 	log.Printf("Adding %f credits", amountToAdd)
 	lbrycrdd, err := logUtils.GetLbrycrdClient(s.Manager.LbrycrdDsn)
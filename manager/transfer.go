@@ -11,7 +11,6 @@ import (
 	"github.com/lbryio/lbry.go/v2/extras/stop"
 	"github.com/lbryio/lbry.go/v2/extras/util"
 	"github.com/lbryio/ytsync/v5/shared"
-	"github.com/lbryio/ytsync/v5/timing"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -19,7 +18,7 @@ import (
 func waitConfirmations(s *Sync) error {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("waitConfirmations").Add(time.Since(start))
+		s.timings.TimedComponent("waitConfirmations").Add(time.Since(start))
 	}(start)
 	defaultAccount, err := s.getDefaultAccount()
 	if err != nil {
@@ -60,7 +59,7 @@ type abandonResponse struct {
 func abandonSupports(s *Sync) (float64, error) {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("abandonSupports").Add(time.Since(start))
+		s.timings.TimedComponent("abandonSupports").Add(time.Since(start))
 	}(start)
 	totalPages := uint64(1)
 	var allSupports []jsonrpc.Claim
@@ -198,7 +197,7 @@ type updateInfo struct {
 func transferVideos(s *Sync) error {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("transferVideos").Add(time.Since(start))
+		s.timings.TimedComponent("transferVideos").Add(time.Since(start))
 	}(start)
 	cleanTransfer := true
 
@@ -290,7 +289,7 @@ func transferVideos(s *Sync) error {
 func (s *Sync) streamUpdate(ui *updateInfo) error {
 	start := time.Now()
 	result, updateError := s.daemon.StreamUpdate(ui.ClaimID, *ui.streamUpdateOptions)
-	timing.TimedComponent("transferStreamUpdate").Add(time.Since(start))
+	s.timings.TimedComponent("transferStreamUpdate").Add(time.Since(start))
 	if updateError != nil {
 		ui.videoStatus.FailureReason = updateError.Error()
 		ui.videoStatus.Status = shared.VideoStatusTransferFailed
@@ -299,7 +298,7 @@ func (s *Sync) streamUpdate(ui *updateInfo) error {
 		ui.videoStatus.IsTransferred = util.PtrToBool(len(result.Outputs) != 0)
 	}
 	log.Infof("TRANSFERRED %t", *ui.videoStatus.IsTransferred)
-	statusErr := s.Manager.ApiConfig.MarkVideoStatus(*ui.videoStatus)
+	statusErr := s.Manager.statusOutbox.MarkVideoStatus(*ui.videoStatus)
 	if statusErr != nil {
 		return errors.Prefix(statusErr.Error(), updateError)
 	}
@@ -309,7 +308,7 @@ func (s *Sync) streamUpdate(ui *updateInfo) error {
 func transferChannel(s *Sync) error {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("transferChannel").Add(time.Since(start))
+		s.timings.TimedComponent("transferChannel").Add(time.Since(start))
 	}(start)
 	account, err := s.getDefaultAccount()
 	if err != nil {
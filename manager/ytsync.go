@@ -15,11 +15,13 @@ import (
 	"github.com/lbryio/ytsync/v5/configs"
 	"github.com/lbryio/ytsync/v5/ip_manager"
 	"github.com/lbryio/ytsync/v5/namer"
+	"github.com/lbryio/ytsync/v5/progress"
 	"github.com/lbryio/ytsync/v5/sdk"
 	"github.com/lbryio/ytsync/v5/shared"
 	"github.com/lbryio/ytsync/v5/sources"
 	"github.com/lbryio/ytsync/v5/thumbs"
 	"github.com/lbryio/ytsync/v5/timing"
+	"github.com/lbryio/ytsync/v5/transcoder"
 	logUtils "github.com/lbryio/ytsync/v5/util"
 	"github.com/lbryio/ytsync/v5/ytapi"
 	probing "github.com/prometheus-community/pro-bing"
@@ -45,6 +47,8 @@ const (
 type Sync struct {
 	DbChannelData *shared.YoutubeChannel
 	Manager       *SyncManager
+	// instance is the daemon and set of directories this channel is synced with
+	instance logUtils.Instance
 
 	daemon           *jsonrpc.Client
 	videoDirectory   string
@@ -56,10 +60,16 @@ type Sync struct {
 	queue            chan ytapi.Video
 	defaultAccountID string
 	hardVideoFailure hardVideoFailure
+	// transcodeProfile is the transcode profile picked by the channel, nil when its videos aren't transcoded
+	transcodeProfile *transcoder.Profile
+	// timings are the component timings of this run, kept apart from the ones of the other pipelines
+	timings *timing.Timings
 
 	state         runState
 	progressBarWg *sync.WaitGroup
 	progressBar   *mpb.Progress
+	// aborted is set when an operator aborts the sync, it is guarded by the control lock of the manager
+	aborted bool
 }
 
 type runState struct {
@@ -67,22 +77,24 @@ type runState struct {
 	walletDownloaded       bool
 	vpnStarted             bool
 	newWalletCreated       bool
+	// blockchainDbUploadFailed is set when the blockchain.db couldn't be uploaded after the sync had already failed
+	blockchainDbUploadFailed bool
 }
 
 type hardVideoFailure struct {
-	lock          *sync.Mutex
-	failed        bool
-	failureReason string
+	lock    *sync.Mutex
+	failed  bool
+	failure error
 }
 
-func (hv *hardVideoFailure) flagFailure(reason string) {
+func (hv *hardVideoFailure) flagFailure(failure error) {
 	hv.lock.Lock()
 	defer hv.lock.Unlock()
 	if hv.failed {
 		return
 	}
 	hv.failed = true
-	hv.failureReason = reason
+	hv.failure = failure
 }
 
 func (s *Sync) AppendSyncedVideo(videoID string, published bool, failureReason string, claimName string, claimID string, metadataVersion int8, size int64) {
@@ -110,10 +122,14 @@ func (s *Sync) IsInterrupted() bool {
 }
 
 func (s *Sync) setStatusSyncing() error {
-	syncedVideos, claimNames, err := s.Manager.ApiConfig.SetChannelStatus(s.DbChannelData.ChannelId, shared.StatusSyncing, "", nil)
+	syncedVideos, claimNames, err := s.Manager.JobStore.SetChannelStatus(s.DbChannelData.ChannelId, shared.StatusSyncing, "", nil)
 	if err != nil {
 		return err
 	}
+	// updates still waiting in the outbox are newer than what the job store knows
+	for _, status := range s.Manager.statusOutbox.Pending(s.DbChannelData.ChannelId) {
+		applyVideoStatus(syncedVideos, claimNames, status)
+	}
 	s.syncedVideosMux.Lock()
 	s.syncedVideos = syncedVideos
 	s.namer.SetNames(claimNames)
@@ -121,7 +137,36 @@ func (s *Sync) setStatusSyncing() error {
 	return nil
 }
 
-var stopGroup = stop.New()
+// applyVideoStatus updates the synced videos of a channel the same way the job store would for the given status
+func applyVideoStatus(syncedVideos map[string]sdk.SyncedVideo, claimNames map[string]bool, status shared.VideoStatus) {
+	sv := syncedVideos[status.VideoID]
+	sv.VideoID = status.VideoID
+	sv.FailureReason = status.FailureReason
+	switch status.Status {
+	case sdk.VideoStatusPublished:
+		sv.Published = true
+	case sdk.VideoStatusFailed, shared.VideoStatusUnpublished:
+		sv.Published = false
+	}
+	if status.ClaimID != "" {
+		sv.ClaimID = status.ClaimID
+	}
+	if status.ClaimName != "" {
+		sv.ClaimName = status.ClaimName
+		claimNames[sv.ClaimName] = sv.Published
+	}
+	if status.MetaDataVersion > 0 {
+		sv.MetadataVersion = int8(status.MetaDataVersion)
+	}
+	if status.Size != nil {
+		sv.Size = *status.Size
+	}
+	if status.IsTransferred != nil {
+		sv.Transferred = *status.IsTransferred
+	}
+	syncedVideos[status.VideoID] = sv
+}
+
 var running = false
 
 func (s *Sync) CheckVpn(vpnStopper *stop.Group) {
@@ -182,10 +227,10 @@ func (s *Sync) FullCycle() (e error) {
 	if os.Getenv("HOME") == "" {
 		return errors.Err("no $HOME env var found")
 	}
-	defer timing.ClearTimings()
+	s.timings = timing.NewTimings()
+	s.instance.Timings = s.timings
 	s.syncedVideosMux = &sync.RWMutex{}
 	s.walletMux = &sync.RWMutex{}
-	s.grp = stopGroup
 	s.queue = make(chan ytapi.Video)
 	interruptChan := make(chan os.Signal, 1)
 	signal.Notify(interruptChan, os.Interrupt, syscall.SIGTERM)
@@ -197,13 +242,18 @@ func (s *Sync) FullCycle() (e error) {
 		case <-interruptChan:
 			util.SendToSlack("got interrupt, shutting down")
 			log.Println("Got interrupt signal, shutting down (if publishing, will shut down after current publish)")
-			s.grp.Stop()
+			s.Manager.stopGroup.Stop()
 			time.Sleep(5 * time.Second)
 		case <-ctx.Done(): // this case is executed when the context is cancelled
 			return
 		}
 	}()
-	err := s.setStatusSyncing()
+	transcodeProfile, err := s.Manager.transcodeProfiles.Get(s.DbChannelData.TranscodeProfile)
+	if err != nil {
+		return err
+	}
+	s.transcodeProfile = transcodeProfile
+	err = s.setStatusSyncing()
 	if err != nil {
 		return err
 	}
@@ -246,8 +296,9 @@ func (s *Sync) FullCycle() (e error) {
 	}
 
 	//TODO: THIS IS A TEMPORARY WORK AROUND FOR THE STUPID IP LOCKUP BUG
-	ipPool, _ := ip_manager.GetIPPool(s.grp)
-	if ipPool != nil {
+	// other pipelines may be holding IPs right now, only release them all when running alone
+	ipPool := s.Manager.ipPool
+	if !s.Manager.isConcurrent() {
 		ipPool.ReleaseAll()
 	}
 	err = ipPool.UpdateIps()
@@ -255,14 +306,14 @@ func (s *Sync) FullCycle() (e error) {
 		return err
 	}
 
-	log.Printf("Starting daemon")
-	err = logUtils.StartDaemon()
+	log.Printf("Starting daemon (%s)", s.instance)
+	err = s.instance.StartDaemon()
 	if err != nil {
 		return err
 	}
 
 	log.Infoln("Waiting for daemon to finish starting...")
-	s.daemon = jsonrpc.NewClient(os.Getenv("LBRYNET_ADDRESS"))
+	s.daemon = jsonrpc.NewClient(s.instance.LBRYNetAddress())
 	s.daemon.SetRPCTimeout(5 * time.Minute)
 
 	err = s.waitForDaemonStart()
@@ -280,10 +331,19 @@ func (s *Sync) FullCycle() (e error) {
 		return err
 	}
 
+	// transfers only move streams, collections of channels being transferred would be left behind in this wallet
+	if s.Manager.CliFlags.SyncPlaylists && s.DbChannelData.SourceURL == "" && !s.DbChannelData.IsDeletedOnYoutube && s.DbChannelData.TransferState == shared.TransferStateNotTouched {
+		// collections are a nice to have, they never fail the channel
+		playlistsErr := s.syncPlaylists()
+		if playlistsErr != nil {
+			logUtils.SendErrorToSlack("could not sync the playlists of %s: %s", s.DbChannelData.ChannelId, errors.FullTrace(playlistsErr))
+		}
+	}
+
 	if s.shouldTransfer() {
 		err = s.processTransfers()
 	}
-	timing.Report()
+	s.timings.Report()
 	return err
 }
 
@@ -364,6 +424,15 @@ func (s *Sync) shouldTransfer() bool {
 
 func (s *Sync) setChannelTerminationStatus(e *error) {
 	var transferState *int
+	// the channel status must not get ahead of the statuses of its videos
+	err := s.Manager.statusOutbox.Flush()
+	if err != nil {
+		log.Errorf("video statuses of %s are still in the outbox: %s", s.DbChannelData.DesiredChannelName, err.Error())
+	}
+	if s.isAborted() {
+		// an aborted channel is not merely interrupted: it must leave the queues
+		*e = errors.Err(errAbortedByOperator)
+	}
 
 	if s.shouldTransfer() {
 		if *e == nil {
@@ -371,33 +440,34 @@ func (s *Sync) setChannelTerminationStatus(e *error) {
 		}
 	}
 	if *e != nil {
+		err := shared.Classify(*e)
 		//conditions for which a channel shouldn't be marked as failed
-		noFailConditions := []string{
-			"this youtube channel is being managed by another server",
-			"interrupted during daemon startup",
-			"interrupted by user",
-			"use --skip-space-check to ignore",
-			"failure uploading blockchain DB",
-			"default_wallet already exists",
-		}
-		dbWipeConditions := []string{
-			"Missing inputs",
-		}
-		if util.SubstringInSlice((*e).Error(), noFailConditions) {
+		noFailConditions := []error{
+			sdk.ErrChannelManagedElsewhere,
+			ip_manager.ErrInterruptedByUser,
+			errNotEnoughSpace,
+			errDefaultWalletExists,
+		}
+		for _, condition := range noFailConditions {
+			if errors.Is(err, condition) {
+				return
+			}
+		}
+		if s.state.blockchainDbUploadFailed {
 			return
 		}
 		channelStatus := shared.StatusFailed
-		if util.SubstringInSlice((*e).Error(), dbWipeConditions) {
+		if errors.Is(err, shared.ErrStaleWallet) {
 			channelStatus = shared.StatusWipeDb
 		}
 		failureReason := (*e).Error()
-		_, _, err := s.Manager.ApiConfig.SetChannelStatus(s.DbChannelData.ChannelId, channelStatus, failureReason, transferState)
+		_, _, err = s.Manager.JobStore.SetChannelStatus(s.DbChannelData.ChannelId, channelStatus, failureReason, transferState)
 		if err != nil {
 			msg := fmt.Sprintf("Failed setting failed state for channel %s", s.DbChannelData.DesiredChannelName)
 			*e = errors.Prefix(msg+err.Error(), *e)
 		}
 	} else if !s.IsInterrupted() {
-		_, _, err := s.Manager.ApiConfig.SetChannelStatus(s.DbChannelData.ChannelId, shared.StatusSynced, "", transferState)
+		_, _, err := s.Manager.JobStore.SetChannelStatus(s.DbChannelData.ChannelId, shared.StatusSynced, "", transferState)
 		if err != nil {
 			*e = err
 		}
@@ -408,12 +478,12 @@ func (s *Sync) waitForDaemonStart() error {
 	beginTime := time.Now()
 	hasHotRestarted := false
 	defer func(start time.Time) {
-		timing.TimedComponent("waitForDaemonStart").Add(time.Since(start))
+		s.timings.TimedComponent("waitForDaemonStart").Add(time.Since(start))
 	}(beginTime)
 	for {
 		select {
 		case <-s.grp.Ch():
-			return errors.Err("interrupted during daemon startup")
+			return errors.Prefix("interrupted during daemon startup", ip_manager.ErrInterruptedByUser)
 		default:
 			status, err := s.daemon.Status()
 			if err == nil && status.StartupStatus.Wallet && status.IsRunning {
@@ -421,7 +491,7 @@ func (s *Sync) waitForDaemonStart() error {
 			}
 			if !hasHotRestarted && time.Since(beginTime) > 2*time.Minute {
 				hasHotRestarted = true
-				err = logUtils.RestartDaemon()
+				err = s.instance.RestartDaemon()
 				if err != nil {
 					return err
 				}
@@ -451,14 +521,14 @@ func (s *Sync) performShutdownTasks(e *error) {
 	}
 
 	successfulDaemonStop := false
-	shutdownErr := logUtils.StopDaemon()
+	shutdownErr := s.instance.StopDaemon()
 	if shutdownErr != nil {
 		logShutdownError(shutdownErr)
 	}
 	// the cli will return long before the daemon effectively stops. we must observe the processes running
 	// before moving the wallet
 	waitTimeout := 8 * time.Minute
-	processDeathError := waitForDaemonProcess(waitTimeout)
+	processDeathError := waitForDaemonProcess(s.instance, waitTimeout)
 	if processDeathError != nil {
 		logShutdownError(processDeathError)
 	} else {
@@ -481,6 +551,7 @@ func (s *Sync) performShutdownTasks(e *error) {
 			if *e == nil {
 				*e = err
 			} else {
+				s.state.blockchainDbUploadFailed = true
 				*e = errors.Prefix(fmt.Sprintf("failure uploading blockchain DB: %s + original error", errors.FullTrace(err)), *e)
 			}
 		}
@@ -492,11 +563,6 @@ func logShutdownError(shutdownErr error) {
 	logUtils.SendErrorToSlack("WALLET HAS NOT BEEN MOVED TO THE WALLET BACKUP DIR")
 }
 
-var thumbnailHosts = []string{
-	"berk.ninja/thumbnails/",
-	thumbs.ThumbnailEndpoint,
-}
-
 func isYtsyncClaim(c jsonrpc.Claim, expectedChannelID string) bool {
 	if !util.InSlice(c.Type, []string{"claim", "update"}) || c.Value.GetStream() == nil {
 		return false
@@ -508,22 +574,21 @@ func isYtsyncClaim(c jsonrpc.Claim, expectedChannelID string) bool {
 	if c.SigningChannel == nil {
 		return false
 	}
-	if c.SigningChannel.ClaimID != expectedChannelID {
+	if util.InSlice(sources.SubtitlesTag, c.Value.GetTags()) {
+		// the subtitles published alongside a video, older ones reused its thumbnail
 		return false
 	}
-	for _, th := range thumbnailHosts {
-		if strings.Contains(c.Value.GetThumbnail().GetUrl(), th) {
-			return true
-		}
+	if c.SigningChannel.ClaimID != expectedChannelID {
+		return false
 	}
-	return false
+	return thumbs.IsMirroredThumbnail(c.Value.GetThumbnail().GetUrl())
 }
 
 // fixDupes abandons duplicate claims
 func (s *Sync) fixDupes(claims []jsonrpc.Claim) (bool, error) {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("fixDupes").Add(time.Since(start))
+		s.timings.TimedComponent("fixDupes").Add(time.Since(start))
 	}(start)
 	abandonedClaims := false
 	videoIDs := make(map[string]jsonrpc.Claim)
@@ -580,9 +645,9 @@ func (s *Sync) mapFromClaims(claims []jsonrpc.Claim) map[string]ytsyncClaim {
 		}
 		tn := c.Value.GetThumbnail().GetUrl()
 		videoID := tn[strings.LastIndex(tn, "/")+1:]
-		claimMetadataVersion := uint(1)
-		if strings.Contains(tn, thumbs.ThumbnailEndpoint) {
-			claimMetadataVersion = 2
+		claimMetadataVersion := uint(2)
+		if strings.Contains(tn, thumbs.LegacyV1Host) {
+			claimMetadataVersion = 1
 		}
 
 		videoIDMap[videoID] = ytsyncClaim{
@@ -649,7 +714,7 @@ func (s *Sync) updateRemoteDB(claims []jsonrpc.Claim, ownClaims []jsonrpc.Claim)
 			}
 			fixed++
 			log.Debugf("updating %s in the database", videoID)
-			err = s.Manager.ApiConfig.MarkVideoStatus(shared.VideoStatus{
+			err = s.Manager.statusOutbox.MarkVideoStatus(shared.VideoStatus{
 				ChannelID:       s.DbChannelData.ChannelId,
 				VideoID:         videoID,
 				Status:          shared.VideoStatusPublished,
@@ -704,7 +769,7 @@ func (s *Sync) updateRemoteDB(claims []jsonrpc.Claim, ownClaims []jsonrpc.Claim)
 	}
 	if s.Manager.CliFlags.RemoveDBUnpublished && len(idsToRemove) > 0 {
 		log.Infof("removing: %s", strings.Join(idsToRemove, ","))
-		err := s.Manager.ApiConfig.DeleteVideos(idsToRemove)
+		err := s.Manager.JobStore.DeleteVideos(idsToRemove)
 		if err != nil {
 			return count, fixed, len(idsToRemove), err
 		}
@@ -745,7 +810,7 @@ func (s *Sync) getClaims(defaultOnly bool) ([]jsonrpc.Claim, error) {
 func (s *Sync) checkIntegrity() error {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("checkIntegrity").Add(time.Since(start))
+		s.timings.TimedComponent("checkIntegrity").Add(time.Since(start))
 	}(start)
 	allClaims, err := s.getClaims(false)
 	if err != nil {
@@ -836,7 +901,7 @@ func (s *Sync) doSync() error {
 			return errors.Prefix("error getting channel cert", err)
 		}
 		if cert != nil {
-			err = s.Manager.ApiConfig.SetChannelCert(string(*cert), s.DbChannelData.ChannelClaimID)
+			err = s.Manager.JobStore.SetChannelCert(string(*cert), s.DbChannelData.ChannelClaimID)
 			if err != nil {
 				return errors.Prefix("error setting channel cert", err)
 			}
@@ -862,7 +927,7 @@ func (s *Sync) doSync() error {
 		return err
 	}
 	if s.hardVideoFailure.failed {
-		return errors.Err(s.hardVideoFailure.failureReason)
+		return errors.Err(s.hardVideoFailure.failure)
 	}
 	return nil
 }
@@ -889,29 +954,44 @@ func (s *Sync) startWorker(workerNum int) {
 			return
 		}
 
+		if s.Manager.isVideoSkipped(v.ID()) {
+			log.Infof("Skipping %s as requested by an operator", v.ID())
+			continue
+		}
+
 		log.Println("================================================================================")
 
+		videoProgress := progress.TrackVideo(s.DbChannelData.ChannelId, v.ID(), workerNum)
 		tryCount := 0
 		for {
 			select { // check again inside the loop so this dies faster
 			case <-s.grp.Ch():
+				videoProgress.Done()
 				log.Printf("Stopping worker %d", workerNum)
 				return
 			default:
 			}
 			tryCount++
+			videoProgress.SetAttempt(tryCount)
 
 			err := s.processVideo(v)
-			if err != nil {
+			if err != nil && shared.IsDeferred(err) {
+				s.deferVideo(v.Item(), err)
+				break
+			}
+			if err == nil {
+				s.forgetDeferredVideo(v.ID())
+			} else {
+				err = shared.Classify(err)
 				logUtils.SendErrorToSlack("error processing video %s: %s", v.ID(), err.Error())
-				shouldRetry := s.Manager.CliFlags.MaxTries > 1 && !util.SubstringInSlice(err.Error(), shared.ErrorsNoRetry) && tryCount < s.Manager.CliFlags.MaxTries
-				if strings.Contains(strings.ToLower(err.Error()), "interrupted by user") {
+				shouldRetry := s.Manager.CliFlags.MaxTries > 1 && shared.IsRetryable(err) && tryCount < s.Manager.CliFlags.MaxTries
+				if errors.Is(err, ip_manager.ErrInterruptedByUser) {
 					s.grp.Stop()
-				} else if util.SubstringInSlice(err.Error(), shared.FatalErrors) {
-					s.hardVideoFailure.flagFailure(err.Error())
+				} else if shared.IsFatal(err) {
+					s.hardVideoFailure.flagFailure(err)
 					s.grp.Stop()
 				} else if shouldRetry {
-					if util.SubstringInSlice(err.Error(), shared.BlockchainErrors) {
+					if errors.Is(err, shared.ErrBlockchain) {
 						log.Println("waiting for a block before retrying")
 						err := s.waitForNewBlock()
 						if err != nil {
@@ -919,7 +999,7 @@ func (s *Sync) startWorker(workerNum int) {
 							logUtils.SendErrorToSlack("something went wrong while waiting for a block: %s", errors.FullTrace(err))
 							break
 						}
-					} else if util.SubstringInSlice(err.Error(), shared.WalletErrors) {
+					} else if errors.Is(err, shared.ErrWallet) {
 						log.Println("checking funds and UTXOs before retrying...")
 						err := s.walletSetup()
 						if err != nil {
@@ -927,7 +1007,7 @@ func (s *Sync) startWorker(workerNum int) {
 							logUtils.SendErrorToSlack("failed to setup the wallet for a refill: %s", errors.FullTrace(err))
 							break
 						}
-					} else if strings.Contains(err.Error(), "Error in daemon: 'str' object has no attribute 'get'") {
+					} else if errors.Is(err, shared.ErrTransient) {
 						time.Sleep(5 * time.Second)
 					}
 					log.Println("Retrying")
@@ -951,13 +1031,17 @@ func (s *Sync) startWorker(workerNum int) {
 						existingClaimSize = existingClaim.Size
 					}
 				}
+				if shared.IsPermanentFailure(err.Error()) {
+					// a transient failure keeps the video deferred so that it is queued again once it's due
+					s.forgetDeferredVideo(v.ID())
+				}
 				videoStatus := shared.VideoStatusFailed
 				if strings.Contains(err.Error(), "upgrade failed") {
 					videoStatus = shared.VideoStatusUpgradeFailed
 				} else {
 					s.AppendSyncedVideo(v.ID(), false, err.Error(), existingClaimName, existingClaimID, 0, existingClaimSize)
 				}
-				err = s.Manager.ApiConfig.MarkVideoStatus(shared.VideoStatus{
+				err = s.Manager.statusOutbox.MarkVideoStatus(shared.VideoStatus{
 					ChannelID:     s.DbChannelData.ChannelId,
 					VideoID:       v.ID(),
 					Status:        videoStatus,
@@ -972,28 +1056,65 @@ func (s *Sync) startWorker(workerNum int) {
 			}
 			break
 		}
+		videoProgress.Done()
 	}
 }
 
-func (s *Sync) enqueueYoutubeVideos() error {
-	defer func(start time.Time) { timing.TimedComponent("enqueueYoutubeVideos").Add(time.Since(start)) }(time.Now())
+// deferVideo puts aside a video that isn't ready yet, it is queued again by the first cycle after it's due.
+// It isn't marked as failed
+func (s *Sync) deferVideo(item sources.Item, reason error) {
+	videoID := item.ID
+	// the cached metadata would still describe the video as not ready when it is queued again
+	s.Manager.metadataCache.Invalidate(videoID)
+	now := time.Now()
+	notBefore, err := s.Manager.deferred.Defer(s.DbChannelData.ChannelId, videoID, item.URL, reason.Error(), shared.ReadyAt(reason, now), now)
+	if err != nil {
+		logUtils.SendErrorToSlack("could not defer video %s: %s", videoID, err.Error())
+	}
+	log.Infof("%s isn't ready yet, deferring it until %s: %s", videoID, notBefore.Format(time.RFC3339), reason.Error())
+	progress.ForChannel(s.DbChannelData.ChannelId).SetDeferredVideos(len(s.Manager.deferred.Pending(s.DbChannelData.ChannelId)))
+}
 
-	ipPool, err := ip_manager.GetIPPool(s.grp)
+// forgetDeferredVideo stops deferring a video that was synced or failed for good
+func (s *Sync) forgetDeferredVideo(videoID string) {
+	err := s.Manager.deferred.Remove(s.DbChannelData.ChannelId, videoID)
 	if err != nil {
-		return err
+		logUtils.SendErrorToSlack("could not remove video %s from the deferred videos: %s", videoID, err.Error())
 	}
+	progress.ForChannel(s.DbChannelData.ChannelId).SetDeferredVideos(len(s.Manager.deferred.Pending(s.DbChannelData.ChannelId)))
+}
+
+func (s *Sync) enqueueYoutubeVideos() error {
+	defer func(start time.Time) { s.timings.TimedComponent("enqueueYoutubeVideos").Add(time.Since(start)) }(time.Now())
 
-	videos, err := ytapi.GetVideosToSync(s.DbChannelData.ChannelId, s.syncedVideos, s.Manager.CliFlags.QuickSync, s.Manager.CliFlags.VideosToSync(s.DbChannelData.TotalSubscribers), ytapi.VideoParams{
-		VideoDir: s.videoDirectory,
-		Stopper:  s.grp,
-		IPPool:   ipPool,
-	}, s.DbChannelData.LastUploadedVideo)
+	source := sources.NewSource(s.DbChannelData, sources.SourceParams{
+		VideoDir:      s.videoDirectory,
+		MetadataCache: s.Manager.metadataCache,
+		Stopper:       s.grp,
+		IPPool:        s.Manager.ipPool,
+	})
+	lastUploadedVideo := s.DbChannelData.LastUploadedVideo
+	if s.DbChannelData.SourceURL != "" {
+		// the last uploaded video is a youtube video
+		lastUploadedVideo = ""
+	}
+	useFeed := !s.Manager.CliFlags.FullScan && !s.Manager.fullScans.FullScanDue(s.DbChannelData.ChannelId, time.Now())
+	videos, fullScan, err := ytapi.GetVideosToSync(source, s.DbChannelData.ChannelId, s.syncedVideos, s.Manager.CliFlags.QuickSync, s.Manager.CliFlags.VideosToSync(s.DbChannelData.TotalSubscribers), s.grp.Ch(), s.Manager.ipPool, lastUploadedVideo, useFeed, s.Manager.deferred, s.Manager.CliFlags.Status == shared.StatusAgeRestricted, s.Manager.statusOutbox)
 	if err != nil {
 		return err
 	}
+	if fullScan {
+		err = s.Manager.fullScans.RecordFullScan(s.DbChannelData.ChannelId, time.Now())
+		if err != nil {
+			logUtils.SendErrorToSlack("could not record the full scan of %s: %s", s.DbChannelData.ChannelId, err.Error())
+		}
+	}
 
+	channelProgress := progress.ForChannel(s.DbChannelData.ChannelId)
+	channelProgress.SetQueueLength(len(videos))
+	channelProgress.SetDeferredVideos(len(s.Manager.deferred.Pending(s.DbChannelData.ChannelId)))
 Enqueue:
-	for _, v := range videos {
+	for i, v := range videos {
 		select {
 		case <-s.grp.Ch():
 			break Enqueue
@@ -1002,6 +1123,7 @@ Enqueue:
 
 		select {
 		case s.queue <- v:
+			channelProgress.SetQueueLength(len(videos) - i - 1)
 		case <-s.grp.Ch():
 			break Enqueue
 		}
@@ -1035,8 +1157,7 @@ func (s *Sync) processVideo(v ytapi.Video) (err error) {
 	alreadyPublished := ok && sv.Published
 	videoRequiresUpgrade := ok && s.Manager.CliFlags.UpgradeMetadata && sv.MetadataVersion < newMetadataVersion
 
-	neverRetryFailures := shared.NeverRetryFailures
-	if ok && !sv.Published && util.SubstringInSlice(sv.FailureReason, neverRetryFailures) {
+	if ok && !sv.Published && shared.ShouldSkipFailedVideo(sv.FailureReason, s.Manager.CliFlags.Status == shared.StatusAgeRestricted) {
 		log.Println(v.ID() + " can't ever be published")
 		return nil
 	}
@@ -1071,6 +1192,9 @@ func (s *Sync) processVideo(v ytapi.Video) (err error) {
 		MaxVideoLength: time.Duration(s.DbChannelData.LengthLimit) * time.Minute,
 		Fee:            s.DbChannelData.Fee,
 		DefaultAccount: da,
+		Subtitles:      s.DbChannelData.Subtitles,
+		Transcode:      s.transcodeProfile,
+		Timings:        s.timings,
 	}
 
 	summary, err := v.Sync(s.daemon, sp, &sv, videoRequiresUpgrade, s.walletMux, s.progressBarWg, s.progressBar)
@@ -1078,8 +1202,11 @@ func (s *Sync) processVideo(v ytapi.Video) (err error) {
 		return err
 	}
 
+	for language, claimID := range summary.SubtitleClaimIDs {
+		log.Infof("%s: published the %s subtitles as %s", v.ID(), language, claimID)
+	}
 	s.AppendSyncedVideo(v.ID(), true, "", summary.ClaimName, summary.ClaimID, newMetadataVersion, *v.Size())
-	err = s.Manager.ApiConfig.MarkVideoStatus(shared.VideoStatus{
+	err = s.Manager.statusOutbox.MarkVideoStatus(shared.VideoStatus{
 		ChannelID:       s.DbChannelData.ChannelId,
 		VideoID:         v.ID(),
 		Status:          shared.VideoStatusPublished,
@@ -1166,13 +1293,13 @@ func (s *Sync) getUnsentSupports() (float64, error) {
 }
 
 // waitForDaemonProcess observes the running processes and returns when the process is no longer running or when the timeout is up
-func waitForDaemonProcess(timeout time.Duration) error {
+func waitForDaemonProcess(instance logUtils.Instance, timeout time.Duration) error {
 	stopTime := time.Now().Add(timeout * time.Second)
 	for !time.Now().After(stopTime) {
 		wait := 10 * time.Second
 		log.Println("the daemon is still running, waiting for it to exit")
 		time.Sleep(wait)
-		running, err := logUtils.IsLbrynetRunning()
+		running, err := instance.IsLbrynetRunning()
 		if err != nil {
 			return errors.Err(err)
 		}
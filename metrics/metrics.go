@@ -14,4 +14,53 @@ var (
 		Name:      "duration",
 		Help:      "The durations of the individual modules",
 	}, []string{"path"})
+	APICalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ytsync",
+		Subsystem: configs.Configuration.GetHostname(),
+		Name:      "api_calls",
+		Help:      "The attempts made against each internal-apis endpoint, by outcome",
+	}, []string{"endpoint", "outcome"})
+	APIRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ytsync",
+		Subsystem: configs.Configuration.GetHostname(),
+		Name:      "api_retries",
+		Help:      "The retries scheduled for each internal-apis endpoint",
+	}, []string{"endpoint"})
+	APIDurations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "ytsync",
+		Subsystem: configs.Configuration.GetHostname(),
+		Name:      "api_duration",
+		Help:      "The duration of the individual attempts made against each internal-apis endpoint",
+	}, []string{"endpoint"})
+	DownloadedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "ytsync",
+		Subsystem: configs.Configuration.GetHostname(),
+		Name:      "downloaded_bytes",
+		Help:      "The bytes downloaded by yt-dlp, as reported by its progress output",
+	})
+	DownloadSpeeds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "ytsync",
+		Subsystem: configs.Configuration.GetHostname(),
+		Name:      "download_speed",
+		Help:      "The download speeds reported by yt-dlp, in bytes per second",
+		Buckets:   prometheus.ExponentialBuckets(16*1024, 2, 12),
+	})
+	SlowDownloads = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "ytsync",
+		Subsystem: configs.Configuration.GetHostname(),
+		Name:      "slow_downloads",
+		Help:      "The downloads killed for being too slow",
+	})
+	DeferredVideos = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "ytsync",
+		Subsystem: configs.Configuration.GetHostname(),
+		Name:      "deferred_videos",
+		Help:      "The videos put aside because they weren't ready yet (premieres, lives)",
+	})
+	RequeuedDeferredVideos = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "ytsync",
+		Subsystem: configs.Configuration.GetHostname(),
+		Name:      "requeued_deferred_videos",
+		Help:      "The deferred videos queued again once they were due",
+	})
 )
@@ -0,0 +1,83 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler serves the snapshot as JSON
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(Snapshot())
+		if err != nil {
+			log.Errorf("failed to write the status: %s", err.Error())
+		}
+	})
+}
+
+// HTMLHandler serves the snapshot as a page that refreshes itself
+func HTMLHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := statusPage.Execute(w, Snapshot())
+		if err != nil {
+			log.Errorf("failed to write the status page: %s", err.Error())
+		}
+	})
+}
+
+var statusPage = template.Must(template.New("status").Funcs(template.FuncMap{
+	"bytes":   formatBytes,
+	"seconds": func(s float64) string { return fmt.Sprintf("%.0fs", s) },
+	"percent": func(done, total int64) string {
+		if total <= 0 {
+			return "?"
+		}
+		return fmt.Sprintf("%.1f%%", float64(done)/float64(total)*100)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>ytsync status</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+{{range .Channels}}
+<h2>{{.Channel.DesiredChannelName}} ({{.Channel.ChannelId}})</h2>
+<p>instance {{.Instance}} - running for {{seconds .ElapsedSeconds}} - {{.QueueLength}} videos queued - {{.DeferredVideos}} videos deferred</p>
+<table>
+<tr><th>worker</th><th>video</th><th>stage</th><th>downloaded</th><th>source IP</th><th>attempt</th><th>download retries</th><th>elapsed</th></tr>
+{{range .Videos}}
+<tr><td>{{.Worker}}</td><td>{{.VideoID}}</td><td>{{.Stage}}</td><td>{{bytes .DownloadedBytes}} / {{bytes .ExpectedBytes}} ({{percent .DownloadedBytes .ExpectedBytes}})</td><td>{{.SourceIP}}</td><td>{{.Attempt}}</td><td>{{.DownloadRetries}}</td><td>{{seconds .ElapsedSeconds}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<p>No channel is being synced.</p>
+{{end}}
+</body>
+</html>
+`))
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
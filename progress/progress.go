@@ -0,0 +1,272 @@
+package progress
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/shared"
+)
+
+// Stage is the step a video is going through
+type Stage string
+
+const (
+	StageMetadata  Stage = "metadata"
+	StageDownload  Stage = "download"
+	StageTranscode Stage = "transcode"
+	StageThumbnail Stage = "thumbnail"
+	StagePublish   Stage = "publish"
+)
+
+// VideoProgress is what is known about a video being processed by a worker
+type VideoProgress struct {
+	VideoID         string    `json:"video_id"`
+	ChannelID       string    `json:"channel_id"`
+	Worker          int       `json:"worker"`
+	Stage           Stage     `json:"stage"`
+	DownloadedBytes int64     `json:"downloaded_bytes"`
+	ExpectedBytes   int64     `json:"expected_bytes"`
+	SourceIP        string    `json:"source_ip"`
+	Attempt         int       `json:"attempt"`
+	DownloadRetries int       `json:"download_retries"`
+	StartedAt       time.Time `json:"started_at"`
+	StageStartedAt  time.Time `json:"stage_started_at"`
+	ElapsedSeconds  float64   `json:"elapsed_seconds"`
+	// TranscodedSeconds is how much of the DurationSeconds of the video was transcoded so far
+	TranscodedSeconds float64 `json:"transcoded_seconds,omitempty"`
+	DurationSeconds   float64 `json:"duration_seconds,omitempty"`
+}
+
+// ChannelProgress is what is known about a channel being synced
+type ChannelProgress struct {
+	Channel     shared.YoutubeChannel `json:"channel"`
+	Instance    int                   `json:"instance"`
+	QueueLength int                   `json:"queue_length"`
+	// DeferredVideos are the videos put aside until they're ready, they aren't failures
+	DeferredVideos int             `json:"deferred_videos"`
+	StartedAt      time.Time       `json:"started_at"`
+	ElapsedSeconds float64         `json:"elapsed_seconds"`
+	Videos         []VideoProgress `json:"videos"`
+}
+
+// Status is a snapshot of every channel and video being processed
+type Status struct {
+	Channels []ChannelProgress `json:"channels"`
+	// Videos holds the videos whose channel isn't tracked, which shouldn't happen
+	Videos []VideoProgress `json:"videos,omitempty"`
+}
+
+type registry struct {
+	mux      sync.Mutex
+	channels map[string]*ChannelProgress
+	videos   map[string]*VideoProgress
+}
+
+var reg = &registry{
+	channels: make(map[string]*ChannelProgress),
+	videos:   make(map[string]*VideoProgress),
+}
+
+// ChannelTracker updates the progress of a channel. A nil tracker ignores every update
+type ChannelTracker struct {
+	channelID string
+}
+
+// VideoTracker updates the progress of a video. A nil tracker ignores every update, so that code that can run
+// outside of a worker doesn't need to check whether the video is tracked
+type VideoTracker struct {
+	videoID string
+}
+
+// TrackChannel registers a channel that started syncing on the instance
+func TrackChannel(channel shared.YoutubeChannel, instance int) *ChannelTracker {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+	reg.channels[channel.ChannelId] = &ChannelProgress{
+		Channel:   channel,
+		Instance:  instance,
+		StartedAt: time.Now(),
+	}
+	return &ChannelTracker{channelID: channel.ChannelId}
+}
+
+// ForChannel returns the tracker of a channel, or nil if the channel isn't tracked
+func ForChannel(channelID string) *ChannelTracker {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+	if _, ok := reg.channels[channelID]; !ok {
+		return nil
+	}
+	return &ChannelTracker{channelID: channelID}
+}
+
+// SetQueueLength records how many videos are waiting for a worker
+func (c *ChannelTracker) SetQueueLength(length int) {
+	c.update(func(p *ChannelProgress) { p.QueueLength = length })
+}
+
+// SetDeferredVideos records how many videos of the channel are waiting to be ready
+func (c *ChannelTracker) SetDeferredVideos(count int) {
+	c.update(func(p *ChannelProgress) { p.DeferredVideos = count })
+}
+
+// Done forgets the channel and its videos
+func (c *ChannelTracker) Done() {
+	if c == nil {
+		return
+	}
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+	delete(reg.channels, c.channelID)
+	for id, v := range reg.videos {
+		if v.ChannelID == c.channelID {
+			delete(reg.videos, id)
+		}
+	}
+}
+
+func (c *ChannelTracker) update(f func(p *ChannelProgress)) {
+	if c == nil {
+		return
+	}
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+	if p, ok := reg.channels[c.channelID]; ok {
+		f(p)
+	}
+}
+
+// TrackVideo registers a video picked up by a worker
+func TrackVideo(channelID string, videoID string, worker int) *VideoTracker {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+	now := time.Now()
+	reg.videos[videoID] = &VideoProgress{
+		VideoID:        videoID,
+		ChannelID:      channelID,
+		Worker:         worker,
+		Stage:          StageMetadata,
+		StartedAt:      now,
+		StageStartedAt: now,
+	}
+	return &VideoTracker{videoID: videoID}
+}
+
+// ForVideo returns the tracker of a video, or nil if the video isn't tracked
+func ForVideo(videoID string) *VideoTracker {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+	if _, ok := reg.videos[videoID]; !ok {
+		return nil
+	}
+	return &VideoTracker{videoID: videoID}
+}
+
+// SetStage moves the video to a new stage
+func (v *VideoTracker) SetStage(stage Stage) {
+	v.update(func(p *VideoProgress) {
+		if p.Stage != stage {
+			p.Stage = stage
+			p.StageStartedAt = time.Now()
+		}
+	})
+}
+
+// SetAttempt records which attempt of the worker at processing the video is running, starting at 1
+func (v *VideoTracker) SetAttempt(attempt int) {
+	v.update(func(p *VideoProgress) { p.Attempt = attempt })
+}
+
+// AddDownloadRetry records that the download is started over
+func (v *VideoTracker) AddDownloadRetry() {
+	v.update(func(p *VideoProgress) { p.DownloadRetries++ })
+}
+
+// SetSourceIP records the address the video is downloaded from
+func (v *VideoTracker) SetSourceIP(ip string) {
+	v.update(func(p *VideoProgress) { p.SourceIP = ip })
+}
+
+// SetExpectedBytes records the size the download is expected to reach
+func (v *VideoTracker) SetExpectedBytes(size int64) {
+	v.update(func(p *VideoProgress) { p.ExpectedBytes = size })
+}
+
+// SetDownloadedBytes records how much was downloaded so far
+func (v *VideoTracker) SetDownloadedBytes(size int64) {
+	v.update(func(p *VideoProgress) { p.DownloadedBytes = size })
+}
+
+// SetTranscoded records how much of the video, lasting duration, was transcoded so far
+func (v *VideoTracker) SetTranscoded(done time.Duration, duration time.Duration) {
+	v.update(func(p *VideoProgress) {
+		p.TranscodedSeconds = done.Seconds()
+		p.DurationSeconds = duration.Seconds()
+	})
+}
+
+// Done forgets the video
+func (v *VideoTracker) Done() {
+	if v == nil {
+		return
+	}
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+	delete(reg.videos, v.videoID)
+}
+
+func (v *VideoTracker) update(f func(p *VideoProgress)) {
+	if v == nil {
+		return
+	}
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+	if p, ok := reg.videos[v.videoID]; ok {
+		f(p)
+	}
+}
+
+// Snapshot returns a copy of the progress of every channel and video, oldest first
+func Snapshot() Status {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+	now := time.Now()
+	status := Status{Channels: make([]ChannelProgress, 0, len(reg.channels))}
+	indexes := make(map[string]int, len(reg.channels))
+	for _, c := range reg.channels {
+		cp := *c
+		cp.ElapsedSeconds = now.Sub(c.StartedAt).Seconds()
+		cp.Videos = []VideoProgress{}
+		status.Channels = append(status.Channels, cp)
+	}
+	sort.Slice(status.Channels, func(i, j int) bool {
+		return status.Channels[i].StartedAt.Before(status.Channels[j].StartedAt)
+	})
+	for i, c := range status.Channels {
+		indexes[c.Channel.ChannelId] = i
+	}
+	for _, v := range reg.videos {
+		vp := *v
+		vp.ElapsedSeconds = now.Sub(v.StartedAt).Seconds()
+		if i, ok := indexes[v.ChannelID]; ok {
+			status.Channels[i].Videos = append(status.Channels[i].Videos, vp)
+		} else {
+			status.Videos = append(status.Videos, vp)
+		}
+	}
+	for _, c := range status.Channels {
+		sortVideos(c.Videos)
+	}
+	sortVideos(status.Videos)
+	return status
+}
+
+func sortVideos(videos []VideoProgress) {
+	sort.Slice(videos, func(i, j int) bool {
+		if videos[i].Worker == videos[j].Worker {
+			return videos[i].StartedAt.Before(videos[j].StartedAt)
+		}
+		return videos[i].Worker < videos[j].Worker
+	})
+}
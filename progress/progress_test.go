@@ -0,0 +1,78 @@
+package progress
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	channel := TrackChannel(shared.YoutubeChannel{ChannelId: "UCabc", DesiredChannelName: "@abc"}, 2)
+	defer channel.Done()
+	channel.SetQueueLength(10)
+
+	TrackVideo("UCabc", "vid2", 2)
+	video := TrackVideo("UCabc", "vid1", 1)
+	video.SetAttempt(1)
+	ForVideo("vid1").SetStage(StageDownload)
+	ForVideo("vid1").SetSourceIP("10.0.0.1")
+	ForVideo("vid1").SetExpectedBytes(2048)
+	ForVideo("vid1").SetDownloadedBytes(1024)
+	ForVideo("vid1").AddDownloadRetry()
+	ForVideo("vid2").SetTranscoded(30*time.Second, 2*time.Minute)
+
+	// videos that aren't tracked are ignored
+	assert.Nil(t, ForVideo("missing"))
+	ForVideo("missing").SetStage(StagePublish)
+
+	status := Snapshot()
+	assert.Len(t, status.Channels, 1)
+	c := status.Channels[0]
+	assert.Equal(t, "@abc", c.Channel.DesiredChannelName)
+	assert.Equal(t, 2, c.Instance)
+	assert.Equal(t, 10, c.QueueLength)
+	assert.Len(t, c.Videos, 2)
+	assert.Equal(t, "vid1", c.Videos[0].VideoID)
+	assert.Equal(t, StageDownload, c.Videos[0].Stage)
+	assert.Equal(t, "10.0.0.1", c.Videos[0].SourceIP)
+	assert.Equal(t, int64(1024), c.Videos[0].DownloadedBytes)
+	assert.Equal(t, int64(2048), c.Videos[0].ExpectedBytes)
+	assert.Equal(t, 1, c.Videos[0].Attempt)
+	assert.Equal(t, 1, c.Videos[0].DownloadRetries)
+	assert.Equal(t, StageMetadata, c.Videos[1].Stage)
+	assert.Equal(t, float64(30), c.Videos[1].TranscodedSeconds)
+	assert.Equal(t, float64(120), c.Videos[1].DurationSeconds)
+
+	video.Done()
+	assert.Len(t, Snapshot().Channels[0].Videos, 1)
+	channel.Done()
+	assert.Empty(t, Snapshot().Channels)
+	assert.Nil(t, ForVideo("vid2"))
+}
+
+func TestHandlers(t *testing.T) {
+	channel := TrackChannel(shared.YoutubeChannel{ChannelId: "UCabc", DesiredChannelName: "@abc"}, 1)
+	defer channel.Done()
+	TrackVideo("UCabc", "vid1", 1).SetExpectedBytes(3 * 1024 * 1024)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var status Status
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, "vid1", status.Channels[0].Videos[0].VideoID)
+
+	rec = httptest.NewRecorder()
+	HTMLHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status.html", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.True(t, strings.Contains(body, "@abc"))
+	assert.True(t, strings.Contains(body, "0 B / 3.0 MiB (0.0%)"))
+}
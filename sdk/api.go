@@ -1,15 +1,17 @@
 package sdk
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
@@ -17,8 +19,6 @@ import (
 	"github.com/lbryio/ytsync/v5/configs"
 	"github.com/lbryio/ytsync/v5/shared"
 
-	"github.com/lbryio/ytsync/v5/util"
-
 	log "github.com/sirupsen/logrus"
 )
 
@@ -31,8 +31,19 @@ type APIConfig struct {
 	ApiToken string
 	HostName string
 	client   *http.Client
+
+	retryPolicy RetryPolicy
+	ctx         context.Context
+	ctxMux      sync.RWMutex
+	// noVideoStates is set once internal-apis turned out not to know /yt/video_states
+	noVideoStates atomic.Bool
+	// noVideoStatuses is set once internal-apis turned out not to know /yt/video_statuses
+	noVideoStatuses atomic.Bool
 }
 
+// ErrChannelManagedElsewhere is returned when another sync server already took care of the channel
+var ErrChannelManagedElsewhere = errors.Base("this youtube channel is being managed by another server")
+
 var instance *APIConfig
 
 func GetAPIsConfigs() *APIConfig {
@@ -48,11 +59,17 @@ func GetAPIsConfigs() *APIConfig {
 				ExpectContinueTimeout: 1 * time.Second,
 			},
 		}
+		retryPolicy, err := RetryPolicyFromConfig(configs.Configuration.APIRetry)
+		if err != nil {
+			log.Errorf("falling back to the default api retry policy: %s", err.Error())
+			retryPolicy = DefaultRetryPolicy
+		}
 		instance = &APIConfig{
-			ApiURL:   configs.Configuration.InternalApisEndpoint,
-			ApiToken: configs.Configuration.InternalApisAuthToken,
-			HostName: configs.Configuration.GetHostname(),
-			client:   c,
+			ApiURL:      configs.Configuration.InternalApisEndpoint,
+			ApiToken:    configs.Configuration.InternalApisAuthToken,
+			HostName:    configs.Configuration.GetHostname(),
+			client:      c,
+			retryPolicy: retryPolicy,
 		}
 	}
 	return instance
@@ -64,8 +81,8 @@ func (a *APIConfig) FetchChannels(status string, cliFlags *shared.SyncFlags) ([]
 		Error   null.String             `json:"error"`
 		Data    []shared.YoutubeChannel `json:"data"`
 	}
-	endpoint := a.ApiURL + "/yt/jobs"
-	res, err := a.client.PostForm(endpoint, url.Values{
+	endpoint := "/yt/jobs"
+	body, _, err := a.post(endpoint, url.Values{
 		"auth_token":              {a.ApiToken},
 		"sync_status":             {status},
 		"min_videos":              {strconv.Itoa(1)},
@@ -74,19 +91,9 @@ func (a *APIConfig) FetchChannels(status string, cliFlags *shared.SyncFlags) ([]
 		"sync_server":             {a.HostName},
 		"channel_id":              {cliFlags.ChannelID},
 		"with_deleted_on_youtube": {fmt.Sprintf("%t", cliFlags.WithDeletedOnYoutube)},
-	})
+	}, retryUnlessOK)
 	if err != nil {
-		util.SendErrorToSlack("error while trying to call %s. Waiting to retry: %s", endpoint, err.Error())
-		time.Sleep(30 * time.Second)
-		return a.FetchChannels(status, cliFlags)
-	}
-	defer res.Body.Close()
-	body, _ := io.ReadAll(res.Body)
-	if res.StatusCode != http.StatusOK {
-		util.SendErrorToSlack("Error %d while trying to call %s. Waiting to retry", res.StatusCode, endpoint)
-		log.Debugln(string(body))
-		time.Sleep(30 * time.Second)
-		return a.FetchChannels(status, cliFlags)
+		return nil, err
 	}
 	var response apiJobsResponse
 	err = json.Unmarshal(body, &response)
@@ -120,6 +127,16 @@ func sanitizeFailureReason(s *string) {
 	}
 }
 
+// validateVideoStatus rejects updates that no job store would accept
+func validateVideoStatus(status shared.VideoStatus) error {
+	if status.Status == VideoStatusPublished || status.Status == VideoStatusUpgradeFailed {
+		if status.ClaimID == "" || status.ClaimName == "" {
+			return errors.Err("claimID (%s) or claimName (%s) missing", status.ClaimID, status.ClaimName)
+		}
+	}
+	return nil
+}
+
 func (a *APIConfig) SetChannelCert(certHex string, channelID string) error {
 	type apiSetChannelCertResponse struct {
 		Success bool        `json:"success"`
@@ -127,25 +144,15 @@ func (a *APIConfig) SetChannelCert(certHex string, channelID string) error {
 		Data    string      `json:"data"`
 	}
 
-	endpoint := a.ApiURL + "/yt/channel_cert"
+	endpoint := "/yt/channel_cert"
 
-	res, err := a.client.PostForm(endpoint, url.Values{
+	body, _, err := a.post(endpoint, url.Values{
 		"channel_claim_id": {channelID},
 		"channel_cert":     {certHex},
 		"auth_token":       {a.ApiToken},
-	})
+	}, retryUnlessOK)
 	if err != nil {
-		util.SendErrorToSlack("error while trying to call %s. Waiting to retry: %s", endpoint, err.Error())
-		time.Sleep(30 * time.Second)
-		return a.SetChannelCert(certHex, channelID)
-	}
-	defer res.Body.Close()
-	body, _ := io.ReadAll(res.Body)
-	if res.StatusCode != http.StatusOK {
-		util.SendErrorToSlack("Error %d while trying to call %s. Waiting to retry", res.StatusCode, endpoint)
-		log.Debugln(string(body))
-		time.Sleep(30 * time.Second)
-		return a.SetChannelCert(certHex, channelID)
+		return err
 	}
 	var response apiSetChannelCertResponse
 	err = json.Unmarshal(body, &response)
@@ -165,7 +172,7 @@ func (a *APIConfig) SetChannelStatus(channelID string, status string, failureRea
 		Error   null.String   `json:"error"`
 		Data    []SyncedVideo `json:"data"`
 	}
-	endpoint := a.ApiURL + "/yt/channel_status"
+	endpoint := "/yt/channel_status"
 
 	sanitizeFailureReason(&failureReason)
 	params := url.Values{
@@ -178,22 +185,9 @@ func (a *APIConfig) SetChannelStatus(channelID string, status string, failureRea
 	if transferState != nil {
 		params.Add("transfer_state", strconv.Itoa(*transferState))
 	}
-	res, err := a.client.PostForm(endpoint, params)
+	body, statusCode, err := a.post(endpoint, params, retryServerErrors)
 	if err != nil {
-		util.SendErrorToSlack("error while trying to call %s. Waiting to retry: %s", endpoint, err.Error())
-		time.Sleep(30 * time.Second)
-		return a.SetChannelStatus(channelID, status, failureReason, transferState)
-	}
-	defer res.Body.Close()
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, nil, errors.Err(err)
-	}
-	if res.StatusCode >= http.StatusInternalServerError {
-		util.SendErrorToSlack("Error %d while trying to call %s. Waiting to retry", res.StatusCode, endpoint)
-		log.Debugln(string(body))
-		time.Sleep(30 * time.Second)
-		return a.SetChannelStatus(channelID, status, failureReason, transferState)
+		return nil, nil, err
 	}
 	var response apiChannelStatusResponse
 	err = json.Unmarshal(body, &response)
@@ -201,6 +195,9 @@ func (a *APIConfig) SetChannelStatus(channelID string, status string, failureRea
 		return nil, nil, errors.Err(err)
 	}
 	if !response.Error.IsNull() {
+		if strings.Contains(response.Error.String, ErrChannelManagedElsewhere.Error()) {
+			return nil, nil, errors.Err(ErrChannelManagedElsewhere)
+		}
 		return nil, nil, errors.Err(response.Error.String)
 	}
 	if response.Data != nil {
@@ -214,7 +211,7 @@ func (a *APIConfig) SetChannelStatus(channelID string, status string, failureRea
 		}
 		return svs, claimNames, nil
 	}
-	return nil, nil, errors.Err("invalid API response. Status code: %d", res.StatusCode)
+	return nil, nil, errors.Err("invalid API response. Status code: %d", statusCode)
 }
 
 func (a *APIConfig) SetChannelClaimID(channelID string, channelClaimID string) error {
@@ -223,24 +220,14 @@ func (a *APIConfig) SetChannelClaimID(channelID string, channelClaimID string) e
 		Error   null.String `json:"error"`
 		Data    string      `json:"data"`
 	}
-	endpoint := a.ApiURL + "/yt/set_channel_claim_id"
-	res, err := a.client.PostForm(endpoint, url.Values{
+	endpoint := "/yt/set_channel_claim_id"
+	body, _, err := a.post(endpoint, url.Values{
 		"channel_id":       {channelID},
 		"auth_token":       {a.ApiToken},
 		"channel_claim_id": {channelClaimID},
-	})
+	}, retryUnlessOK)
 	if err != nil {
-		util.SendErrorToSlack("error while trying to call %s. Waiting to retry: %s", endpoint, err.Error())
-		time.Sleep(30 * time.Second)
-		return a.SetChannelClaimID(channelID, channelClaimID)
-	}
-	defer res.Body.Close()
-	body, _ := io.ReadAll(res.Body)
-	if res.StatusCode != http.StatusOK {
-		util.SendErrorToSlack("Error %d while trying to call %s. Waiting to retry", res.StatusCode, endpoint)
-		log.Debugln(string(body))
-		time.Sleep(30 * time.Second)
-		return a.SetChannelClaimID(channelID, channelClaimID)
+		return err
 	}
 	var response apiChannelStatusResponse
 	err = json.Unmarshal(body, &response)
@@ -263,25 +250,15 @@ const (
 )
 
 func (a *APIConfig) DeleteVideos(videos []string) error {
-	endpoint := a.ApiURL + "/yt/video_delete"
+	endpoint := "/yt/video_delete"
 	videoIDs := strings.Join(videos, ",")
 	vals := url.Values{
 		"video_ids":  {videoIDs},
 		"auth_token": {a.ApiToken},
 	}
-	res, err := a.client.PostForm(endpoint, vals)
+	body, statusCode, err := a.post(endpoint, vals, retryUnlessOK)
 	if err != nil {
-		util.SendErrorToSlack("error while trying to call %s. Waiting to retry: %s", endpoint, err.Error())
-		time.Sleep(30 * time.Second)
-		return a.DeleteVideos(videos)
-	}
-	defer res.Body.Close()
-	body, _ := io.ReadAll(res.Body)
-	if res.StatusCode != http.StatusOK {
-		util.SendErrorToSlack("Error %d while trying to call %s. Waiting to retry", res.StatusCode, endpoint)
-		log.Debugln(string(body))
-		time.Sleep(30 * time.Second)
-		return a.DeleteVideos(videos)
+		return err
 	}
 	var response struct {
 		Success bool        `json:"success"`
@@ -299,24 +276,57 @@ func (a *APIConfig) DeleteVideos(videos []string) error {
 	if !response.Data.IsNull() && response.Data.String == "ok" {
 		return nil
 	}
-	return errors.Err("invalid API response. Status code: %d", res.StatusCode)
+	return errors.Err("invalid API response. Status code: %d", statusCode)
 }
 
 func (a *APIConfig) MarkVideoStatus(status shared.VideoStatus) error {
-	endpoint := a.ApiURL + "/yt/video_status"
+	endpoint := "/yt/video_status"
 
+	vals, err := videoStatusValues(status)
+	if err != nil {
+		return err
+	}
+	vals.Set("auth_token", a.ApiToken)
+	body, statusCode, err := a.post(endpoint, vals, retryUnlessOK)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		Success bool        `json:"success"`
+		Error   null.String `json:"error"`
+		Data    null.String `json:"data"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return err
+	}
+	if !response.Error.IsNull() {
+		return errors.Err(response.Error.String)
+	}
+	if !response.Data.IsNull() && response.Data.String == "ok" {
+		return nil
+	}
+	return errors.Err("invalid API response. Status code: %d", statusCode)
+}
+
+// videoStatusValues returns the fields /yt/video_status expects for the update
+func videoStatusValues(status shared.VideoStatus) (url.Values, error) {
 	sanitizeFailureReason(&status.FailureReason)
 	vals := url.Values{
 		"youtube_channel_id": {status.ChannelID},
 		"video_id":           {status.VideoID},
 		"status":             {status.Status},
-		"auth_token":         {a.ApiToken},
+	}
+	err := validateVideoStatus(status)
+	if err != nil {
+		return nil, err
 	}
 	if status.Status == VideoStatusPublished || status.Status == VideoStatusUpgradeFailed {
-		if status.ClaimID == "" || status.ClaimName == "" {
-			return errors.Err("claimID (%s) or claimName (%s) missing", status.ClaimID, status.ClaimName)
+		publishedAt := status.PublishedAt
+		if publishedAt == 0 {
+			publishedAt = time.Now().Unix()
 		}
-		vals.Add("published_at", strconv.FormatInt(time.Now().Unix(), 10))
+		vals.Add("published_at", strconv.FormatInt(publishedAt, 10))
 		vals.Add("claim_id", status.ClaimID)
 		vals.Add("claim_name", status.ClaimName)
 		if status.MetaDataVersion > 0 {
@@ -332,62 +342,95 @@ func (a *APIConfig) MarkVideoStatus(status shared.VideoStatus) error {
 	if status.IsTransferred != nil {
 		vals.Add("transferred", strconv.FormatBool(*status.IsTransferred))
 	}
-	res, err := a.client.PostForm(endpoint, vals)
+	return vals, nil
+}
+
+// MarkVideoStatuses sends several updates in a single /yt/video_statuses call. When internal-apis doesn't know it,
+// the updates are sent one by one through MarkVideoStatus instead
+func (a *APIConfig) MarkVideoStatuses(statuses []shared.VideoStatus) ([]error, error) {
+	if a.noVideoStatuses.Load() {
+		return markVideoStatusesOneByOne(statuses, a.MarkVideoStatus)
+	}
+	endpoint := "/yt/video_statuses"
+	results := make([]error, len(statuses))
+	var sent []int
+	var updates []map[string]string
+	for i, status := range statuses {
+		vals, err := videoStatusValues(status)
+		if err != nil {
+			results[i] = err
+			continue
+		}
+		update := make(map[string]string, len(vals))
+		for k := range vals {
+			update[k] = vals.Get(k)
+		}
+		sent = append(sent, i)
+		updates = append(updates, update)
+	}
+	if len(updates) == 0 {
+		return results, nil
+	}
+	payload, err := json.Marshal(updates)
 	if err != nil {
-		util.SendErrorToSlack("error while trying to call %s. Waiting to retry: %s", endpoint, err.Error())
-		time.Sleep(30 * time.Second)
-		return a.MarkVideoStatus(status)
-	}
-	defer res.Body.Close()
-	body, _ := io.ReadAll(res.Body)
-	if res.StatusCode != http.StatusOK {
-		util.SendErrorToSlack("Error %d while trying to call %s. Waiting to retry", res.StatusCode, endpoint)
-		log.Debugln(string(body))
-		time.Sleep(30 * time.Second)
-		return a.MarkVideoStatus(status)
+		return nil, errors.Err(err)
+	}
+	vals := url.Values{
+		"statuses":   {string(payload)},
+		"auth_token": {a.ApiToken},
+	}
+	body, statusCode, err := a.post(endpoint, vals, retryUnlessFound)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode == http.StatusNotFound {
+		log.Warnf("%s is not supported by internal-apis, sending the video statuses one by one", endpoint)
+		a.noVideoStatuses.Store(true)
+		return markVideoStatusesOneByOne(statuses, a.MarkVideoStatus)
 	}
 	var response struct {
 		Success bool        `json:"success"`
 		Error   null.String `json:"error"`
-		Data    null.String `json:"data"`
+		// Data holds the error of each update, null when it was accepted
+		Data []null.String `json:"data"`
 	}
 	err = json.Unmarshal(body, &response)
 	if err != nil {
-		return err
+		return nil, errors.Err(err)
 	}
 	if !response.Error.IsNull() {
-		return errors.Err(response.Error.String)
+		if isUnknownMethod(response.Error.String) {
+			log.Warnf("%s is not supported by internal-apis, sending the video statuses one by one", endpoint)
+			a.noVideoStatuses.Store(true)
+			return markVideoStatusesOneByOne(statuses, a.MarkVideoStatus)
+		}
+		return nil, errors.Err(response.Error.String)
 	}
-	if !response.Data.IsNull() && response.Data.String == "ok" {
-		return nil
+	if len(response.Data) != len(updates) {
+		return nil, errors.Err("invalid API response. Status code: %d", statusCode)
 	}
-	return errors.Err("invalid API response. Status code: %d", res.StatusCode)
+	for j, i := range sent {
+		if !response.Data[j].IsNull() {
+			results[i] = errors.Err(response.Data[j].String)
+		}
+	}
+	return results, nil
 }
 
 func (a *APIConfig) VideoState(videoID string) (string, error) {
-	endpoint := a.ApiURL + "/yt/video_state"
+	endpoint := "/yt/video_state"
 	vals := url.Values{
 		"video_id":   {videoID},
 		"auth_token": {a.ApiToken},
 	}
 
-	res, err := a.client.PostForm(endpoint, vals)
+	body, statusCode, err := a.post(endpoint, vals, retryUnlessFound)
 	if err != nil {
-		util.SendErrorToSlack("error while trying to call %s. Waiting to retry: %s", endpoint, err.Error())
-		time.Sleep(30 * time.Second)
-		return a.VideoState(videoID)
+		return "", err
 	}
-	defer res.Body.Close()
-	body, _ := io.ReadAll(res.Body)
-	if res.StatusCode == http.StatusNotFound {
+	if statusCode == http.StatusNotFound {
 		return "not_found", nil
 	}
-	if res.StatusCode != http.StatusOK {
-		util.SendErrorToSlack("Error %d while trying to call %s. Waiting to retry", res.StatusCode, endpoint)
-		log.Debugln(string(body))
-		time.Sleep(30 * time.Second)
-		return a.VideoState(videoID)
-	}
 	var response struct {
 		Success bool        `json:"success"`
 		Error   null.String `json:"error"`
@@ -403,7 +446,77 @@ func (a *APIConfig) VideoState(videoID string) (string, error) {
 	if !response.Data.IsNull() {
 		return response.Data.String, nil
 	}
-	return "", errors.Err("invalid API response. Status code: %d", res.StatusCode)
+	return "", errors.Err("invalid API response. Status code: %d", statusCode)
+}
+
+// VideoStates looks up the states of the videos in a single call. Versions of internal-apis without /yt/video_states
+// are asked about each video through VideoState instead
+func (a *APIConfig) VideoStates(videoIDs []string) (map[string]string, error) {
+	if a.noVideoStates.Load() {
+		return a.videoStatesOneByOne(videoIDs)
+	}
+	endpoint := "/yt/video_states"
+	vals := url.Values{
+		"video_ids":  {strings.Join(videoIDs, ",")},
+		"auth_token": {a.ApiToken},
+	}
+
+	body, statusCode, err := a.post(endpoint, vals, retryUnlessFound)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode == http.StatusNotFound {
+		log.Warnf("%s is not supported by internal-apis, looking up the states of the videos one by one", endpoint)
+		a.noVideoStates.Store(true)
+		return a.videoStatesOneByOne(videoIDs)
+	}
+	var response struct {
+		Success bool              `json:"success"`
+		Error   null.String       `json:"error"`
+		Data    map[string]string `json:"data"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	if !response.Error.IsNull() {
+		if isUnknownMethod(response.Error.String) {
+			log.Warnf("%s is not supported by internal-apis, looking up the states of the videos one by one", endpoint)
+			a.noVideoStates.Store(true)
+			return a.videoStatesOneByOne(videoIDs)
+		}
+		return nil, errors.Err(response.Error.String)
+	}
+	if response.Data == nil {
+		return nil, errors.Err("invalid API response. Status code: %d", statusCode)
+	}
+	states := make(map[string]string, len(videoIDs))
+	for _, videoID := range videoIDs {
+		state, ok := response.Data[videoID]
+		if !ok {
+			state = "not_found"
+		}
+		states[videoID] = state
+	}
+	return states, nil
+}
+
+func (a *APIConfig) videoStatesOneByOne(videoIDs []string) (map[string]string, error) {
+	states := make(map[string]string, len(videoIDs))
+	for _, videoID := range videoIDs {
+		state, err := a.VideoState(videoID)
+		if err != nil {
+			return nil, err
+		}
+		states[videoID] = state
+	}
+	return states, nil
+}
+
+// isUnknownMethod tells whether internal-apis refused a call because it doesn't know the endpoint
+func isUnknownMethod(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "unknown method") || strings.Contains(message, "method not found")
 }
 
 type VideoRelease struct {
@@ -416,29 +529,19 @@ type VideoRelease struct {
 }
 
 func (a *APIConfig) GetReleasedDate(videoID string) (*VideoRelease, error) {
-	endpoint := a.ApiURL + "/yt/released"
+	endpoint := "/yt/released"
 	vals := url.Values{
 		"video_id":   {videoID},
 		"auth_token": {a.ApiToken},
 	}
 
-	res, err := a.client.PostForm(endpoint, vals)
+	body, statusCode, err := a.post(endpoint, vals, retryUnlessFound)
 	if err != nil {
-		util.SendErrorToSlack("error while trying to call %s. Waiting to retry: %s", endpoint, err.Error())
-		time.Sleep(30 * time.Second)
-		return a.GetReleasedDate(videoID)
+		return nil, err
 	}
-	defer res.Body.Close()
-	body, _ := io.ReadAll(res.Body)
-	if res.StatusCode == http.StatusNotFound {
+	if statusCode == http.StatusNotFound {
 		return nil, nil
 	}
-	if res.StatusCode != http.StatusOK {
-		util.SendErrorToSlack("Error %d while trying to call %s. Waiting to retry", res.StatusCode, endpoint)
-		log.Debugln(string(body))
-		time.Sleep(30 * time.Second)
-		return a.GetReleasedDate(videoID)
-	}
 	var response struct {
 		Success bool         `json:"success"`
 		Error   null.String  `json:"error"`
@@ -454,5 +557,5 @@ func (a *APIConfig) GetReleasedDate(videoID string) (*VideoRelease, error) {
 	if response.Data.ReleaseTime != "" {
 		return &response.Data, nil
 	}
-	return nil, errors.Err("invalid API response. Status code: %d", res.StatusCode)
+	return nil, errors.Err("invalid API response. Status code: %d", statusCode)
 }
@@ -0,0 +1,63 @@
+package sdk
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+)
+
+// PlaylistCollection is the LBRY collection a youtube playlist was published as
+type PlaylistCollection struct {
+	ClaimID string `json:"claim_id"`
+	// Claims are the stream claim IDs the collection was last published with, in playlist order
+	Claims []string `json:"claims"`
+}
+
+// CollectionIndex remembers which collection each playlist was published as, so that syncing a channel again
+// updates its collections instead of creating new ones. It is kept in a JSON file, by channel and playlist ID
+type CollectionIndex struct {
+	path        string
+	mux         sync.Mutex
+	collections map[string]map[string]PlaylistCollection
+}
+
+// NewCollectionIndex opens the index kept at path
+func NewCollectionIndex(path string) (*CollectionIndex, error) {
+	i := &CollectionIndex{
+		path:        path,
+		collections: make(map[string]map[string]PlaylistCollection),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return i, nil
+	}
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	err = json.Unmarshal(data, &i.collections)
+	if err != nil {
+		return nil, errors.Prefix("failed to parse "+path, err)
+	}
+	return i, nil
+}
+
+// Get returns the collection the playlist was published as, if any
+func (i *CollectionIndex) Get(channelID string, playlistID string) (PlaylistCollection, bool) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	c, ok := i.collections[channelID][playlistID]
+	return c, ok
+}
+
+// Set records the collection of a playlist and saves the index
+func (i *CollectionIndex) Set(channelID string, playlistID string, collection PlaylistCollection) error {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	if i.collections[channelID] == nil {
+		i.collections[channelID] = make(map[string]PlaylistCollection)
+	}
+	i.collections[channelID][playlistID] = collection
+	return writeJSONAtomically(i.path, i.collections)
+}
@@ -0,0 +1,27 @@
+package sdk
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collections.json")
+	index, err := NewCollectionIndex(path)
+	assert.NoError(t, err)
+	_, ok := index.Get("UCabc", "PL1")
+	assert.False(t, ok)
+
+	assert.NoError(t, index.Set("UCabc", "PL1", PlaylistCollection{ClaimID: "c1", Claims: []string{"s1", "s2"}}))
+	assert.NoError(t, index.Set("UCabc", "PL2", PlaylistCollection{ClaimID: "c2"}))
+
+	reopened, err := NewCollectionIndex(path)
+	assert.NoError(t, err)
+	c, ok := reopened.Get("UCabc", "PL1")
+	assert.True(t, ok)
+	assert.Equal(t, PlaylistCollection{ClaimID: "c1", Claims: []string{"s1", "s2"}}, c)
+	_, ok = reopened.Get("UCother", "PL1")
+	assert.False(t, ok)
+}
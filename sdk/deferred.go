@@ -0,0 +1,189 @@
+package sdk
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/ytsync/v5/configs"
+	"github.com/lbryio/ytsync/v5/metrics"
+)
+
+const (
+	defaultDeferredInitialBackoff = time.Hour
+	defaultDeferredMaxBackoff     = 24 * time.Hour
+	defaultDeferredVideosPath     = "deferred_videos.json"
+)
+
+// DeferredVideo is a video that isn't ready to be synced yet: an upcoming premiere, a scheduled or ongoing live, or a
+// live that YouTube is still processing
+type DeferredVideo struct {
+	VideoID string `json:"video_id"`
+	// URL is the URL the video was listed with, empty for the sources that find videos by their ID
+	URL    string `json:"url,omitempty"`
+	Reason string `json:"reason"`
+	// NotBefore is the unix time from which the video is queued again
+	NotBefore int64 `json:"not_before"`
+	// Deferrals counts how many times in a row the video was deferred, it drives the backoff
+	Deferrals int `json:"deferrals"`
+}
+
+// DeferredVideos holds the deferred videos of every channel until they are due. It is kept in a JSON file of
+// deferred videos by channel ID. A nil DeferredVideos defers nothing
+type DeferredVideos struct {
+	path           string
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	mux            sync.Mutex
+	channels       map[string]map[string]DeferredVideo
+}
+
+// NewDeferredVideos opens the deferred videos kept at path. Videos with no known release time are queued again after
+// initialBackoff, doubled every time they are deferred again up to maxBackoff
+func NewDeferredVideos(path string, initialBackoff time.Duration, maxBackoff time.Duration) (*DeferredVideos, error) {
+	d := &DeferredVideos{
+		path:           path,
+		initialBackoff: initialBackoff,
+		maxBackoff:     maxBackoff,
+		channels:       make(map[string]map[string]DeferredVideo),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	err = json.Unmarshal(data, &d.channels)
+	if err != nil {
+		return nil, errors.Prefix("failed to parse "+path, err)
+	}
+	return d, nil
+}
+
+// DeferredVideosFromConfig opens the deferred videos configured in deferred_videos
+func DeferredVideosFromConfig(c configs.DeferredConfig) (*DeferredVideos, error) {
+	initialBackoff := defaultDeferredInitialBackoff
+	maxBackoff := defaultDeferredMaxBackoff
+	var err error
+	if c.InitialBackoff != "" {
+		initialBackoff, err = time.ParseDuration(c.InitialBackoff)
+		if err != nil {
+			return nil, errors.Prefix("invalid initial_backoff", err)
+		}
+	}
+	if c.MaxBackoff != "" {
+		maxBackoff, err = time.ParseDuration(c.MaxBackoff)
+		if err != nil {
+			return nil, errors.Prefix("invalid max_backoff", err)
+		}
+	}
+	path := c.StatePath
+	if path == "" {
+		path = defaultDeferredVideosPath
+	}
+	return NewDeferredVideos(path, initialBackoff, maxBackoff)
+}
+
+// Defer records that the video isn't ready yet. When readyAt is zero the video is queued again after the backoff.
+// It returns the time from which the video is due
+func (d *DeferredVideos) Defer(channelID string, videoID string, url string, reason string, readyAt time.Time, now time.Time) (time.Time, error) {
+	if d == nil {
+		return time.Time{}, nil
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	videos, ok := d.channels[channelID]
+	if !ok {
+		videos = make(map[string]DeferredVideo)
+		d.channels[channelID] = videos
+	}
+	deferrals := videos[videoID].Deferrals + 1
+	notBefore := readyAt
+	if notBefore.IsZero() || !notBefore.After(now) {
+		backoff := d.initialBackoff
+		for i := 1; i < deferrals && backoff < d.maxBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > d.maxBackoff {
+			backoff = d.maxBackoff
+		}
+		notBefore = now.Add(backoff)
+	}
+	videos[videoID] = DeferredVideo{
+		VideoID:   videoID,
+		URL:       url,
+		Reason:    reason,
+		NotBefore: notBefore.Unix(),
+		Deferrals: deferrals,
+	}
+	metrics.DeferredVideos.Inc()
+	return notBefore, d.save()
+}
+
+// Remove forgets a video, once it was synced or failed for another reason
+func (d *DeferredVideos) Remove(channelID string, videoID string) error {
+	if d == nil {
+		return nil
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if _, ok := d.channels[channelID][videoID]; !ok {
+		return nil
+	}
+	delete(d.channels[channelID], videoID)
+	if len(d.channels[channelID]) == 0 {
+		delete(d.channels, channelID)
+	}
+	return d.save()
+}
+
+// Due returns the videos of the channel that should be queued again, sorted by ID
+func (d *DeferredVideos) Due(channelID string, now time.Time) []DeferredVideo {
+	if d == nil {
+		return nil
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	var due []DeferredVideo
+	for _, v := range d.channels[channelID] {
+		if !now.Before(time.Unix(v.NotBefore, 0)) {
+			due = append(due, v)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].VideoID < due[j].VideoID })
+	return due
+}
+
+// Pending returns the deferred videos of the channel, due or not
+func (d *DeferredVideos) Pending(channelID string) []DeferredVideo {
+	if d == nil {
+		return nil
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	pending := make([]DeferredVideo, 0, len(d.channels[channelID]))
+	for _, v := range d.channels[channelID] {
+		pending = append(pending, v)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].NotBefore < pending[j].NotBefore })
+	return pending
+}
+
+// IsDeferred reports whether the video is waiting to be queued again
+func (d *DeferredVideos) IsDeferred(channelID string, videoID string) bool {
+	if d == nil {
+		return false
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	_, ok := d.channels[channelID][videoID]
+	return ok
+}
+
+func (d *DeferredVideos) save() error {
+	return writeJSONAtomically(d.path, d.channels)
+}
@@ -0,0 +1,60 @@
+package sdk
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/configs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeferredVideos(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deferred_videos.json")
+	d, err := DeferredVideosFromConfig(configs.DeferredConfig{StatePath: path, InitialBackoff: "1h", MaxBackoff: "3h"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	now := time.Unix(1700000000, 0)
+
+	notBefore, err := d.Defer("UCabc", "premiere", "", "Premieres in 2 hours", now.Add(2*time.Hour), now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(2*time.Hour), notBefore)
+
+	// without a release time the backoff doubles up to the maximum
+	notBefore, err = d.Defer("UCabc", "live", "https://example.com/live", "live", time.Time{}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour), notBefore)
+	notBefore, _ = d.Defer("UCabc", "live", "https://example.com/live", "live", time.Time{}, now)
+	assert.Equal(t, now.Add(2*time.Hour), notBefore)
+	notBefore, _ = d.Defer("UCabc", "live", "https://example.com/live", "live", time.Time{}, now)
+	assert.Equal(t, now.Add(3*time.Hour), notBefore)
+
+	assert.Empty(t, d.Due("UCabc", now))
+	due := d.Due("UCabc", now.Add(2*time.Hour))
+	if assert.Len(t, due, 1) {
+		assert.Equal(t, "premiere", due[0].VideoID)
+	}
+	due = d.Due("UCabc", now.Add(3*time.Hour))
+	if assert.Len(t, due, 2) {
+		assert.Equal(t, "live", due[0].VideoID)
+		// the URL is kept to fetch the video again
+		assert.Equal(t, "https://example.com/live", due[0].URL)
+		assert.Equal(t, "premiere", due[1].VideoID)
+	}
+	assert.Empty(t, d.Due("UCother", now.Add(3*time.Hour)))
+	assert.Len(t, d.Pending("UCabc"), 2)
+
+	reopened, err := NewDeferredVideos(path, time.Hour, 3*time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, reopened.IsDeferred("UCabc", "live"))
+	assert.NoError(t, reopened.Remove("UCabc", "live"))
+	assert.False(t, reopened.IsDeferred("UCabc", "live"))
+	assert.NoError(t, reopened.Remove("UCabc", "unknown"))
+
+	_, err = DeferredVideosFromConfig(configs.DeferredConfig{InitialBackoff: "soon"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package sdk
+
+import (
+	"github.com/lbryio/ytsync/v5/configs"
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+)
+
+// JobStore keeps the channels to sync along with the state of their videos.
+// APIConfig talks to internal-apis while LocalStore keeps everything on disk so that ytsync can run standalone
+type JobStore interface {
+	FetchChannels(status string, cliFlags *shared.SyncFlags) ([]shared.YoutubeChannel, error)
+	SetChannelCert(certHex string, channelID string) error
+	SetChannelStatus(channelID string, status string, failureReason string, transferState *int) (map[string]SyncedVideo, map[string]bool, error)
+	SetChannelClaimID(channelID string, channelClaimID string) error
+	DeleteVideos(videos []string) error
+	MarkVideoStatus(status shared.VideoStatus) error
+	// MarkVideoStatuses sends several updates at once. results holds what the job store answered to each of the first
+	// len(results) statuses, err is set when the others couldn't be sent
+	MarkVideoStatuses(statuses []shared.VideoStatus) (results []error, err error)
+	VideoState(videoID string) (string, error)
+	// VideoStates looks up the state of several videos at once. Unknown videos are "not_found"
+	VideoStates(videoIDs []string) (map[string]string, error)
+	GetReleasedDate(videoID string) (*VideoRelease, error)
+}
+
+var (
+	_ JobStore = (*APIConfig)(nil)
+	_ JobStore = (*LocalStore)(nil)
+)
+
+var jobStore JobStore
+
+// InitJobStore selects the backend according to the configuration: the local store when local_job_store_path is set
+// and internal-apis otherwise. It must be called once at startup, before any GetJobStore call
+func InitJobStore() error {
+	if configs.Configuration.LocalJobStorePath == "" {
+		_, err := RetryPolicyFromConfig(configs.Configuration.APIRetry)
+		if err != nil {
+			return err
+		}
+		jobStore = GetAPIsConfigs()
+		return nil
+	}
+	store, err := NewLocalStore(configs.Configuration.LocalJobStorePath)
+	if err != nil {
+		return err
+	}
+	jobStore = store
+	return nil
+}
+
+// GetJobStore returns the job store selected by InitJobStore
+func GetJobStore() JobStore {
+	if jobStore == nil {
+		return GetAPIsConfigs()
+	}
+	return jobStore
+}
+
+// markVideoStatusesOneByOne sends the updates one at a time with mark, until the job store becomes unavailable
+func markVideoStatusesOneByOne(statuses []shared.VideoStatus, mark func(shared.VideoStatus) error) ([]error, error) {
+	results := make([]error, 0, len(statuses))
+	for _, status := range statuses {
+		err := mark(status)
+		if errors.Is(err, ErrAPIUnavailable) {
+			return results, err
+		}
+		results = append(results, err)
+	}
+	return results, nil
+}
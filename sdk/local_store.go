@@ -0,0 +1,304 @@
+package sdk
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/ytsync/v5/shared"
+)
+
+const (
+	localChannelsFile = "channels.json"
+	localVideosFile   = "videos.json"
+)
+
+var ErrLocalChannelNotFound = errors.Base("channel not found in the local job store")
+
+type localChannel struct {
+	shared.YoutubeChannel
+	// PublishAddress is kept as a plain string, like internal-apis sends it. It shadows the address of the channel: it
+	// is set by AddChannel and copied into the channel when the store is loaded
+	PublishAddress string `json:"publish_address,omitempty"`
+	Status         string `json:"status"`
+	FailureReason  string `json:"failure_reason"`
+	ChannelCert    string `json:"channel_cert"`
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+type localVideo struct {
+	SyncedVideo
+	ChannelID   string `json:"channel_id"`
+	Status      string `json:"status"`
+	PublishedAt int64  `json:"published_at,omitempty"`
+}
+
+// LocalStore is a JobStore that keeps channels and videos in JSON files inside a directory.
+// Channels can be queued by adding them to channels.json (see README) or through AddChannel
+type LocalStore struct {
+	dir      string
+	mux      sync.Mutex
+	channels map[string]*localChannel
+	videos   map[string]*localVideo
+}
+
+// NewLocalStore opens the store kept in dir, creating it if it doesn't exist yet
+func NewLocalStore(dir string) (*LocalStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	s := &LocalStore{
+		dir:      dir,
+		channels: make(map[string]*localChannel),
+		videos:   make(map[string]*localVideo),
+	}
+	err = s.load(localChannelsFile, &s.channels)
+	if err != nil {
+		return nil, err
+	}
+	err = s.load(localVideosFile, &s.videos)
+	if err != nil {
+		return nil, err
+	}
+	// the keys are authoritative, hand written entries don't need to repeat the IDs
+	for id, c := range s.channels {
+		c.ChannelId = id
+		c.YoutubeChannel.PublishAddress = shared.PublishAddress{Address: c.PublishAddress}
+	}
+	for id, v := range s.videos {
+		v.VideoID = id
+	}
+	return s, nil
+}
+
+func (s *LocalStore) load(name string, into interface{}) error {
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Err(err)
+	}
+	err = json.Unmarshal(data, into)
+	if err != nil {
+		return errors.Prefix("failed to parse "+name, err)
+	}
+	return nil
+}
+
+// save writes one of the files of the store
+func (s *LocalStore) save(name string, from interface{}) error {
+	return writeJSONAtomically(filepath.Join(s.dir, name), from)
+}
+
+// writeJSONAtomically writes v as indented JSON to path through a temporary file, so that a crash never leaves a
+// truncated file behind
+func writeJSONAtomically(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Err(err)
+	}
+	err = os.WriteFile(path+".tmp", data, 0644)
+	if err != nil {
+		return errors.Err(err)
+	}
+	return errors.Err(os.Rename(path+".tmp", path))
+}
+
+// AddChannel queues a channel with the given status, replacing any previous job for it
+func (s *LocalStore) AddChannel(channel shared.YoutubeChannel, status string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := time.Now().Unix()
+	s.channels[channel.ChannelId] = &localChannel{
+		YoutubeChannel: channel,
+		PublishAddress: channel.PublishAddress.Address,
+		Status:         status,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	return s.save(localChannelsFile, s.channels)
+}
+
+func (s *LocalStore) FetchChannels(status string, cliFlags *shared.SyncFlags) ([]shared.YoutubeChannel, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var matches []*localChannel
+	for id, c := range s.channels {
+		if cliFlags.ChannelID != "" && id != cliFlags.ChannelID {
+			continue
+		}
+		if status != "" && c.Status != status {
+			continue
+		}
+		if c.IsDeletedOnYoutube && !cliFlags.WithDeletedOnYoutube {
+			continue
+		}
+		// --after and --before select jobs by the time they were queued
+		if c.CreatedAt < cliFlags.SyncFrom || (cliFlags.SyncUntil > 0 && c.CreatedAt > cliFlags.SyncUntil) {
+			continue
+		}
+		matches = append(matches, c)
+	}
+	// oldest jobs first, like a queue
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].UpdatedAt == matches[j].UpdatedAt {
+			return matches[i].ChannelId < matches[j].ChannelId
+		}
+		return matches[i].UpdatedAt < matches[j].UpdatedAt
+	})
+	channels := make([]shared.YoutubeChannel, 0, len(matches))
+	for _, c := range matches {
+		channels = append(channels, c.YoutubeChannel)
+	}
+	return channels, nil
+}
+
+func (s *LocalStore) SetChannelCert(certHex string, channelID string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	// like internal-apis, the certificate is keyed by the claim ID of the channel
+	for _, c := range s.channels {
+		if c.ChannelClaimID == channelID {
+			c.ChannelCert = certHex
+			return s.save(localChannelsFile, s.channels)
+		}
+	}
+	return errors.Prefix(channelID, ErrLocalChannelNotFound)
+}
+
+func (s *LocalStore) SetChannelStatus(channelID string, status string, failureReason string, transferState *int) (map[string]SyncedVideo, map[string]bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c, ok := s.channels[channelID]
+	if !ok {
+		return nil, nil, errors.Prefix(channelID, ErrLocalChannelNotFound)
+	}
+	sanitizeFailureReason(&failureReason)
+	c.Status = status
+	c.FailureReason = failureReason
+	c.UpdatedAt = time.Now().Unix()
+	if transferState != nil {
+		c.TransferState = *transferState
+	}
+	err := s.save(localChannelsFile, s.channels)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	svs := make(map[string]SyncedVideo)
+	claimNames := make(map[string]bool)
+	for id, v := range s.videos {
+		if v.ChannelID != channelID {
+			continue
+		}
+		svs[id] = v.SyncedVideo
+		if v.ClaimName != "" {
+			claimNames[v.ClaimName] = v.Published
+		}
+	}
+	return svs, claimNames, nil
+}
+
+func (s *LocalStore) SetChannelClaimID(channelID string, channelClaimID string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c, ok := s.channels[channelID]
+	if !ok {
+		return errors.Prefix(channelID, ErrLocalChannelNotFound)
+	}
+	c.ChannelClaimID = channelClaimID
+	return s.save(localChannelsFile, s.channels)
+}
+
+func (s *LocalStore) DeleteVideos(videos []string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, id := range videos {
+		delete(s.videos, id)
+	}
+	return s.save(localVideosFile, s.videos)
+}
+
+func (s *LocalStore) MarkVideoStatus(status shared.VideoStatus) error {
+	sanitizeFailureReason(&status.FailureReason)
+	err := validateVideoStatus(status)
+	if err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	v, ok := s.videos[status.VideoID]
+	if !ok {
+		v = &localVideo{}
+		s.videos[status.VideoID] = v
+	}
+	v.VideoID = status.VideoID
+	v.ChannelID = status.ChannelID
+	v.Status = status.Status
+	v.FailureReason = status.FailureReason
+	switch status.Status {
+	case VideoStatusPublished:
+		v.Published = true
+		v.PublishedAt = status.PublishedAt
+		if v.PublishedAt == 0 {
+			v.PublishedAt = time.Now().Unix()
+		}
+	case VideoStatusFailed, shared.VideoStatusUnpublished:
+		v.Published = false
+	}
+	if status.ClaimID != "" {
+		v.ClaimID = status.ClaimID
+	}
+	if status.ClaimName != "" {
+		v.ClaimName = status.ClaimName
+	}
+	if status.MetaDataVersion > 0 {
+		v.MetadataVersion = int8(status.MetaDataVersion)
+	}
+	if status.Size != nil {
+		v.Size = *status.Size
+	}
+	if status.IsTransferred != nil {
+		v.Transferred = *status.IsTransferred
+	}
+	return s.save(localVideosFile, s.videos)
+}
+
+func (s *LocalStore) MarkVideoStatuses(statuses []shared.VideoStatus) ([]error, error) {
+	return markVideoStatusesOneByOne(statuses, s.MarkVideoStatus)
+}
+
+func (s *LocalStore) VideoState(videoID string) (string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	v, ok := s.videos[videoID]
+	if !ok {
+		return "not_found", nil
+	}
+	return v.Status, nil
+}
+
+func (s *LocalStore) VideoStates(videoIDs []string) (map[string]string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	states := make(map[string]string, len(videoIDs))
+	for _, videoID := range videoIDs {
+		states[videoID] = "not_found"
+		if v, ok := s.videos[videoID]; ok {
+			states[videoID] = v.Status
+		}
+	}
+	return states, nil
+}
+
+// GetReleasedDate always reports that the release date is unknown: the local store has no external source for it
+func (s *LocalStore) GetReleasedDate(videoID string) (*VideoRelease, error) {
+	return nil, nil
+}
@@ -0,0 +1,95 @@
+package sdk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	assert.NoError(t, err)
+
+	err = store.AddChannel(shared.YoutubeChannel{ChannelId: "UCabc", DesiredChannelName: "@abc"}, shared.StatusQueued)
+	assert.NoError(t, err)
+	err = store.AddChannel(shared.YoutubeChannel{ChannelId: "UCdef", DesiredChannelName: "@def", PublishAddress: shared.PublishAddress{Address: "bDEF"}}, shared.StatusSynced)
+	assert.NoError(t, err)
+
+	channels, err := store.FetchChannels(shared.StatusQueued, &shared.SyncFlags{})
+	assert.NoError(t, err)
+	assert.Len(t, channels, 1)
+	assert.Equal(t, "@abc", channels[0].DesiredChannelName)
+
+	size := int64(1337)
+	err = store.MarkVideoStatus(shared.VideoStatus{ChannelID: "UCabc", VideoID: "vid1", Status: VideoStatusPublished, ClaimID: "claim1", ClaimName: "video-1", Size: &size, MetaDataVersion: 2})
+	assert.NoError(t, err)
+	err = store.MarkVideoStatus(shared.VideoStatus{ChannelID: "UCabc", VideoID: "vid2", Status: VideoStatusFailed, FailureReason: "Private video"})
+	assert.NoError(t, err)
+	err = store.MarkVideoStatus(shared.VideoStatus{ChannelID: "UCabc", VideoID: "vid3", Status: VideoStatusPublished})
+	assert.Error(t, err)
+
+	// reopen the store to make sure that everything was persisted
+	store, err = NewLocalStore(dir)
+	assert.NoError(t, err)
+	channels, err = store.FetchChannels(shared.StatusSynced, &shared.SyncFlags{})
+	assert.NoError(t, err)
+	assert.Len(t, channels, 1)
+	assert.Equal(t, "bDEF", channels[0].PublishAddress.Address)
+	syncedVideos, claimNames, err := store.SetChannelStatus("UCabc", shared.StatusSyncing, "", nil)
+	assert.NoError(t, err)
+	assert.Len(t, syncedVideos, 2)
+	assert.True(t, syncedVideos["vid1"].Published)
+	assert.Equal(t, int64(1337), syncedVideos["vid1"].Size)
+	assert.Equal(t, int8(2), syncedVideos["vid1"].MetadataVersion)
+	assert.Equal(t, "Private video", syncedVideos["vid2"].FailureReason)
+	assert.Equal(t, map[string]bool{"video-1": true}, claimNames)
+
+	state, err := store.VideoState("vid1")
+	assert.NoError(t, err)
+	assert.Equal(t, VideoStatusPublished, state)
+	state, err = store.VideoState("missing")
+	assert.NoError(t, err)
+	assert.Equal(t, "not_found", state)
+	states, err := store.VideoStates([]string{"vid1", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"vid1": VideoStatusPublished, "missing": "not_found"}, states)
+
+	assert.NoError(t, store.DeleteVideos([]string{"vid2"}))
+	syncedVideos, _, err = store.SetChannelStatus("UCabc", shared.StatusSynced, "", nil)
+	assert.NoError(t, err)
+	assert.Len(t, syncedVideos, 1)
+
+	_, _, err = store.SetChannelStatus("UCmissing", shared.StatusSyncing, "", nil)
+	assert.True(t, errors.Is(err, ErrLocalChannelNotFound))
+}
+
+func TestLocalStoreHandWrittenChannels(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, localChannelsFile), []byte(`{
+		"UCabc": {"desired_channel_name": "@abc", "status": "queued", "publish_address": "bXYZ"}
+	}`), 0644)
+	assert.NoError(t, err)
+
+	store, err := NewLocalStore(dir)
+	assert.NoError(t, err)
+	channels, err := store.FetchChannels(shared.StatusQueued, &shared.SyncFlags{ChannelID: "UCabc"})
+	assert.NoError(t, err)
+	assert.Len(t, channels, 1)
+	assert.Equal(t, "UCabc", channels[0].ChannelId)
+	assert.Equal(t, "bXYZ", channels[0].PublishAddress.Address)
+
+	// saving must keep the file readable
+	assert.NoError(t, store.SetChannelClaimID("UCabc", "claimid"))
+	store, err = NewLocalStore(dir)
+	assert.NoError(t, err)
+	channels, err = store.FetchChannels("", &shared.SyncFlags{})
+	assert.NoError(t, err)
+	assert.Equal(t, "claimid", channels[0].ChannelClaimID)
+	assert.Equal(t, "bXYZ", channels[0].PublishAddress.Address)
+}
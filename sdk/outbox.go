@@ -0,0 +1,266 @@
+package sdk
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+	"github.com/lbryio/ytsync/v5/shared"
+	"github.com/lbryio/ytsync/v5/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	outboxBatchSize     = 50
+	outboxFlushInterval = 10 * time.Second
+)
+
+type outboxEntry struct {
+	ID     uint64             `json:"id"`
+	Status shared.VideoStatus `json:"status"`
+}
+
+// StatusOutbox journals video status updates on disk before they are sent to the job store.
+// Updates are sent in batches by a background flusher and whatever could not be sent is replayed by the next run,
+// so a claim published right before a crash or during an internal-apis outage is never forgotten
+type StatusOutbox struct {
+	path  string
+	store JobStore
+
+	mux     sync.Mutex
+	pending []outboxEntry
+	nextID  uint64
+
+	flushMux sync.Mutex
+	wake     chan struct{}
+	grp      *stop.Group
+}
+
+// NewStatusOutbox opens the journal at path, loading the updates left behind by a previous run
+func NewStatusOutbox(path string, store JobStore) (*StatusOutbox, error) {
+	o := &StatusOutbox{
+		path:  path,
+		store: store,
+		wake:  make(chan struct{}, 1),
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	damaged := false
+	for scanner.Scan() {
+		var entry outboxEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			// only the last line can be damaged, by a crash in the middle of a write
+			log.Warnf("skipping unreadable entry in %s: %s", path, err.Error())
+			damaged = true
+			continue
+		}
+		o.pending = append(o.pending, entry)
+		if entry.ID >= o.nextID {
+			o.nextID = entry.ID + 1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Err(err)
+	}
+	if damaged {
+		// the damaged line has no newline, the next update would be appended to it and lost as well
+		err = o.rewrite()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(o.pending) > 0 {
+		log.Infof("%d video status updates left from the previous run will be replayed", len(o.pending))
+	}
+	return o, nil
+}
+
+// MarkVideoStatus journals the update. It is sent to the job store by the next flush
+func (o *StatusOutbox) MarkVideoStatus(status shared.VideoStatus) error {
+	err := validateVideoStatus(status)
+	if err != nil {
+		return err
+	}
+	if status.PublishedAt == 0 {
+		status.PublishedAt = time.Now().Unix()
+	}
+	o.mux.Lock()
+	entry := outboxEntry{ID: o.nextID, Status: status}
+	err = o.append(entry)
+	if err != nil {
+		o.mux.Unlock()
+		return err
+	}
+	o.nextID++
+	o.pending = append(o.pending, entry)
+	full := len(o.pending) >= outboxBatchSize
+	o.mux.Unlock()
+	if full {
+		select {
+		case o.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (o *StatusOutbox) append(entry outboxEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Err(err)
+	}
+	f, err := os.OpenFile(o.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Err(err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return errors.Err(err)
+	}
+	return errors.Err(f.Sync())
+}
+
+// rewrite replaces the journal with the pending entries. It must be called with mux held
+func (o *StatusOutbox) rewrite() error {
+	if len(o.pending) == 0 {
+		err := os.Remove(o.path)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Err(err)
+		}
+		return nil
+	}
+	tmpPath := o.path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return errors.Err(err)
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range o.pending {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			f.Close()
+			return errors.Err(err)
+		}
+		_, _ = w.Write(append(line, '\n'))
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err != nil {
+		return errors.Err(err)
+	}
+	if closeErr != nil {
+		return errors.Err(closeErr)
+	}
+	return errors.Err(os.Rename(tmpPath, o.path))
+}
+
+// Pending returns the updates for the channel that were not sent yet, oldest first
+func (o *StatusOutbox) Pending(channelID string) []shared.VideoStatus {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	var statuses []shared.VideoStatus
+	for _, entry := range o.pending {
+		if entry.Status.ChannelID == channelID {
+			statuses = append(statuses, entry.Status)
+		}
+	}
+	return statuses
+}
+
+// Flush sends the pending updates in batches until none is left or the job store becomes unavailable
+func (o *StatusOutbox) Flush() error {
+	o.flushMux.Lock()
+	defer o.flushMux.Unlock()
+	for {
+		o.mux.Lock()
+		batch := make([]outboxEntry, 0, outboxBatchSize)
+		for i := 0; i < len(o.pending) && i < outboxBatchSize; i++ {
+			batch = append(batch, o.pending[i])
+		}
+		o.mux.Unlock()
+		if len(batch) == 0 {
+			return nil
+		}
+
+		statuses := make([]shared.VideoStatus, len(batch))
+		for i, entry := range batch {
+			statuses[i] = entry.Status
+		}
+		results, flushErr := o.store.MarkVideoStatuses(statuses)
+		done := make(map[uint64]bool, len(results))
+		for i, err := range results {
+			if err != nil {
+				// the job store refused the update: keeping it would block the journal forever
+				util.SendErrorToSlack("Failed to mark video %s on the database, dropping the update: %s", batch[i].Status.VideoID, errors.FullTrace(err))
+			}
+			done[batch[i].ID] = true
+		}
+
+		o.mux.Lock()
+		remaining := o.pending[:0]
+		for _, entry := range o.pending {
+			if !done[entry.ID] {
+				remaining = append(remaining, entry)
+			}
+		}
+		o.pending = remaining
+		err := o.rewrite()
+		o.mux.Unlock()
+		if err != nil {
+			return err
+		}
+		if flushErr != nil {
+			return flushErr
+		}
+	}
+}
+
+// Start flushes the journal in the background until Close is called
+func (o *StatusOutbox) Start() {
+	o.grp = stop.New()
+	o.grp.Add(1)
+	go func() {
+		defer o.grp.Done()
+		ticker := time.NewTicker(outboxFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-o.grp.Ch():
+				return
+			case <-ticker.C:
+			case <-o.wake:
+			}
+			err := o.Flush()
+			if err != nil {
+				log.Errorf("failed to flush the video status outbox, will try again later: %s", err.Error())
+			}
+		}
+	}()
+}
+
+// Close stops the background flusher and makes a last attempt at sending the pending updates.
+// Whatever is still pending stays in the journal for the next run
+func (o *StatusOutbox) Close() error {
+	if o.grp != nil {
+		o.grp.StopAndWait()
+	}
+	return o.Flush()
+}
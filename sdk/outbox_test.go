@@ -0,0 +1,113 @@
+package sdk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/ytsync/v5/configs"
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyStore records the updates it receives and fails with ErrAPIUnavailable while down is set
+type flakyStore struct {
+	*LocalStore
+	down     bool
+	received []string
+	batches  int
+}
+
+func (f *flakyStore) MarkVideoStatus(status shared.VideoStatus) error {
+	if f.down {
+		return &APIUnavailableError{Endpoint: "/yt/video_status", Attempts: 1, Err: errors.Err("503")}
+	}
+	f.received = append(f.received, status.VideoID)
+	return f.LocalStore.MarkVideoStatus(status)
+}
+
+func (f *flakyStore) MarkVideoStatuses(statuses []shared.VideoStatus) ([]error, error) {
+	f.batches++
+	return markVideoStatusesOneByOne(statuses, f.MarkVideoStatus)
+}
+
+func TestStatusOutboxReplaysAfterOutage(t *testing.T) {
+	dir := t.TempDir()
+	local, err := NewLocalStore(dir)
+	assert.NoError(t, err)
+	store := &flakyStore{LocalStore: local, down: true}
+	path := filepath.Join(dir, "outbox.jsonl")
+
+	outbox, err := NewStatusOutbox(path, store)
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.MarkVideoStatus(shared.VideoStatus{ChannelID: "UCabc", VideoID: "vid1", Status: VideoStatusPublished, ClaimID: "claim1", ClaimName: "video-1", PublishedAt: 1600000000}))
+	assert.NoError(t, outbox.MarkVideoStatus(shared.VideoStatus{ChannelID: "UCabc", VideoID: "vid2", Status: VideoStatusFailed, FailureReason: "Private video"}))
+	assert.NoError(t, outbox.MarkVideoStatus(shared.VideoStatus{ChannelID: "UCdef", VideoID: "vid3", Status: VideoStatusFailed}))
+	// invalid updates are refused right away instead of being journaled
+	assert.Error(t, outbox.MarkVideoStatus(shared.VideoStatus{ChannelID: "UCabc", VideoID: "vid4", Status: VideoStatusPublished}))
+
+	err = outbox.Flush()
+	assert.True(t, errors.Is(err, ErrAPIUnavailable))
+	assert.Len(t, outbox.Pending("UCabc"), 2)
+	assert.Len(t, outbox.Pending("UCdef"), 1)
+
+	// simulate a crash in the middle of an append
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"id": 3, "sta`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	outbox, err = NewStatusOutbox(path, store)
+	assert.NoError(t, err)
+	assert.Len(t, outbox.Pending("UCabc"), 2)
+	// the damaged line is dropped from the journal so that it doesn't swallow the next update
+	assert.NoError(t, outbox.MarkVideoStatus(shared.VideoStatus{ChannelID: "UCabc", VideoID: "vid5", Status: VideoStatusFailed}))
+
+	store.down = false
+	outbox, err = NewStatusOutbox(path, store)
+	assert.NoError(t, err)
+	assert.Len(t, outbox.Pending("UCabc"), 3)
+	assert.NoError(t, outbox.Close())
+	assert.Equal(t, []string{"vid1", "vid2", "vid3", "vid5"}, store.received)
+	// the attempt during the outage, then a single batch for every pending update
+	assert.Equal(t, 2, store.batches)
+	assert.Empty(t, outbox.Pending("UCabc"))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	state, err := local.VideoState("vid1")
+	assert.NoError(t, err)
+	assert.Equal(t, VideoStatusPublished, state)
+	// the replayed update keeps the time it was made at
+	assert.Equal(t, int64(1600000000), local.videos["vid1"].PublishedAt)
+}
+
+func TestStatusOutboxDropsRejectedUpdates(t *testing.T) {
+	dir := t.TempDir()
+	local, err := NewLocalStore(dir)
+	assert.NoError(t, err)
+	if configs.Configuration == nil {
+		// dropped updates are reported to slack, which is disabled by an empty configuration
+		configs.Configuration = &configs.Configs{}
+	}
+	outbox, err := NewStatusOutbox(filepath.Join(dir, "outbox.jsonl"), &rejectingStore{LocalStore: local})
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.MarkVideoStatus(shared.VideoStatus{ChannelID: "UCabc", VideoID: "vid1", Status: VideoStatusFailed}))
+	assert.NoError(t, outbox.Flush())
+	assert.Empty(t, outbox.Pending("UCabc"))
+}
+
+type rejectingStore struct {
+	*LocalStore
+}
+
+func (r *rejectingStore) MarkVideoStatus(status shared.VideoStatus) error {
+	return errors.Err("video not found")
+}
+
+func (r *rejectingStore) MarkVideoStatuses(statuses []shared.VideoStatus) ([]error, error) {
+	return markVideoStatusesOneByOne(statuses, r.MarkVideoStatus)
+}
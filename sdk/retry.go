@@ -0,0 +1,211 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+	"github.com/lbryio/ytsync/v5/configs"
+	"github.com/lbryio/ytsync/v5/metrics"
+	"github.com/lbryio/ytsync/v5/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy describes how failed internal-apis calls are retried: the wait between attempts starts at InitialBackoff
+// and is multiplied by Multiplier after each attempt (up to MaxBackoff), with a random jitter of +/- Jitter (0 to 1).
+// The call gives up after MaxAttempts attempts or once MaxElapsed has passed, whichever comes first. Zero means no limit
+type RetryPolicy struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+	MaxAttempts    int
+	MaxElapsed     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	InitialBackoff: 5 * time.Second,
+	MaxBackoff:     2 * time.Minute,
+	Multiplier:     2,
+	Jitter:         0.2,
+	MaxElapsed:     30 * time.Minute,
+}
+
+// RetryPolicyFromConfig overrides the defaults with the values set in the configuration
+func RetryPolicyFromConfig(c configs.APIRetryConfig) (RetryPolicy, error) {
+	p := DefaultRetryPolicy
+	durations := []struct {
+		value string
+		into  *time.Duration
+	}{
+		{c.InitialBackoff, &p.InitialBackoff},
+		{c.MaxBackoff, &p.MaxBackoff},
+		{c.MaxElapsed, &p.MaxElapsed},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return p, errors.Err(err)
+		}
+		*d.into = parsed
+	}
+	if c.Multiplier != 0 {
+		p.Multiplier = c.Multiplier
+	}
+	if c.Jitter != 0 {
+		p.Jitter = c.Jitter
+	}
+	if c.MaxAttempts != 0 {
+		p.MaxAttempts = c.MaxAttempts
+	}
+	if p.Multiplier < 1 || p.Jitter < 0 || p.Jitter > 1 || p.InitialBackoff <= 0 {
+		return p, errors.Err("invalid api retry policy: %+v", p)
+	}
+	return p, nil
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		wait *= p.Multiplier
+		if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+			wait = float64(p.MaxBackoff)
+			break
+		}
+	}
+	wait += wait * p.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(wait)
+}
+
+// ErrAPIUnavailable can be matched with errors.Is against any APIUnavailableError
+var ErrAPIUnavailable = errors.Base("internal-apis is unavailable")
+
+// APIUnavailableError is returned when an endpoint kept failing until the retry policy gave up or the sync was stopped
+type APIUnavailableError struct {
+	Endpoint string
+	Attempts int
+	Err      error
+}
+
+func (e *APIUnavailableError) Error() string {
+	return fmt.Sprintf("%s: %s failed %d times: %s", ErrAPIUnavailable.Error(), e.Endpoint, e.Attempts, e.Err.Error())
+}
+
+func (e *APIUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+func (e *APIUnavailableError) Is(target error) bool {
+	return target == ErrAPIUnavailable
+}
+
+// Retry calls attempt until it reports that there is no need to retry, the policy gives up or ctx is cancelled.
+// The first attempt is always made, even with a cancelled context, so that final status updates still go through
+func (p RetryPolicy) Retry(ctx context.Context, endpoint string, attempt func() (retry bool, err error)) error {
+	start := time.Now()
+	for attempts := 1; ; attempts++ {
+		retry, err := attempt()
+		if !retry {
+			return err
+		}
+		wait := p.backoff(attempts)
+		if (p.MaxAttempts > 0 && attempts >= p.MaxAttempts) || (p.MaxElapsed > 0 && time.Since(start)+wait > p.MaxElapsed) {
+			metrics.APICalls.WithLabelValues(endpoint, "unavailable").Inc()
+			return &APIUnavailableError{Endpoint: endpoint, Attempts: attempts, Err: err}
+		}
+		metrics.APIRetries.WithLabelValues(endpoint).Inc()
+		select {
+		case <-ctx.Done():
+			metrics.APICalls.WithLabelValues(endpoint, "cancelled").Inc()
+			return &APIUnavailableError{Endpoint: endpoint, Attempts: attempts, Err: err}
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryUnlessOK retries every response that isn't a 200
+func retryUnlessOK(statusCode int) bool {
+	return statusCode != http.StatusOK
+}
+
+// retryUnlessFound also accepts a 404, for endpoints where it is a meaningful answer
+func retryUnlessFound(statusCode int) bool {
+	return statusCode != http.StatusOK && statusCode != http.StatusNotFound
+}
+
+// retryServerErrors only retries when internal-apis itself failed
+func retryServerErrors(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError
+}
+
+// post sends the form to the endpoint (a path such as /yt/jobs) and returns the body of the response.
+// Network errors and the status codes selected by shouldRetry are retried according to the retry policy
+func (a *APIConfig) post(path string, vals url.Values, shouldRetry func(statusCode int) bool) ([]byte, int, error) {
+	endpoint := a.ApiURL + path
+	var body []byte
+	var statusCode int
+	err := a.retryPolicy.Retry(a.context(), path, func() (bool, error) {
+		start := time.Now()
+		defer func(start time.Time) {
+			metrics.APIDurations.WithLabelValues(path).Observe(time.Since(start).Seconds())
+		}(start)
+		res, err := a.client.PostForm(endpoint, vals)
+		if err != nil {
+			metrics.APICalls.WithLabelValues(path, "network_error").Inc()
+			util.SendErrorToSlack("error while trying to call %s. Waiting to retry: %s", endpoint, err.Error())
+			return true, errors.Err(err)
+		}
+		defer res.Body.Close()
+		body, err = io.ReadAll(res.Body)
+		if err != nil {
+			metrics.APICalls.WithLabelValues(path, "network_error").Inc()
+			return true, errors.Err(err)
+		}
+		statusCode = res.StatusCode
+		if shouldRetry(statusCode) {
+			metrics.APICalls.WithLabelValues(path, fmt.Sprintf("%d", statusCode)).Inc()
+			util.SendErrorToSlack("Error %d while trying to call %s. Waiting to retry", statusCode, endpoint)
+			log.Debugln(string(body))
+			return true, errors.Err("invalid API response. Status code: %d", statusCode)
+		}
+		metrics.APICalls.WithLabelValues(path, "ok").Inc()
+		return false, nil
+	})
+	return body, statusCode, err
+}
+
+func (a *APIConfig) context() context.Context {
+	a.ctxMux.RLock()
+	defer a.ctxMux.RUnlock()
+	if a.ctx == nil {
+		return context.Background()
+	}
+	return a.ctx
+}
+
+// SetContext makes pending retries give up as soon as ctx is done
+func (a *APIConfig) SetContext(ctx context.Context) {
+	a.ctxMux.Lock()
+	defer a.ctxMux.Unlock()
+	a.ctx = ctx
+}
+
+// BindStopGroup makes pending retries give up as soon as grp is stopped
+func (a *APIConfig) BindStopGroup(grp *stop.Group) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-grp.Ch()
+		cancel()
+	}()
+	a.SetContext(ctx)
+}
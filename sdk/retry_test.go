@@ -0,0 +1,188 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/ytsync/v5/configs"
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fastRetryPolicy = RetryPolicy{
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+	Multiplier:     2,
+	Jitter:         0.5,
+	MaxAttempts:    4,
+}
+
+func testAPI(t *testing.T, handler http.HandlerFunc) *APIConfig {
+	if configs.Configuration == nil {
+		// failed attempts are reported to slack and graylog, which are disabled by an empty configuration
+		configs.Configuration = &configs.Configs{}
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &APIConfig{
+		ApiURL:      server.URL,
+		client:      server.Client(),
+		retryPolicy: fastRetryPolicy,
+	}
+}
+
+func TestPostRetriesUntilSuccess(t *testing.T) {
+	var calls int32
+	api := testAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"success": true, "error": null, "data": "published"}`))
+	})
+	state, err := api.VideoState("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "published", state)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
+
+func TestPostGivesUp(t *testing.T) {
+	var calls int32
+	api := testAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	err := api.SetChannelClaimID("channel", "claim")
+	assert.True(t, errors.Is(err, ErrAPIUnavailable))
+	var unavailable *APIUnavailableError
+	assert.ErrorAs(t, err, &unavailable)
+	assert.Equal(t, "/yt/set_channel_claim_id", unavailable.Endpoint)
+	assert.Equal(t, 4, unavailable.Attempts)
+	assert.EqualValues(t, 4, atomic.LoadInt32(&calls))
+}
+
+func TestPostStopsRetryingWhenCancelled(t *testing.T) {
+	var calls int32
+	api := testAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	api.retryPolicy.InitialBackoff = time.Hour
+	api.retryPolicy.MaxBackoff = time.Hour
+	api.retryPolicy.MaxAttempts = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	api.SetContext(ctx)
+	_, err := api.GetReleasedDate("abc")
+	assert.True(t, errors.Is(err, ErrAPIUnavailable))
+	// the first attempt is made even when the context is already cancelled
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestNotFoundIsNotRetried(t *testing.T) {
+	var calls int32
+	api := testAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	state, err := api.VideoState("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "not_found", state)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Multiplier: 2}
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 4*time.Second, p.backoff(3))
+	assert.Equal(t, 10*time.Second, p.backoff(10))
+}
+
+func TestVideoStatesFallsBackToVideoState(t *testing.T) {
+	var batched, single int32
+	api := testAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/yt/video_states":
+			atomic.AddInt32(&batched, 1)
+			w.WriteHeader(http.StatusNotFound)
+		case "/yt/video_state":
+			atomic.AddInt32(&single, 1)
+			if r.FormValue("video_id") == "missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(`{"success": true, "error": null, "data": "published"}`))
+		}
+	})
+	states, err := api.VideoStates([]string{"vid1", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"vid1": "published", "missing": "not_found"}, states)
+	// the batch endpoint isn't tried again
+	_, err = api.VideoStates([]string{"vid1"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&batched))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&single))
+}
+
+func TestVideoStatesFallsBackOnUnknownMethod(t *testing.T) {
+	api := testAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/yt/video_states" {
+			_, _ = w.Write([]byte(`{"success": false, "error": "unknown method video_states", "data": null}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success": true, "error": null, "data": "failed"}`))
+	})
+	states, err := api.VideoStates([]string{"vid1"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"vid1": "failed"}, states)
+}
+
+func TestMarkVideoStatusesInOneCall(t *testing.T) {
+	var batched int32
+	api := testAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/yt/video_statuses" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		atomic.AddInt32(&batched, 1)
+		_, _ = w.Write([]byte(`{"success": true, "error": null, "data": [null, "video not found"]}`))
+	})
+	results, err := api.MarkVideoStatuses([]shared.VideoStatus{
+		{ChannelID: "UCabc", VideoID: "vid1", Status: VideoStatusFailed},
+		{ChannelID: "UCabc", VideoID: "vid2", Status: VideoStatusPublished},
+		{ChannelID: "UCabc", VideoID: "vid3", Status: VideoStatusFailed},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.NoError(t, results[0])
+	// the update without a claim is refused without being sent
+	assert.Error(t, results[1])
+	assert.Error(t, results[2])
+	assert.EqualValues(t, 1, atomic.LoadInt32(&batched))
+}
+
+func TestMarkVideoStatusesFallsBackToMarkVideoStatus(t *testing.T) {
+	var single int32
+	api := testAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/yt/video_statuses":
+			w.WriteHeader(http.StatusNotFound)
+		case "/yt/video_status":
+			atomic.AddInt32(&single, 1)
+			_, _ = w.Write([]byte(`{"success": true, "error": null, "data": "ok"}`))
+		}
+	})
+	results, err := api.MarkVideoStatuses([]shared.VideoStatus{
+		{ChannelID: "UCabc", VideoID: "vid1", Status: VideoStatusFailed},
+		{ChannelID: "UCabc", VideoID: "vid2", Status: VideoStatusFailed},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, nil}, results)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&single))
+}
@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/ytsync/v5/configs"
+)
+
+const (
+	defaultFullScanInterval = 24 * time.Hour
+	defaultFullScanLogPath  = "full_scans.json"
+)
+
+// FullScanLog remembers when the videos of each channel were last listed in full. In between, new uploads are
+// found through the channel feeds. It is kept in a JSON file of unix timestamps by channel ID
+type FullScanLog struct {
+	path     string
+	interval time.Duration
+	mux      sync.Mutex
+	scans    map[string]int64
+}
+
+// NewFullScanLog opens the log kept at path. Channels are listed in full again once interval has passed
+func NewFullScanLog(path string, interval time.Duration) (*FullScanLog, error) {
+	l := &FullScanLog{
+		path:     path,
+		interval: interval,
+		scans:    make(map[string]int64),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	err = json.Unmarshal(data, &l.scans)
+	if err != nil {
+		return nil, errors.Prefix("failed to parse "+path, err)
+	}
+	return l, nil
+}
+
+// FullScanLogFromConfig opens the log configured in new_uploads_feed. It returns nil when the feeds are disabled
+func FullScanLogFromConfig(c configs.FeedConfig) (*FullScanLog, error) {
+	if c.Disabled {
+		return nil, nil
+	}
+	interval := defaultFullScanInterval
+	if c.FullScanInterval != "" {
+		var err error
+		interval, err = time.ParseDuration(c.FullScanInterval)
+		if err != nil {
+			return nil, errors.Prefix("invalid full_scan_interval", err)
+		}
+	}
+	path := c.StatePath
+	if path == "" {
+		path = defaultFullScanLogPath
+	}
+	return NewFullScanLog(path, interval)
+}
+
+// FullScanDue reports whether the channel must be listed in full. A nil log always requires full listings
+func (l *FullScanLog) FullScanDue(channelID string, now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	last, ok := l.scans[channelID]
+	return !ok || now.Sub(time.Unix(last, 0)) >= l.interval
+}
+
+// RecordFullScan saves the time the channel was listed in full
+func (l *FullScanLog) RecordFullScan(channelID string, at time.Time) error {
+	if l == nil {
+		return nil
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.scans[channelID] = at.Unix()
+	return writeJSONAtomically(l.path, l.scans)
+}
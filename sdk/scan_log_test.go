@@ -0,0 +1,38 @@
+package sdk
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/configs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFullScanLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "full_scans.json")
+	l, err := FullScanLogFromConfig(configs.FeedConfig{FullScanInterval: "6h", StatePath: path})
+	if !assert.NoError(t, err) {
+		return
+	}
+	now := time.Unix(1700000000, 0)
+	assert.True(t, l.FullScanDue("UCabc", now))
+	assert.NoError(t, l.RecordFullScan("UCabc", now))
+	assert.False(t, l.FullScanDue("UCabc", now.Add(5*time.Hour)))
+	assert.True(t, l.FullScanDue("UCabc", now.Add(6*time.Hour)))
+	assert.True(t, l.FullScanDue("UCother", now))
+
+	reopened, err := NewFullScanLog(path, 6*time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, reopened.FullScanDue("UCabc", now.Add(time.Hour)))
+
+	disabled, err := FullScanLogFromConfig(configs.FeedConfig{Disabled: true})
+	assert.NoError(t, err)
+	assert.Nil(t, disabled)
+	assert.True(t, disabled.FullScanDue("UCabc", now))
+	assert.NoError(t, disabled.RecordFullScan("UCabc", now))
+
+	_, err = FullScanLogFromConfig(configs.FeedConfig{FullScanInterval: "daily"})
+	assert.Error(t, err)
+}
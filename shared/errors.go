@@ -0,0 +1,425 @@
+package shared
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+)
+
+// ErrorCategory describes how the sync process should react to an error
+type ErrorCategory string
+
+const (
+	// CategoryQuotaExceeded errors mean that the daily quota of the YouTube API is spent until it's reset
+	CategoryQuotaExceeded ErrorCategory = "quota_exceeded"
+	// CategoryTransient errors are expected to go away by simply trying again
+	CategoryTransient ErrorCategory = "transient"
+	// CategoryThrottled errors are caused by youtube rate limiting the IP in use
+	CategoryThrottled ErrorCategory = "throttled"
+	// CategoryWallet errors require the wallet to be refilled or its UTXOs to be split before retrying
+	CategoryWallet ErrorCategory = "wallet"
+	// CategoryBlockchain errors require a new block to be mined before retrying
+	CategoryBlockchain ErrorCategory = "blockchain"
+	// CategoryPermanentVideo errors mean that the video can never be synced
+	CategoryPermanentVideo ErrorCategory = "permanent_video"
+	// CategoryAgeRestricted errors mean that the video can only be synced by the runs using age verified cookies
+	CategoryAgeRestricted ErrorCategory = "age_restricted"
+	// CategoryUnavailableVideo errors mean that the video can't be synced during this run but might be in the future
+	CategoryUnavailableVideo ErrorCategory = "unavailable_video"
+	// CategoryDeferred errors mean that the video isn't ready yet (premieres, lives) and should be queued again later
+	CategoryDeferred ErrorCategory = "deferred"
+	// CategoryStaleWallet errors mean that the wallet is out of sync with the chain and the channel needs a DB wipe
+	CategoryStaleWallet ErrorCategory = "stale_wallet"
+	// CategoryFatalHost errors mean that this host (daemon, disk, channel setup) can't keep syncing the channel
+	CategoryFatalHost ErrorCategory = "fatal_host"
+	// CategoryNeedsManualIntervention errors must stop the whole process until someone looks at them
+	CategoryNeedsManualIntervention ErrorCategory = "needs_manual_intervention"
+)
+
+// The sentinels below can be used with errors.Is to find out the category of a classified error
+var (
+	ErrQuotaExceeded             = errors.Base("youtube api quota exceeded")
+	ErrTransient                 = errors.Base("transient error")
+	ErrThrottled                 = errors.Base("throttled")
+	ErrWallet                    = errors.Base("wallet error")
+	ErrBlockchain                = errors.Base("blockchain error")
+	ErrPermanentVideo            = errors.Base("video can never be synced")
+	ErrAgeRestricted             = errors.Base("video is age restricted")
+	ErrUnavailableVideo          = errors.Base("video is currently unavailable")
+	ErrDeferred                  = errors.Base("video is not ready yet")
+	ErrStaleWallet               = errors.Base("wallet is out of sync")
+	ErrFatalHost                 = errors.Base("fatal host error")
+	ErrNeedsManualIntervention   = errors.Base("manual intervention required")
+	ErrUnknownErrorCategory      = errors.Base("unknown error category")
+	ErrInvalidErrorClassRuleFile = errors.Base("invalid error rules file")
+)
+
+var categorySentinels = map[ErrorCategory]error{
+	CategoryQuotaExceeded:           ErrQuotaExceeded,
+	CategoryTransient:               ErrTransient,
+	CategoryThrottled:               ErrThrottled,
+	CategoryWallet:                  ErrWallet,
+	CategoryBlockchain:              ErrBlockchain,
+	CategoryPermanentVideo:          ErrPermanentVideo,
+	CategoryAgeRestricted:           ErrAgeRestricted,
+	CategoryUnavailableVideo:        ErrUnavailableVideo,
+	CategoryDeferred:                ErrDeferred,
+	CategoryStaleWallet:             ErrStaleWallet,
+	CategoryFatalHost:               ErrFatalHost,
+	CategoryNeedsManualIntervention: ErrNeedsManualIntervention,
+}
+
+// ClassifiedError attaches an ErrorCategory to an error.
+// errors.Is matches both the wrapped error and the sentinel of the category (e.g. ErrWallet)
+type ClassifiedError struct {
+	Category ErrorCategory
+	Err      error
+	// NotBefore is when a deferred video is expected to be ready, zero when it isn't known
+	NotBefore time.Time
+}
+
+// NewClassifiedError wraps err into the given category
+func NewClassifiedError(category ErrorCategory, err error) *ClassifiedError {
+	return &ClassifiedError{Category: category, Err: err}
+}
+
+// NewDeferredError wraps err into CategoryDeferred, notBefore can be zero when it isn't known
+func NewDeferredError(err error, notBefore time.Time) *ClassifiedError {
+	return &ClassifiedError{Category: CategoryDeferred, Err: err, NotBefore: notBefore}
+}
+
+func (c *ClassifiedError) Error() string {
+	return c.Err.Error()
+}
+
+func (c *ClassifiedError) Unwrap() error {
+	return c.Err
+}
+
+func (c *ClassifiedError) Is(target error) bool {
+	return categorySentinels[c.Category] == target
+}
+
+// ErrorRule assigns a category to every error whose message contains Contains or matches Regex
+type ErrorRule struct {
+	Category ErrorCategory `json:"category"`
+	Contains string        `json:"contains,omitempty"`
+	Regex    string        `json:"regex,omitempty"`
+	// CausedByVideo marks the errors that are about the video itself (private, removed, blocked...), unlike other
+	// errors of the same category which can be caused by the network, the IP in use or a broken yt-dlp
+	CausedByVideo bool `json:"caused_by_video,omitempty"`
+
+	re *regexp.Regexp
+}
+
+func (r *ErrorRule) compile() error {
+	if _, ok := categorySentinels[r.Category]; !ok {
+		return errors.Prefix(string(r.Category), ErrUnknownErrorCategory)
+	}
+	if r.Contains == "" && r.Regex == "" {
+		return errors.Prefix("a rule needs either contains or regex", ErrInvalidErrorClassRuleFile)
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return errors.Err(err)
+		}
+		r.re = re
+	}
+	return nil
+}
+
+func (r *ErrorRule) matches(msg string) bool {
+	if r.Contains != "" && strings.Contains(msg, r.Contains) {
+		return true
+	}
+	return r.re != nil && r.re.MatchString(msg)
+}
+
+func rulesFor(category ErrorCategory, substrings ...string) []ErrorRule {
+	rules := make([]ErrorRule, 0, len(substrings))
+	for _, s := range substrings {
+		rules = append(rules, ErrorRule{Category: category, Contains: s})
+	}
+	return rules
+}
+
+// videoRulesFor returns rules for errors caused by the video itself
+func videoRulesFor(category ErrorCategory, substrings ...string) []ErrorRule {
+	rules := rulesFor(category, substrings...)
+	for i := range rules {
+		rules[i].CausedByVideo = true
+	}
+	return rules
+}
+
+// defaultErrorRules are evaluated in order, the first matching rule wins
+var defaultErrorRules = concatRules(
+	rulesFor(CategoryNeedsManualIntervention,
+		"default_wallet already exists",
+		"WALLET HAS NOT BEEN MOVED TO THE WALLET BACKUP DIR",
+		"NotEnoughFunds",
+		"no space left on device",
+		"there was a problem uploading the wallet",
+		"the channel in the wallet is different than the channel in the database",
+		"this channel does not belong to this wallet!",
+		"You already have a stream claim published under the name",
+	),
+	rulesFor(CategoryStaleWallet,
+		"Missing inputs",
+	),
+	rulesFor(CategoryFatalHost,
+		":5279: read: connection reset by peer",
+		"Cannot publish using channel",
+		"cannot concatenate 'str' and 'NoneType' objects",
+		"more than 90% of the space has been used.",
+		"Couldn't find private key for id",
+	),
+	rulesFor(CategoryWallet,
+		"Not enough funds to cover this transaction",
+		"failed: Not enough funds",
+		"Error in daemon: Insufficient funds, please deposit additional LBC",
+	),
+	rulesFor(CategoryBlockchain,
+		"txn-mempool-conflict",
+		"too-long-mempool-chain",
+	),
+	// youtube prefixes most of these with "Video unavailable", they must come before it
+	videoRulesFor(CategoryPermanentVideo,
+		"This video contains content from",
+		"have blocked it on copyright grounds",
+		"Playback on other websites has been disabled by the video owner",
+		"uploader has not made this video available in your country",
+		"This video has been removed by the uploader",
+	),
+	rulesFor(CategoryPermanentVideo,
+		"Error extracting sts from embedded url response",
+		"Unable to extract signature tokens",
+		"the video is too big to sync, skipping for now",
+		"video is too long to process",
+		"video is too short to process",
+		"no compatible format available for this video",
+		"Watch this video on YouTube.",
+		"giving up after 0 fragment retries",
+		"Video unavailable",
+		"Video is not available - hardcoded fix",
+	),
+	rulesFor(CategoryAgeRestricted,
+		"Sign in to confirm your age",
+	),
+	videoRulesFor(CategoryUnavailableVideo,
+		"Private video",
+	),
+	rulesFor(CategoryUnavailableVideo,
+		"Requested format is not available",
+		"requested format not available",
+		"non 200 status code received",
+		"dont know which claim to update",
+		"download error: AccessDenied: Access Denied",
+		"Error in daemon: Cannot publish empty file",
+		"Client.Timeout exceeded while awaiting headers",
+		"the video must be republished as we can't get the right size",
+		"HTTP Error 403",
+		"Sorry about that",
+		"This video is not available",
+		"This video is unavailable",
+		"cannot unmarshal number 0.0",
+		"default youtube thumbnail found",
+		"This channel does not have a",
+	),
+	rulesFor(CategoryDeferred,
+		"video is a live stream and hasn't completed yet",
+		"Premieres in",
+		"This live event will begin in",
+		"Premiere will begin shortly",
+		"livestream is likely bugged",
+	),
+	rulesFor(CategoryQuotaExceeded,
+		"quotaExceeded",
+	),
+	rulesFor(CategoryThrottled,
+		"HTTP Error 429",
+		"returned non-zero exit status 8",
+	),
+	rulesFor(CategoryTransient,
+		"Error in daemon: 'str' object has no attribute 'get'",
+	),
+)
+
+func concatRules(groups ...[]ErrorRule) []ErrorRule {
+	var rules []ErrorRule
+	for _, g := range groups {
+		rules = append(rules, g...)
+	}
+	return rules
+}
+
+var (
+	errorRules    = defaultErrorRules
+	errorRulesMux sync.RWMutex
+)
+
+type errorRulesFile struct {
+	// ReplaceDefaults drops the built-in rules instead of evaluating them after the ones in the file
+	ReplaceDefaults bool        `json:"replace_defaults"`
+	Rules           []ErrorRule `json:"rules"`
+}
+
+// LoadErrorRules reads classification rules from a JSON file. Rules from the file take precedence over the built-in ones
+func LoadErrorRules(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Err(err)
+	}
+	var f errorRulesFile
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return errors.Prefix(err.Error(), ErrInvalidErrorClassRuleFile)
+	}
+	for i := range f.Rules {
+		err = f.Rules[i].compile()
+		if err != nil {
+			return errors.Prefix(path, err)
+		}
+	}
+	rules := f.Rules
+	if !f.ReplaceDefaults {
+		rules = append(rules, defaultErrorRules...)
+	}
+	errorRulesMux.Lock()
+	defer errorRulesMux.Unlock()
+	errorRules = rules
+	return nil
+}
+
+// matchingRule returns the first rule matching msg
+func matchingRule(msg string) (ErrorRule, bool) {
+	errorRulesMux.RLock()
+	defer errorRulesMux.RUnlock()
+	for i := range errorRules {
+		if errorRules[i].matches(msg) {
+			return errorRules[i], true
+		}
+	}
+	return ErrorRule{}, false
+}
+
+// ClassifyMessage returns the category of the first rule matching msg
+func ClassifyMessage(msg string) (ErrorCategory, bool) {
+	rule, ok := matchingRule(msg)
+	return rule.Category, ok
+}
+
+// IsCausedByVideo tells whether err is about the video itself, which won't change for a while, according to the
+// first rule matching it
+func IsCausedByVideo(err error) bool {
+	if err == nil {
+		return false
+	}
+	rule, ok := matchingRule(err.Error())
+	return ok && rule.CausedByVideo
+}
+
+// Classify wraps err into a ClassifiedError according to the configured rules.
+// Errors that are already classified or that don't match any rule are returned untouched
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := AsClassified(err); ok {
+		return err
+	}
+	category, ok := ClassifyMessage(err.Error())
+	if !ok {
+		return err
+	}
+	return NewClassifiedError(category, err)
+}
+
+// CategoryOf returns the category of err, classifying it if needed
+func CategoryOf(err error) (ErrorCategory, bool) {
+	if classified, ok := AsClassified(Classify(err)); ok {
+		return classified.Category, true
+	}
+	return "", false
+}
+
+// AsClassified returns the first ClassifiedError found in the chain of err
+func AsClassified(err error) (*ClassifiedError, bool) {
+	var classified *ClassifiedError
+	if stderrors.As(err, &classified) {
+		return classified, true
+	}
+	return nil, false
+}
+
+// IsRetryable tells whether the operation that failed with err can be attempted again during this run.
+// Unclassified errors are considered retryable
+func IsRetryable(err error) bool {
+	category, ok := CategoryOf(err)
+	if !ok {
+		return true
+	}
+	switch category {
+	case CategoryTransient, CategoryThrottled, CategoryWallet, CategoryBlockchain:
+		return true
+	}
+	return false
+}
+
+// IsDeferred tells whether err means that the video isn't ready yet and should be queued again later
+func IsDeferred(err error) bool {
+	return errors.Is(Classify(err), ErrDeferred)
+}
+
+var countdownRegex = regexp.MustCompile(`(?:Premieres in|will begin in) (\d+) (minute|hour|day)s?`)
+
+// ReadyAt returns when the deferred video that failed with err is expected to be ready: the NotBefore of the error,
+// or the countdown of the "Premieres in 3 hours" messages of youtube. It returns zero when neither is known
+func ReadyAt(err error, now time.Time) time.Time {
+	if classified, ok := AsClassified(err); ok && !classified.NotBefore.IsZero() {
+		return classified.NotBefore
+	}
+	m := countdownRegex.FindStringSubmatch(err.Error())
+	if m == nil {
+		return time.Time{}
+	}
+	n, _ := strconv.Atoi(m[1])
+	unit := time.Minute
+	switch m[2] {
+	case "hour":
+		unit = time.Hour
+	case "day":
+		unit = 24 * time.Hour
+	}
+	return now.Add(time.Duration(n) * unit)
+}
+
+// IsFatal tells whether err must stop the sync of the whole channel
+func IsFatal(err error) bool {
+	err = Classify(err)
+	return errors.Is(err, ErrFatalHost) || errors.Is(err, ErrStaleWallet) || errors.Is(err, ErrNeedsManualIntervention)
+}
+
+// IsPermanentFailure tells whether a stored failure reason means the video can never be synced
+func IsPermanentFailure(failureReason string) bool {
+	category, ok := ClassifyMessage(failureReason)
+	return ok && category == CategoryPermanentVideo
+}
+
+// ShouldSkipFailedVideo tells whether a video that failed with failureReason must not be queued again: permanent
+// failures never are, age restricted videos only are by the runs syncing them
+func ShouldSkipFailedVideo(failureReason string, ageRestrictedRun bool) bool {
+	if IsPermanentFailure(failureReason) {
+		return true
+	}
+	category, ok := ClassifyMessage(failureReason)
+	return ok && category == CategoryAgeRestricted && !ageRestrictedRun
+}
@@ -0,0 +1,114 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	err := Classify(errors.Prefix("publish error", errors.Err("Error in daemon: txn-mempool-conflict")))
+	assert.True(t, errors.Is(err, ErrBlockchain))
+	assert.True(t, IsRetryable(err))
+	assert.False(t, IsFatal(err))
+
+	err = Classify(errors.Err("Missing inputs"))
+	assert.True(t, errors.Is(err, ErrStaleWallet))
+	assert.True(t, IsFatal(err))
+
+	err = Classify(errors.Err("ERROR: [youtube] abc: Private video. Sign in if you've been granted access"))
+	assert.True(t, errors.Is(err, ErrUnavailableVideo))
+	assert.False(t, IsRetryable(err))
+
+	err = Classify(errors.Err("googleapi: Error 403: The request cannot be completed because you have exceeded your quota., quotaExceeded"))
+	assert.True(t, errors.Is(err, ErrQuotaExceeded))
+	assert.False(t, IsRetryable(err))
+
+	unknown := errors.Err("something nobody expected")
+	assert.Equal(t, unknown, Classify(unknown))
+	assert.True(t, IsRetryable(unknown))
+	assert.Nil(t, Classify(nil))
+
+	assert.True(t, IsPermanentFailure("video is too long to process"))
+	assert.False(t, IsPermanentFailure("Premieres in 3 hours"))
+
+	// age restricted videos are only skipped by the runs that can't sync them
+	ageRestricted := "ERROR: [youtube] abc: Sign in to confirm your age. This video may be inappropriate for some users."
+	assert.True(t, errors.Is(Classify(errors.Err(ageRestricted)), ErrAgeRestricted))
+	assert.False(t, IsPermanentFailure(ageRestricted))
+	assert.True(t, ShouldSkipFailedVideo(ageRestricted, false))
+	assert.False(t, ShouldSkipFailedVideo(ageRestricted, true))
+	assert.True(t, ShouldSkipFailedVideo("video is too long to process", true))
+
+	// errors about the video itself are told apart from the others of their category
+	assert.True(t, IsCausedByVideo(errors.Err("ERROR: [youtube] abc: Video unavailable. This video has been removed by the uploader")))
+	assert.True(t, IsCausedByVideo(errors.Err("ERROR: [youtube] abc: Private video. Sign in if you've been granted access")))
+	assert.False(t, IsCausedByVideo(errors.Err("ERROR: [youtube] abc: Video unavailable")))
+	assert.False(t, IsCausedByVideo(errors.Err("ERROR: unable to download video data: HTTP Error 403: Forbidden")))
+	assert.False(t, IsCausedByVideo(unknown))
+	assert.False(t, IsCausedByVideo(nil))
+}
+
+func TestDeferred(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	err := Classify(errors.Err("ERROR: [youtube] abc: Premieres in 3 hours"))
+	assert.True(t, IsDeferred(err))
+	assert.False(t, IsRetryable(err))
+	assert.Equal(t, now.Add(3*time.Hour), ReadyAt(err, now))
+
+	err = Classify(errors.Err("ERROR: [youtube] abc: This live event will begin in 2 days."))
+	assert.True(t, IsDeferred(err))
+	assert.Equal(t, now.Add(48*time.Hour), ReadyAt(err, now))
+
+	err = Classify(errors.Err("video is a live stream and hasn't completed yet"))
+	assert.True(t, IsDeferred(err))
+	assert.True(t, ReadyAt(err, now).IsZero())
+
+	release := now.Add(time.Hour)
+	err = errors.Prefix("download error", errors.Err(NewDeferredError(errors.Base("live"), release)))
+	assert.True(t, IsDeferred(err))
+	assert.Equal(t, release, ReadyAt(err, now))
+}
+
+func TestClassifiedErrorSurvivesWrapping(t *testing.T) {
+	base := NewClassifiedError(CategoryNeedsManualIntervention, errors.Base("default_wallet already exists"))
+	err := errors.Prefix("failure in downloading wallet", errors.Err(base))
+	assert.True(t, errors.Is(err, base))
+	assert.True(t, errors.Is(err, ErrNeedsManualIntervention))
+	assert.False(t, errors.Is(err, ErrFatalHost))
+	// already classified errors keep their category even if the message matches another rule
+	assert.Equal(t, err, Classify(err))
+}
+
+func TestLoadErrorRules(t *testing.T) {
+	defer func() { errorRules = defaultErrorRules }()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	err := os.WriteFile(path, []byte(`{"rules": [
+		{"category": "permanent_video", "regex": "(?i)members-only"},
+		{"category": "transient", "contains": "Private video"},
+		{"category": "permanent_video", "contains": "members only", "caused_by_video": true}
+	]}`), 0644)
+	assert.NoError(t, err)
+
+	assert.NoError(t, LoadErrorRules(path))
+	assert.True(t, errors.Is(Classify(errors.Err("Join this channel to get access to Members-only content")), ErrPermanentVideo))
+	assert.True(t, errors.Is(Classify(errors.Err("Private video")), ErrTransient))
+	assert.True(t, errors.Is(Classify(errors.Err("too-long-mempool-chain")), ErrBlockchain))
+	assert.False(t, IsCausedByVideo(errors.Err("Private video")))
+	assert.True(t, IsCausedByVideo(errors.Err("this video is for members only")))
+
+	err = os.WriteFile(path, []byte(`{"replace_defaults": true, "rules": [{"category": "transient", "contains": "Private video"}]}`), 0644)
+	assert.NoError(t, err)
+	assert.NoError(t, LoadErrorRules(path))
+	_, ok := CategoryOf(errors.Err("too-long-mempool-chain"))
+	assert.False(t, ok)
+
+	err = os.WriteFile(path, []byte(`{"rules": [{"category": "bogus", "contains": "x"}]}`), 0644)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(LoadErrorRules(path), ErrUnknownErrorCategory))
+}
@@ -28,6 +28,29 @@ type YoutubeChannel struct {
 	WipeDB             bool           `json:"wipe_db"`
 	Language           string         `json:"language"`
 	IsDeletedOnYoutube bool           `json:"is_deleted_on_youtube"`
+	// SourceURL is a channel or playlist on any site supported by yt-dlp, or a file:// directory, to sync instead of the youtube channel
+	SourceURL string `json:"source_url,omitempty"`
+	// Subtitles enables the download of the subtitles of the videos, they are skipped when it's nil
+	Subtitles *SubtitlesConfig `json:"subtitles,omitempty"`
+	// TranscodeProfile is the name of the transcode profile of config.json the videos go through, none when empty
+	TranscodeProfile string `json:"transcode_profile,omitempty"`
+}
+
+const (
+	// SubtitlesEmbed muxes the subtitles into the mp4 as text tracks
+	SubtitlesEmbed = "embed"
+	// SubtitlesCompanion publishes each subtitle language as its own claim next to the video
+	SubtitlesCompanion = "companion"
+)
+
+// SubtitlesConfig controls which subtitles of the videos of a channel are synced and how
+type SubtitlesConfig struct {
+	// Languages lists the languages to fetch (e.g. "en", "pt-BR"), every language uploaded by the creator when empty
+	Languages []string `json:"languages,omitempty"`
+	// AutoCaptions also fetches the captions generated by youtube, in their original language or in Languages
+	AutoCaptions bool `json:"auto_captions"`
+	// Mode is SubtitlesEmbed (the default) or SubtitlesCompanion
+	Mode string `json:"mode,omitempty"`
 }
 
 type PublishAddress struct {
@@ -35,95 +58,23 @@ type PublishAddress struct {
 	IsMine  bool   `json:"is_mine"`
 }
 
+// UnmarshalJSON reads the plain string address returned by internal-apis, or the object PublishAddress is marshalled to
 func (p *PublishAddress) UnmarshalJSON(data []byte) error {
 	var s string
-	if err := json.Unmarshal(data, &s); err != nil {
+	if err := json.Unmarshal(data, &s); err == nil {
+		p.Address = s
+		p.IsMine = false
+		return nil
+	}
+	type publishAddress PublishAddress
+	var address publishAddress
+	if err := json.Unmarshal(data, &address); err != nil {
 		return errors.Err(err)
 	}
-	p.Address = s
-	p.IsMine = false
+	*p = PublishAddress(address)
 	return nil
 }
 
-var FatalErrors = []string{
-	":5279: read: connection reset by peer",
-	"no space left on device",
-	"NotEnoughFunds",
-	"Cannot publish using channel",
-	"cannot concatenate 'str' and 'NoneType' objects",
-	"more than 90% of the space has been used.",
-	"Couldn't find private key for id",
-	"You already have a stream claim published under the name",
-	"Missing inputs",
-}
-var ErrorsNoRetry = []string{
-	"Requested format is not available",
-	"non 200 status code received",
-	"This video contains content from",
-	"dont know which claim to update",
-	"uploader has not made this video available in your country",
-	"download error: AccessDenied: Access Denied",
-	"Playback on other websites has been disabled by the video owner",
-	"Error in daemon: Cannot publish empty file",
-	"Error extracting sts from embedded url response",
-	"Unable to extract signature tokens",
-	"Client.Timeout exceeded while awaiting headers",
-	"the video is too big to sync, skipping for now",
-	"video is too long to process",
-	"video is too short to process",
-	"no compatible format available for this video",
-	"Watch this video on YouTube.",
-	"have blocked it on copyright grounds",
-	"the video must be republished as we can't get the right size",
-	"HTTP Error 403",
-	"giving up after 0 fragment retries",
-	"Sorry about that",
-	"This video is not available",
-	"Video unavailable",
-	"requested format not available",
-	"interrupted by user",
-	"Sign in to confirm your age",
-	"This video is unavailable",
-	"video is a live stream and hasn't completed yet",
-	"Premieres in",
-	"Private video",
-	"This live event will begin in",
-	"This video has been removed by the uploader",
-	"Premiere will begin shortly",
-	"cannot unmarshal number 0.0",
-	"default youtube thumbnail found",
-	"livestream is likely bugged",
-	"This channel does not have a",
-}
-var WalletErrors = []string{
-	"Not enough funds to cover this transaction",
-	"failed: Not enough funds",
-	"Error in daemon: Insufficient funds, please deposit additional LBC",
-	//"Missing inputs",
-}
-var BlockchainErrors = []string{
-	"txn-mempool-conflict",
-	"too-long-mempool-chain",
-}
-var NeverRetryFailures = []string{
-	"Error extracting sts from embedded url response",
-	"Unable to extract signature tokens",
-	"the video is too big to sync, skipping for now",
-	"video is too long to process",
-	"video is too short to process",
-	"This video contains content from",
-	"no compatible format available for this video",
-	"Watch this video on YouTube.",
-	"have blocked it on copyright grounds",
-	"giving up after 0 fragment retries",
-	"Sign in to confirm your age",
-	"Playback on other websites has been disabled by the video owner",
-	"uploader has not made this video available in your country",
-	"This video has been removed by the uploader",
-	"Video unavailable",
-	"Video is not available - hardcoded fix",
-}
-
 type SyncFlags struct {
 	TakeOverExistingChannel bool
 	SkipSpaceCheck          bool
@@ -133,16 +84,21 @@ type SyncFlags struct {
 	UpgradeMetadata         bool
 	DisableTransfers        bool
 	QuickSync               bool
+	FullScan                bool
+	RefreshMetadata         bool
+	SyncPlaylists           bool
 	MaxTries                int
 	Refill                  int
 	Limit                   int
 	Status                  string
 	SecondaryStatus         string
 	ChannelID               string
+	SourceURL               string
 	WithDeletedOnYoutube    bool
 	SyncFrom                int64
 	SyncUntil               int64
 	ConcurrentJobs          int
+	ConcurrentChannels      int
 	VideosLimit             int
 	MaxVideoSize            int
 	MaxVideoLength          time.Duration
@@ -186,6 +142,8 @@ type VideoStatus struct {
 	Size            *int64
 	MetaDataVersion uint
 	IsTransferred   *bool
+	// PublishedAt is the unix time of the update, so that updates replayed from the outbox keep their original time
+	PublishedAt int64
 }
 
 const (
@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"encoding/json"
 	"testing"
 
 	"gotest.tools/assert"
@@ -18,3 +19,15 @@ func TestSyncFlags_VideosToSync(t *testing.T) {
 	f.VideosLimit = 1337
 	assert.Equal(t, f.VideosToSync(21), 1337)
 }
+
+func TestPublishAddressUnmarshal(t *testing.T) {
+	var p PublishAddress
+	assert.NilError(t, json.Unmarshal([]byte(`"bXYZ"`), &p))
+	assert.Equal(t, p.Address, "bXYZ")
+
+	// what the status endpoint returns can be read back
+	data, err := json.Marshal(PublishAddress{Address: "bABC", IsMine: true})
+	assert.NilError(t, err)
+	assert.NilError(t, json.Unmarshal(data, &p))
+	assert.Equal(t, p, PublishAddress{Address: "bABC", IsMine: true})
+}
@@ -0,0 +1,53 @@
+package sources
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lbryio/ytsync/v5/downloader/ytdl"
+)
+
+// chapterTimestampRegex matches a line starting with the 0:00 timestamp youtube requires for the first chapter, which
+// means the creator already listed the chapters in the description
+var chapterTimestampRegex = regexp.MustCompile(`(?m)^\W*(0?0:)?0?0:00\b`)
+
+const chaptersHeader = "\n\nChapters:"
+
+// hasChapterList tells whether a description already contains a list of timestamps
+func hasChapterList(description string) bool {
+	return chapterTimestampRegex.MatchString(description)
+}
+
+// formatTimestamp formats seconds like youtube does, with hours only when the video is at least an hour long
+func formatTimestamp(seconds float64, withHours bool) string {
+	s := int(seconds)
+	if withHours {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
+
+// renderChapters returns the chapters as a timestamp list to append to the description, with as many chapters as
+// fit in maxLength. It's empty when there are fewer than two chapters or none of them fit
+func renderChapters(chapters []ytdl.Chapter, maxLength int) string {
+	if len(chapters) < 2 {
+		return ""
+	}
+	withHours := chapters[len(chapters)-1].StartTime >= 3600
+	var sb strings.Builder
+	sb.WriteString(chaptersHeader)
+	rendered := 0
+	for _, c := range chapters {
+		line := "\n" + formatTimestamp(c.StartTime, withHours) + " " + strings.TrimSpace(c.Title)
+		if sb.Len()+len(line) > maxLength {
+			break
+		}
+		sb.WriteString(line)
+		rendered++
+	}
+	if rendered < 2 {
+		return ""
+	}
+	return sb.String()
+}
@@ -0,0 +1,51 @@
+package sources
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lbryio/ytsync/v5/downloader/ytdl"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasChapterList(t *testing.T) {
+	assert.True(t, hasChapterList("intro text\n0:00 Intro\n1:20 Setup"))
+	assert.True(t, hasChapterList("intro text\n00:00 - Intro"))
+	assert.True(t, hasChapterList("(0:00:00) Intro"))
+	assert.False(t, hasChapterList("we met at 10:00 in the morning"))
+	assert.False(t, hasChapterList("no timestamps here"))
+}
+
+func TestRenderChapters(t *testing.T) {
+	chapters := []ytdl.Chapter{
+		{StartTime: 0, Title: "Intro"},
+		{StartTime: 83, Title: " Setup "},
+		{StartTime: 754.5, Title: "Outro"},
+	}
+	assert.Equal(t, "\n\nChapters:\n00:00 Intro\n01:23 Setup\n12:34 Outro", renderChapters(chapters, 1000))
+	assert.Equal(t, "\n\nChapters:\n00:00 Intro\n01:23 Setup", renderChapters(chapters, len("\n\nChapters:\n00:00 Intro\n01:23 Setup")))
+	assert.Empty(t, renderChapters(chapters, 20))
+	assert.Empty(t, renderChapters(chapters[:1], 1000))
+
+	chapters = append(chapters, ytdl.Chapter{StartTime: 3725, Title: "Bonus"})
+	assert.Equal(t, "\n\nChapters:\n0:00:00 Intro\n0:01:23 Setup\n0:12:34 Outro\n1:02:05 Bonus", renderChapters(chapters, 1000))
+}
+
+func TestAbbrevDescriptionChapters(t *testing.T) {
+	v := &YoutubeVideo{
+		id:          "abc",
+		description: "a video",
+		profile:     youtubeProfile,
+		youtubeInfo: &ytdl.YtdlVideo{Chapters: []ytdl.Chapter{{StartTime: 0, Title: "Intro"}, {StartTime: 60, Title: "Main"}}},
+	}
+	assert.Equal(t, "a video\n\nChapters:\n00:00 Intro\n01:00 Main\n...\nhttps://www.youtube.com/watch?v=abc", v.getAbbrevDescription())
+
+	v.description = "a video\n0:00 Start\n0:30 Middle"
+	assert.NotContains(t, v.getAbbrevDescription(), "Chapters:")
+
+	v.description = strings.Repeat("a", 7000)
+	description := v.getAbbrevDescription()
+	assert.Contains(t, description, "00:00 Intro\n01:00 Main\n...")
+	assert.LessOrEqual(t, len(description), 6500+len("\n...\nhttps://www.youtube.com/watch?v=abc"))
+}
@@ -0,0 +1,263 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/downloader/ytdl"
+	"github.com/lbryio/ytsync/v5/shared"
+	"github.com/lbryio/ytsync/v5/thumbs"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+// LocalSourcePrefix marks a source URL as a directory on the local file system
+const LocalSourcePrefix = "file://"
+
+// localMediaExtensions are the media files listed in a directory. Only the mp4 ones are published as they are, the
+// others need a transcode profile on the channel
+var localMediaExtensions = map[string]bool{".mp4": true, ".m4v": true, ".mov": true, ".mkv": true, ".webm": true}
+var localMP4Extensions = map[string]bool{".mp4": true, ".m4v": true}
+
+var NeedsTranscodeErr error = shared.NewClassifiedError(shared.CategoryPermanentVideo, errors.Base("only mp4 files can be published without a transcode profile"))
+
+// localSidecar is the JSON file describing a media file of a local directory. It is named after the media file:
+// my-video.mp4 is described by my-video.json
+type localSidecar struct {
+	// ID defaults to the name of the media file without its extension
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	// ReleaseTime is a unix timestamp, the modification time of the media file is used when it's missing
+	ReleaseTime int64 `json:"release_time"`
+	// Thumbnail is the path of the thumbnail, relative to the directory
+	Thumbnail string `json:"thumbnail"`
+	// Duration in seconds, the media file is probed when it's missing
+	Duration int `json:"duration"`
+}
+
+// localEntry is a media file with its sidecar
+type localEntry struct {
+	path        string
+	sidecar     localSidecar
+	releaseTime time.Time
+}
+
+// LocalSource imports the media files of a directory, usually a creator's archive, as if they were the videos of a channel
+type LocalSource struct {
+	channelID string
+	dir       string
+	params    SourceParams
+}
+
+// NewLocalSource returns a source for the media files in dir. channelID is the ID of the channel in the job store
+func NewLocalSource(channelID string, dir string, params SourceParams) *LocalSource {
+	return &LocalSource{channelID: channelID, dir: dir, params: params}
+}
+
+func (s *LocalSource) ListItems(maxItems int) ([]Item, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	var entries []*localEntry
+	for _, f := range files {
+		if f.IsDir() || !localMediaExtensions[strings.ToLower(filepath.Ext(f.Name()))] {
+			continue
+		}
+		entry, err := readLocalEntry(filepath.Join(s.dir, f.Name()))
+		if err != nil {
+			log.Warnf("skipping %s: %s", f.Name(), err.Error())
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	// like youtube, the most recent videos come first
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].releaseTime.After(entries[j].releaseTime) })
+	items := make([]Item, 0, len(entries))
+	for _, e := range entries {
+		if len(items) >= maxItems {
+			break
+		}
+		items = append(items, Item{ID: localVideoID(s.channelID, e.sidecar.ID), URL: e.path})
+	}
+	return items, nil
+}
+
+// localVideoID namespaces the ID of a file with the channel it's imported for, file names are only unique within a
+// directory and must never be mistaken for youtube IDs
+func localVideoID(channelID string, id string) string {
+	return "local:" + channelID + ":" + id
+}
+
+func (s *LocalSource) FetchMetadata(item Item, playlistPosition int64) (Video, error) {
+	entry, err := readLocalEntry(item.URL)
+	if err != nil {
+		return nil, err
+	}
+	duration := entry.sidecar.Duration
+	if duration == 0 {
+		duration, err = probeDuration(entry.path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	releaseTimestamp := entry.releaseTime.Unix()
+	info := &ytdl.YtdlVideo{
+		ID:               item.ID,
+		Title:            entry.sidecar.Title,
+		Description:      entry.sidecar.Description,
+		Tags:             entry.sidecar.Tags,
+		Duration:         duration,
+		ReleaseTimestamp: &releaseTimestamp,
+		Thumbnail:        entry.sidecar.Thumbnail,
+		ChannelID:        s.channelID,
+		ExtractorKey:     "Local",
+	}
+	return &YoutubeVideo{
+		id:               info.ID,
+		title:            info.Title,
+		description:      info.Description,
+		playlistPosition: playlistPosition,
+		publishedAt:      entry.releaseTime,
+		dir:              s.params.VideoDir,
+		metadataCache:    s.params.MetadataCache,
+		youtubeInfo:      info,
+		youtubeChannelID: s.channelID,
+		stopGroup:        s.params.Stopper,
+		pool:             s.params.IPPool,
+		profile:          localProfile,
+		sourceFile:       entry.path,
+	}, nil
+}
+
+func (s *LocalSource) MockedVideo(videoID string) Video {
+	video := NewMockedVideo(s.params.VideoDir, videoID, s.channelID, s.params.Stopper, s.params.IPPool)
+	video.profile = localProfile
+	return video
+}
+
+func readLocalEntry(mediaPath string) (*localEntry, error) {
+	sidecarPath := strings.TrimSuffix(mediaPath, filepath.Ext(mediaPath)) + ".json"
+	data, err := os.ReadFile(sidecarPath)
+	if err != nil {
+		return nil, errors.Prefix("missing sidecar", err)
+	}
+	entry := &localEntry{path: mediaPath}
+	err = json.Unmarshal(data, &entry.sidecar)
+	if err != nil {
+		return nil, errors.Prefix("invalid sidecar "+sidecarPath, err)
+	}
+	if strings.TrimSpace(entry.sidecar.Title) == "" {
+		return nil, errors.Err("%s has no title", sidecarPath)
+	}
+	if entry.sidecar.ID == "" {
+		entry.sidecar.ID = strings.TrimSuffix(filepath.Base(mediaPath), filepath.Ext(mediaPath))
+	}
+	if entry.sidecar.Thumbnail != "" && !filepath.IsAbs(entry.sidecar.Thumbnail) {
+		entry.sidecar.Thumbnail = filepath.Join(filepath.Dir(mediaPath), entry.sidecar.Thumbnail)
+	}
+	if entry.sidecar.ReleaseTime > 0 {
+		entry.releaseTime = time.Unix(entry.sidecar.ReleaseTime, 0).UTC()
+	} else {
+		fi, err := os.Stat(mediaPath)
+		if err != nil {
+			return nil, errors.Err(err)
+		}
+		entry.releaseTime = fi.ModTime().UTC()
+	}
+	return entry, nil
+}
+
+func probeDuration(mediaPath string) (int, error) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFn()
+	data, err := ffprobe.ProbeURL(ctx, mediaPath)
+	if err != nil {
+		return 0, errors.Prefix("could not probe "+mediaPath, err)
+	}
+	return int(data.Format.Duration().Seconds()), nil
+}
+
+var localProfile = &videoProfile{
+	formatSelector: func(string) string { return "" },
+	webpageURL:     func(*YoutubeVideo) string { return "" },
+	thumbnailURLs: func(info *ytdl.YtdlVideo) (string, string, error) {
+		if info.Thumbnail == "" {
+			return "", "", errors.Err("no thumbnail found")
+		}
+		return info.Thumbnail, "", nil
+	},
+	mirrorThumbnail: thumbs.MirrorLocalThumbnail,
+	download:        copyLocalFile,
+}
+
+// copyLocalFile puts the media file of a local video in the video directory, where publishing expects it. The
+// directory is deleted after publishing so the file is linked, or copied when it's on another file system
+func copyLocalFile(v *YoutubeVideo) error {
+	start := time.Now()
+	defer func(start time.Time) {
+		v.timings.TimedComponent("download").Add(time.Since(start))
+	}(start)
+
+	fi, err := os.Stat(v.sourceFile)
+	if err != nil {
+		return errors.Err(err)
+	}
+	if v.maxVideoSize > 0 && fi.Size() > v.maxVideoSize*1024*1024 {
+		return errors.Err(VideoTooBigErr)
+	}
+	ext := strings.ToLower(filepath.Ext(v.sourceFile))
+	if v.transcodeProfile == nil && !localMP4Extensions[ext] {
+		return errors.Prefix(ext, NeedsTranscodeErr)
+	}
+	err = os.MkdirAll(v.videoDir(), 0777)
+	if err != nil {
+		return errors.Err(err)
+	}
+	dest := strings.TrimSuffix(v.getFullPath(), ".mp4") + ext
+	if err := os.Link(v.sourceFile, dest); err != nil {
+		err = copyFile(v.sourceFile, dest)
+		if err != nil {
+			return err
+		}
+	}
+	// the transcode validates the files it goes through
+	if v.transcodeProfile == nil {
+		err = validateVideoFile(dest, time.Duration(v.youtubeInfo.Duration)*time.Second, true, true)
+		if err != nil {
+			return errors.Prefix("invalid video", err)
+		}
+	}
+	size := fi.Size()
+	v.size = &size
+	return nil
+}
+
+func copyFile(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Err(err)
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return errors.Err(err)
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		_ = out.Close()
+		return errors.Err(err)
+	}
+	return errors.Err(out.Close())
+}
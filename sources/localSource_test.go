@@ -0,0 +1,94 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lbryio/ytsync/v5/shared"
+	"github.com/lbryio/ytsync/v5/transcoder"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLocalFixture(t *testing.T, dir string, name string, sidecar string) {
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, name+".mp4"), []byte("not really a video"), 0644))
+	if sidecar != "" {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name+".json"), []byte(sidecar), 0644))
+	}
+}
+
+func TestLocalSource(t *testing.T) {
+	archive := t.TempDir()
+	videoDir := t.TempDir()
+	writeLocalFixture(t, archive, "old-video", `{"title": "Old video", "release_time": 1500000000, "duration": 60}`)
+	writeLocalFixture(t, archive, "new-video", `{"id": "custom-id", "title": "New video", "description": "the newest one", "tags": ["Gaming", "Music"], "release_time": 1600000000, "thumbnail": "thumbs/new.jpg", "duration": 120}`)
+	writeLocalFixture(t, archive, "no-sidecar", "")
+	writeLocalFixture(t, archive, "no-title", `{"release_time": 1700000000}`)
+	assert.NoError(t, os.WriteFile(filepath.Join(archive, "notes.txt"), []byte("ignored"), 0644))
+
+	source := NewSource(&shared.YoutubeChannel{ChannelId: "UCabc", SourceURL: "file://" + archive}, SourceParams{VideoDir: videoDir})
+	items, err := source.ListItems(10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Item{
+		{ID: "local:UCabc:custom-id", URL: filepath.Join(archive, "new-video.mp4")},
+		{ID: "local:UCabc:old-video", URL: filepath.Join(archive, "old-video.mp4")},
+	}, items)
+
+	items, err = source.ListItems(1)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	video, err := source.FetchMetadata(items[0], 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	v := video.(*YoutubeVideo)
+	assert.Equal(t, "local:UCabc:custom-id", v.ID())
+	assert.Equal(t, items[0], v.Item())
+	assert.Equal(t, int64(1600000000), v.PublishedAt().Unix())
+	assert.Equal(t, 120, v.youtubeInfo.Duration)
+	assert.Equal(t, "UCabc", v.youtubeChannelID)
+	assert.Equal(t, "the newest one\n...", v.getAbbrevDescription())
+	_, _, tags := v.getMetadata()
+	assert.Contains(t, tags, "gaming")
+	thumbnail, fallback, err := v.profile.thumbnailURLs(v.youtubeInfo)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(archive, "thumbs/new.jpg"), thumbnail)
+	assert.Empty(t, fallback)
+
+	v.maxVideoSize = 1
+	// the fixture isn't a real video, with a transcode profile it's only validated by the transcode
+	v.transcodeProfile = &transcoder.Profile{Name: "test"}
+	assert.NoError(t, v.profile.download(v))
+	downloaded, err := v.getDownloadedPath()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(videoDir, "local:UCabc:custom-id", "new-video.mp4"), downloaded)
+	assert.Equal(t, int64(len("not really a video")), *v.Size())
+
+	// deleting the published copy keeps the archive intact
+	assert.NoError(t, v.delete("test"))
+	_, err = os.Stat(filepath.Join(archive, "new-video.mp4"))
+	assert.NoError(t, err)
+}
+
+func TestLocalSourceNeedsTranscodeForOtherFormats(t *testing.T) {
+	archive := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(archive, "clip.mkv"), []byte("not really a video"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(archive, "clip.json"), []byte(`{"title": "Clip", "duration": 60}`), 0644))
+
+	source := NewSource(&shared.YoutubeChannel{ChannelId: "UCabc", SourceURL: "file://" + archive}, SourceParams{VideoDir: t.TempDir()})
+	items, err := source.ListItems(10)
+	assert.NoError(t, err)
+	if !assert.Len(t, items, 1) {
+		return
+	}
+	video, err := source.FetchMetadata(items[0], 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	v := video.(*YoutubeVideo)
+	assert.True(t, errors.Is(v.profile.download(v), NeedsTranscodeErr))
+}
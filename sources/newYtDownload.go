@@ -1,19 +1,19 @@
 package sources
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
-	"path"
 	"strings"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	"github.com/lbryio/ytsync/v5/downloader"
 	"github.com/lbryio/ytsync/v5/ip_manager"
-	"github.com/lbryio/ytsync/v5/timing"
-	logUtils "github.com/lbryio/ytsync/v5/util"
+	"github.com/lbryio/ytsync/v5/metrics"
+	"github.com/lbryio/ytsync/v5/progress"
+	"github.com/lbryio/ytsync/v5/shared"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
 	"github.com/lbryio/lbry.go/v2/extras/stop"
@@ -26,34 +26,60 @@ type DownloadResults struct {
 	WasThrottled     bool
 	ReduceResolution bool
 	ChangeUserAgent  bool
+	CookiesExpired   bool
 	Successful       bool
 	KnownError       error
 }
 
-func rawDownload(args []string, dir string) (*DownloadResults, error) {
+func rawDownload(args []string, onProgress func(downloader.DownloadProgress)) (*DownloadResults, error) {
 	log.Printf("Running command yt-dlp %s", strings.Join(args, " "))
-	cmd := exec.Command("yt-dlp", args...)
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, errors.Err(err)
-	}
-	stdout, err := cmd.StdoutPipe()
+	process, err := downloader.GetYtDlpRunner().Start(args)
 	if err != nil {
-		return nil, errors.Err(err)
-	}
-	err = cmd.Start()
-	if err != nil {
-		return nil, errors.Err(err)
+		return nil, err
 	}
+	//stderr is read on its own so that yt-dlp never blocks on it while we read its progress
+	errorLogCh := make(chan []byte, 1)
+	go func() {
+		errorLog, _ := io.ReadAll(process.Stderr())
+		errorLogCh <- errorLog
+	}()
+	var downloaded atomic.Int64
 	monitorStopGrp := stop.New()
-	go detectSlowDownload(dir, monitorStopGrp, cmd)
-	errorLog, _ := io.ReadAll(stderr)
-	outLog, _ := io.ReadAll(stdout)
-	err = cmd.Wait()
+	go detectSlowDownload(downloaded.Load, monitorStopGrp, process)
+	var aggregator downloader.ProgressAggregator
+	var outLog strings.Builder
+	scanner := bufio.NewScanner(process.Stdout())
+	// the progress JSON of fragmented downloads can go past the default 64KB
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		event, ok := downloader.ParseProgressLine(scanner.Text())
+		if !ok {
+			outLog.WriteString(scanner.Text() + "\n")
+			// no progress is reported while ffmpeg merges or fixes the files, which takes a while for long videos
+			if isPostProcessingLine(scanner.Text()) {
+				monitorStopGrp.Stop()
+			}
+			continue
+		}
+		p := aggregator.Update(event)
+		metrics.DownloadedBytes.Add(float64(max(p.Bytes-downloaded.Swap(p.Bytes), 0)))
+		if p.Speed > 0 {
+			metrics.DownloadSpeeds.Observe(p.Speed)
+		}
+		if onProgress != nil {
+			onProgress(p)
+		}
+	}
+	scanErr := scanner.Err()
+	_, _ = io.Copy(io.Discard, process.Stdout())
+	errorLog := <-errorLogCh
+	err = process.Wait()
 	monitorStopGrp.Stop()
+	if scanErr != nil {
+		return nil, errors.Prefix("failed to read the output of yt-dlp", scanErr)
+	}
 	parsedFailure := parseFailureReason(string(errorLog))
-	parsedOut := parseOutLog(string(outLog))
+	parsedOut := parseOutLog(outLog.String())
 	if err != nil && !strings.Contains(err.Error(), "exit status 1") {
 		return nil, errors.Err(err)
 	}
@@ -91,7 +117,20 @@ func rawDownload(args []string, dir string) (*DownloadResults, error) {
 	return &DownloadResults{Successful: true}, nil
 }
 
-func detectSlowDownload(path string, stop *stop.Group, cmd *exec.Cmd) {
+// postProcessingPrefixes start the lines yt-dlp prints once the files are downloaded and handed to ffmpeg
+var postProcessingPrefixes = []string{"[Merger]", "[Fixup", "[ffmpeg]", "[EmbedSubtitle]", "[VideoConvertor]", "[VideoRemuxer]"}
+
+func isPostProcessingLine(line string) bool {
+	for _, prefix := range postProcessingPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectSlowDownload kills the download when the bytes reported by yt-dlp grow slower than 30 KB/s for too long
+func detectSlowDownload(downloaded func() int64, stop *stop.Group, process downloader.YtDlpProcess) {
 	stop.Add(1)
 	defer stop.Done()
 	ticker := time.NewTicker(10 * time.Second)
@@ -103,22 +142,18 @@ func detectSlowDownload(path string, stop *stop.Group, cmd *exec.Cmd) {
 		case <-stop.Ch():
 			return
 		case <-ticker.C:
-			size, err := logUtils.DirSize(path)
-			if err != nil {
-				log.Errorf("error while getting size of download directory: %s", errors.FullTrace(err))
-				continue
-			}
+			size := downloaded()
 			delta := size - lastSize
 			lastSize = size
 			avgSpeed := delta / 10
-			//log.Infof("download speed: %d bytes/s - min speed: %d", avgSpeed, 30*1024*1000)
 			if avgSpeed < 30*1024 { //30 KB/s
 				count++
 			} else if count > 0 {
 				count--
 			}
 			if count > 3 {
-				err := cmd.Process.Signal(syscall.SIGKILL)
+				metrics.SlowDownloads.Inc()
+				err := process.Kill()
 				if err != nil {
 					log.Errorf("failure in killing slow download: %s", errors.Err(err))
 					return
@@ -130,12 +165,12 @@ func detectSlowDownload(path string, stop *stop.Group, cmd *exec.Cmd) {
 }
 
 var (
-	ThrottledErr          = errors.Base("throttled")
-	FragmentsRetriesErr   = errors.Base("missing fragments")
-	VideoExtractionErr    = errors.Base("unable to extract")
-	VideoTooLongErr       = errors.Base("video is too long to process")
-	VideoTooBigErr        = errors.Base("the video is too big to sync, skipping for now")
-	FormatNotAvailableErr = errors.Base("Requested format is not available")
+	ThrottledErr          error = shared.NewClassifiedError(shared.CategoryThrottled, errors.Base("throttled"))
+	FragmentsRetriesErr   error = shared.NewClassifiedError(shared.CategoryTransient, errors.Base("missing fragments"))
+	VideoExtractionErr    error = shared.NewClassifiedError(shared.CategoryTransient, errors.Base("unable to extract"))
+	VideoTooLongErr       error = shared.NewClassifiedError(shared.CategoryPermanentVideo, errors.Base("video is too long to process"))
+	VideoTooBigErr        error = shared.NewClassifiedError(shared.CategoryPermanentVideo, errors.Base("the video is too big to sync, skipping for now"))
+	FormatNotAvailableErr error = shared.NewClassifiedError(shared.CategoryUnavailableVideo, errors.Base("Requested format is not available"))
 )
 
 const (
@@ -187,25 +222,65 @@ func parseOutLog(outLog string) error {
 	return nil
 }
 
+// validateDownload checks a video downloaded by yt-dlp. The container and the codecs are checked after the transcode
+// when there is one, and the codecs aren't checked when the format selector of the profile doesn't guarantee them
+func (v *YoutubeVideo) validateDownload(videoPath string, dur time.Duration) error {
+	strict := v.transcodeProfile == nil
+	return validateVideoFile(videoPath, dur, strict, strict && !v.profile.anyCodec)
+}
+
+// downloadedVideoPath returns where yt-dlp wrote the video. It is the mp4 of getFullPath unless the video is transcoded,
+// in which case the container of the source is kept
+func (v *YoutubeVideo) downloadedVideoPath() (string, error) {
+	if v.transcodeProfile != nil {
+		return v.getDownloadedPath()
+	}
+	return v.getFullPath(), nil
+}
+
+// recordDownloadSize makes the video downloaded at videoPath readable by the daemon and records its size
+func (v *YoutubeVideo) recordDownloadSize(videoPath string) error {
+	fi, err := os.Stat(videoPath)
+	if err != nil {
+		return errors.Err(err)
+	}
+	err = os.Chmod(videoPath, 0777)
+	if err != nil {
+		return errors.Err(err)
+	}
+	videoSize := fi.Size()
+	v.size = &videoSize
+	return nil
+}
+
 func (v *YoutubeVideo) Download() error {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("download").Add(time.Since(start))
+		v.timings.TimedComponent("download").Add(time.Since(start))
 	}(start)
 
-	videoPath := v.getFullPath()
-
 	err := os.Mkdir(v.videoDir(), 0777)
 	if err != nil && !strings.Contains(err.Error(), "file exists") {
 		return errors.Wrap(err, 0)
 	}
 
-	_, err = os.Stat(videoPath)
-	if err != nil && !os.IsNotExist(err) {
-		return errors.Err(err)
-	} else if err == nil {
-		log.Debugln(v.id + " already exists at " + videoPath)
-		return nil
+	dur := time.Duration(v.youtubeInfo.Duration) * time.Second
+	videoPath, err := v.downloadedVideoPath()
+	if err == nil {
+		_, err = os.Stat(videoPath)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Err(err)
+		}
+	}
+	if err == nil {
+		// it might have been left behind by an interrupted run
+		err = v.validateDownload(videoPath, dur)
+		if err == nil {
+			log.Debugln(v.id + " already exists at " + videoPath)
+			return v.recordDownloadSize(videoPath)
+		}
+		log.Warnf("%s: dropping the invalid video found at %s: %s", v.id, videoPath, err.Error())
+		_ = v.delete(err.Error())
 	}
 	qualities := []string{
 		"1080",
@@ -213,7 +288,6 @@ func (v *YoutubeVideo) Download() error {
 		"480",
 		"360",
 	}
-	dur := time.Duration(v.youtubeInfo.Duration) * time.Second
 	if dur.Hours() > 1 { //for videos longer than 1 hour only sync up to 720p
 		qualities = []string{
 			"720",
@@ -222,25 +296,22 @@ func (v *YoutubeVideo) Download() error {
 		}
 	}
 
-	metadataPath := path.Join(logUtils.GetVideoMetadataDir(), v.id+".info.json")
-	_, err = os.Stat(metadataPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return errors.Err("metadata information for video %s is missing! Why?", v.id)
-		}
-		return errors.Err(err)
+	formatSelector := v.profile.formatSelector
+	if v.transcodeProfile != nil {
+		// the codecs don't matter as the video is transcoded afterwards
+		formatSelector = anyCodecFormat
+		qualities = capQualities(qualities, v.transcodeProfile.MaxHeight)
 	}
 
-	metadata, err := parseVideoMetadata(metadataPath)
-
-	err = checkCookiesIntegrity()
+	// the cached metadata is fetched again if it expired while the video was waiting in the queue
+	metadataPath, releaseMetadata, err := v.metadataCache.InfoPath(v.id, v.profile.webpageURL(v), v.stopGroup.Ch(), v.pool)
 	if err != nil {
-		return err
+		return errors.Prefix("metadata information for video "+v.id+" is missing", err)
 	}
+	defer releaseMetadata()
 
 	ytdlArgs := []string{
 		"--no-warnings",
-		"--no-progress",
 		"-o" + strings.TrimSuffix(v.getFullPath(), ".mp4"),
 		"--merge-output-format",
 		"mp4",
@@ -249,13 +320,12 @@ func (v *YoutubeVideo) Download() error {
 		"--abort-on-unavailable-fragment",
 		"--fragment-retries",
 		"1",
-		"--cookies",
-		"cookies.txt",
-		"--extractor-args",
-		"youtube:player_client=android",
 		"--load-info-json",
 		metadataPath,
 	}
+	ytdlArgs = append(ytdlArgs, downloader.ProgressArgs...)
+	ytdlArgs = append(ytdlArgs, v.profile.extraArgs...)
+	ytdlArgs = append(ytdlArgs, subtitleArgs(subtitleLanguages(v.youtubeInfo, v.subtitles), v.subtitles)...)
 
 	userAgent := []string{"--user-agent", downloader.ChromeUA}
 	if v.maxVideoSize > 0 {
@@ -273,6 +343,8 @@ func (v *YoutubeVideo) Download() error {
 
 	qualityIndex := 0
 	var lastKnownError error
+	videoProgress := progress.ForVideo(v.id)
+	videoProgress.SetStage(progress.StageDownload)
 
 	remainingAttempts := len(qualities)
 
@@ -284,18 +356,27 @@ func (v *YoutubeVideo) Download() error {
 				return nil, sourceAddress, err
 			}
 			defer v.pool.ReleaseIP(sourceAddress)
+			videoProgress.SetSourceIP(sourceAddress)
+			cookies, err := downloader.GetCookieJar().Pick(sourceAddress)
+			if err != nil {
+				return nil, sourceAddress, err
+			}
 			quality := qualities[qualityIndex]
-			dynamicArgs := append(ytdlArgs, "-fbestvideo[ext=mp4][vcodec!*=av01][vcodec!*=vp09][height<="+quality+"]+bestaudio[ext!=webm][format_id!*=258][format_id!*=380][format_id!*=251][format_id!*=256][format_id!*=327][format_id!*=328][format_id!*=380]")
+			dynamicArgs := append(ytdlArgs, "-f"+formatSelector(quality))
 			dynamicArgs = append(dynamicArgs, userAgent...)
 			dynamicArgs = append(dynamicArgs,
 				"--source-address",
 				sourceAddress,
 			)
-			dlStopGrp := stop.New()
-			go v.trackProgressBar(dynamicArgs, metadata, dlStopGrp, sourceAddress)
-			res, err := rawDownload(dynamicArgs, v.videoDir())
-			//stop the progress bar
-			dlStopGrp.Stop()
+			dynamicArgs = append(dynamicArgs, cookies.Args()...)
+			onProgress, removeProgressBar := v.trackProgress(sourceAddress)
+			res, err := rawDownload(dynamicArgs, onProgress)
+			removeProgressBar()
+			if err == nil && res.KnownError != nil && downloader.GetCookieJar().ReportOutput(cookies, res.KnownError.Error()) {
+				// another profile might still be signed in
+				res.CouldRetry = true
+				res.CookiesExpired = true
+			}
 			return res, sourceAddress, err
 		}()
 		if err != nil {
@@ -304,19 +385,25 @@ func (v *YoutubeVideo) Download() error {
 		}
 		lastKnownError = res.KnownError
 		if res.Successful {
-			fi, err := os.Stat(v.getFullPath())
-			if err != nil {
-				return errors.Err(err)
+			videoPath, err = v.downloadedVideoPath()
+			if err == nil {
+				err = v.validateDownload(videoPath, dur)
 			}
-			err = os.Chmod(v.getFullPath(), 0777)
 			if err != nil {
-				return errors.Err(err)
+				log.Warnf("%s: invalid download at %sp: %s", v.id, qualities[qualityIndex], err.Error())
+				lastKnownError = err
+				_ = v.delete(err.Error())
+				videoProgress.AddDownloadRetry()
+				qualityIndex++
+				if qualityIndex >= len(qualities) {
+					break
+				}
+				continue
 			}
-			videoSize := fi.Size()
-			v.size = &videoSize
-			return nil
+			return v.recordDownloadSize(videoPath)
 		}
 		if res.CouldRetry {
+			videoProgress.AddDownloadRetry()
 			if res.ReduceResolution {
 				qualityIndex++
 				_ = v.delete(res.KnownError.Error())
@@ -331,6 +418,11 @@ func (v *YoutubeVideo) Download() error {
 				userAgent = []string{"--user-agent", downloader.GoogleBotUA}
 				continue
 			}
+			if res.CookiesExpired {
+				log.Infof("trying another cookie profile for video %s", v.ID())
+				_ = v.delete(res.KnownError.Error())
+				continue
+			}
 			if res.WasThrottled {
 				remainingAttempts++
 				v.pool.SetThrottled(usedIp)
@@ -352,7 +444,7 @@ func (v *YoutubeVideo) getSourceAddress() (string, error) {
 		if errors.Is(err, ip_manager.ErrAllThrottled) {
 			select {
 			case <-v.stopGroup.Ch():
-				return sourceAddress, errors.Err("interrupted by user")
+				return sourceAddress, errors.Err(ip_manager.ErrInterruptedByUser)
 			default:
 				time.Sleep(ip_manager.IPCooldownPeriod)
 				continue
@@ -1,32 +1,35 @@
 package sources
 
 import (
-	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
-	"time"
+
+	"github.com/lbryio/ytsync/v5/downloader"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func Test_rawDownload(t *testing.T) {
-	nowString := fmt.Sprintf("%d", time.Now().Unix())
-	testPath, err := os.MkdirTemp("/tmp/", "")
-	if !assert.NoError(t, err) {
-		return
-	}
-	defer func(path string) {
-		err := os.RemoveAll(path)
-		if err != nil {
-			t.Error(err)
-		}
-	}(testPath)
-	args := []string{
+// replayYtDlp makes yt-dlp calls replay the fixture recorded in testdata/ytdlp until the test ends
+func replayYtDlp(t *testing.T, fixture string) {
+	runner, err := downloader.NewFixtureRunnerFromFiles(filepath.Join("testdata", "ytdlp", fixture+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := downloader.GetYtDlpRunner()
+	downloader.SetYtDlpRunner(runner)
+	t.Cleanup(func() { downloader.SetYtDlpRunner(previous) })
+}
+
+func downloadArgs(output string, maxFileSize string, videoURL string) []string {
+	return []string{
 		"--merge-output-format",
 		"mp4",
-		"-o" + fmt.Sprintf("%s/%s.mp4", testPath, nowString),
+		"-o" + output,
 		"--postprocessor-args",
 		"ffmpeg:-movflags faststart",
 		"--abort-on-unavailable-fragment",
@@ -34,13 +37,22 @@ func Test_rawDownload(t *testing.T) {
 		"1",
 		"--extractor-args",
 		"youtube:player_client=android",
-		"--max-filesize", "3050M",
+		"--max-filesize", maxFileSize,
 		"--match-filter", "duration <= 7200",
 		"-f", "bestvideo[ext=mp4][vcodec!*=av01][height<=1920]+bestaudio[ext!=webm][format_id!=258][format_id!=380][format_id!=251][format_id!=256][format_id!=327][format_id!=328]",
 		"--user-agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36",
-		"https://www.youtube.com/watch?v=HYH4Z__jqe0",
+		videoURL,
 	}
-	res, err := rawDownload(args, testPath)
+}
+
+func Test_rawDownload(t *testing.T) {
+	replayYtDlp(t, "downloaded")
+	testPath := t.TempDir()
+	output := filepath.Join(testPath, "HYH4Z__jqe0")
+	var last downloader.DownloadProgress
+	res, err := rawDownload(downloadArgs(output, "3050M", "https://www.youtube.com/watch?v=HYH4Z__jqe0"), func(p downloader.DownloadProgress) {
+		last = p
+	})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -51,38 +63,16 @@ func Test_rawDownload(t *testing.T) {
 		return
 	}
 	assert.True(t, res.Successful)
+	_, err = os.Stat(output + ".mp4")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5120), last.Bytes)
+	assert.Equal(t, int64(5120), last.Total)
 }
 
 func TestVideoTooLong(t *testing.T) {
-	nowString := fmt.Sprintf("%d", time.Now().Unix())
-	testPath, err := os.MkdirTemp("/tmp/", "")
-	if !assert.NoError(t, err) {
-		return
-	}
-	defer func(path string) {
-		err := os.RemoveAll(path)
-		if err != nil {
-			t.Error(err)
-		}
-	}(testPath)
-	args := []string{
-		"--merge-output-format",
-		"mp4",
-		"-o" + fmt.Sprintf("%s/%s.mp4", testPath, nowString),
-		"--postprocessor-args",
-		"ffmpeg:-movflags faststart",
-		"--abort-on-unavailable-fragment",
-		"--fragment-retries",
-		"1",
-		"--extractor-args",
-		"youtube:player_client=android",
-		"--max-filesize", "3050M",
-		"--match-filter", "duration <= 7200",
-		"-f", "bestvideo[ext=mp4][vcodec!*=av01][height<=1920]+bestaudio[ext!=webm][format_id!=258][format_id!=380][format_id!=251][format_id!=256][format_id!=327][format_id!=328]",
-		"--user-agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36",
-		"https://www.youtube.com/watch?v=X0RK2jz5HOI",
-	}
-	res, err := rawDownload(args, testPath)
+	replayYtDlp(t, "too_long")
+	testPath := t.TempDir()
+	res, err := rawDownload(downloadArgs(filepath.Join(testPath, "X0RK2jz5HOI"), "3050M", "https://www.youtube.com/watch?v=X0RK2jz5HOI"), nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -90,38 +80,13 @@ func TestVideoTooLong(t *testing.T) {
 		return
 	}
 	assert.True(t, errors.Is(res.KnownError, VideoTooLongErr))
+	assert.False(t, res.CouldRetry)
 }
 
 func TestTooBig(t *testing.T) {
-	nowString := fmt.Sprintf("%d", time.Now().Unix())
-	testPath, err := os.MkdirTemp("/tmp/", "")
-	if !assert.NoError(t, err) {
-		return
-	}
-	defer func(path string) {
-		err := os.RemoveAll(path)
-		if err != nil {
-			t.Error(err)
-		}
-	}(testPath)
-	args := []string{
-		"--merge-output-format",
-		"mp4",
-		"-o" + fmt.Sprintf("%s/%s.mp4", testPath, nowString),
-		"--postprocessor-args",
-		"ffmpeg:-movflags faststart",
-		"--abort-on-unavailable-fragment",
-		"--fragment-retries",
-		"1",
-		"--extractor-args",
-		"youtube:player_client=android",
-		"--max-filesize", "1M",
-		"--match-filter", "duration <= 7200",
-		"-f", "bestvideo[ext=mp4][vcodec!*=av01][height<=1920]+bestaudio[ext!=webm][format_id!=258][format_id!=380][format_id!=251][format_id!=256][format_id!=327][format_id!=328]",
-		"--user-agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36",
-		"https://www.youtube.com/watch?v=HYH4Z__jqe0",
-	}
-	res, err := rawDownload(args, testPath)
+	replayYtDlp(t, "too_big")
+	testPath := t.TempDir()
+	res, err := rawDownload(downloadArgs(filepath.Join(testPath, "HYH4Z__jqe0"), "1M", "https://www.youtube.com/watch?v=HYH4Z__jqe0"), nil)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -129,46 +94,114 @@ func TestTooBig(t *testing.T) {
 		return
 	}
 	assert.True(t, errors.Is(res.KnownError, VideoTooBigErr))
+	assert.True(t, res.CouldRetry)
+	assert.True(t, res.ReduceResolution)
 }
 
 func TestNoFormat(t *testing.T) {
-	nowString := fmt.Sprintf("%d", time.Now().Unix())
-	testPath, err := os.MkdirTemp("/tmp/", "")
+	replayYtDlp(t, "format_unavailable")
+	testPath := t.TempDir()
+	res, err := rawDownload(downloadArgs(filepath.Join(testPath, "w92FBmzJnc4"), "1M", "https://www.youtube.com/watch?v=w92FBmzJnc4"), nil)
 	if !assert.NoError(t, err) {
 		return
 	}
-	defer func(path string) {
-		err := os.RemoveAll(path)
-		if err != nil {
-			t.Error(err)
-		}
-	}(testPath)
-	args := []string{
-		"--merge-output-format",
-		"mp4",
-		"-o" + fmt.Sprintf("%s/%s.mp4", testPath, nowString),
-		"--postprocessor-args",
-		"ffmpeg:-movflags faststart",
-		"--abort-on-unavailable-fragment",
-		"--fragment-retries",
-		"1",
-		"--extractor-args",
-		"youtube:player_client=android",
-		"--max-filesize", "1M",
-		"--match-filter", "duration <= 7200",
-		"-f", "bestvideo[ext=mp4][vcodec!*=av01][height<=1080]+bestaudio[ext!=webm][format_id!=258][format_id!=380][format_id!=251][format_id!=256][format_id!=327][format_id!=328]",
-		"--user-agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36",
-		"https://www.youtube.com/watch?v=w92FBmzJnc4",
+	if !assert.NotNil(t, res) {
+		return
 	}
-	res, err := rawDownload(args, testPath)
+	assert.True(t, errors.Is(res.KnownError, FormatNotAvailableErr))
+	assert.True(t, res.CouldRetry)
+	assert.True(t, res.ReduceResolution)
+	assert.False(t, res.Successful)
+}
+
+func TestThrottledDownload(t *testing.T) {
+	replayYtDlp(t, "throttled")
+	testPath := t.TempDir()
+	res, err := rawDownload(downloadArgs(filepath.Join(testPath, "HYH4Z__jqe0"), "3050M", "https://www.youtube.com/watch?v=HYH4Z__jqe0"), nil)
 	if !assert.NoError(t, err) {
 		return
 	}
 	if !assert.NotNil(t, res) {
 		return
 	}
-	assert.True(t, errors.Is(res.KnownError, FormatNotAvailableErr))
+	assert.True(t, errors.Is(res.KnownError, ThrottledErr))
+	assert.True(t, res.WasThrottled)
 	assert.True(t, res.CouldRetry)
-	assert.True(t, res.ReduceResolution)
-	assert.False(t, res.Successful)
+	assert.False(t, res.ReduceResolution)
+}
+
+func TestParseFailureReason(t *testing.T) {
+	tests := []struct {
+		errLog   string
+		expected error
+	}{
+		{"ERROR: [youtube] abc: Unable to download webpage: HTTP Error 429: Too Many Requests", ThrottledErr},
+		{"ERROR: Postprocessing: ffmpeg returned non-zero exit status 8", ThrottledErr},
+		{"ERROR: fragment 3 not found, giving up after 0 fragment retries", FragmentsRetriesErr},
+		{"ERROR: [youtube] abc: YouTube said: Unable to extract video data", VideoExtractionErr},
+		{"ERROR: [youtube] abc: Requested format is not available. Use --list-formats for a list of available formats", FormatNotAvailableErr},
+	}
+	for _, test := range tests {
+		assert.True(t, errors.Is(parseFailureReason(test.errLog), test.expected), test.errLog)
+	}
+	assert.NoError(t, parseFailureReason(""))
+	// unknown errors are kept as they are
+	err := parseFailureReason("ERROR: [youtube] abc: Video unavailable")
+	assert.EqualError(t, err, "ERROR: [youtube] abc: Video unavailable")
+}
+
+func TestParseOutLog(t *testing.T) {
+	tests := []struct {
+		outLog   string
+		expected error
+	}{
+		{"[download] Title does not pass filter (duration <= 7200), skipping ..", VideoTooLongErr},
+		{"[download] File is larger than max-filesize (24510975 bytes > 1048576 bytes). Aborting.", VideoTooBigErr},
+		{"[download] Got error: HTTP Error 429: Too Many Requests", ThrottledErr},
+	}
+	for _, test := range tests {
+		assert.True(t, errors.Is(parseOutLog(test.outLog), test.expected), test.outLog)
+	}
+	assert.NoError(t, parseOutLog(""))
+	assert.NoError(t, parseOutLog("[download] 100% of 24.51MiB in 00:00:03"))
+}
+
+// scriptedRunner starts processes that print stdout and exit successfully
+type scriptedRunner struct {
+	stdout string
+}
+
+func (r scriptedRunner) Start([]string) (downloader.YtDlpProcess, error) {
+	return &scriptedProcess{stdout: strings.NewReader(r.stdout)}, nil
+}
+
+type scriptedProcess struct {
+	stdout io.Reader
+}
+
+func (p *scriptedProcess) Stdout() io.Reader { return p.stdout }
+func (p *scriptedProcess) Stderr() io.Reader { return strings.NewReader("") }
+func (p *scriptedProcess) Wait() error       { return nil }
+func (p *scriptedProcess) Kill() error       { return nil }
+
+func TestRawDownloadReadsLongLines(t *testing.T) {
+	previous := downloader.GetYtDlpRunner()
+	defer downloader.SetYtDlpRunner(previous)
+	downloader.SetYtDlpRunner(scriptedRunner{stdout: "[info] " + strings.Repeat("x", 200*1024) + "\n" +
+		`[ytsync-progress] {"status": "finished", "filename": "video.mp4", "downloaded_bytes": 1024}` + "\n" +
+		"[Merger] Merging formats into \"video.mp4\"\n"})
+
+	var reported int64
+	res, err := rawDownload(nil, func(p downloader.DownloadProgress) { reported = p.Bytes })
+	assert.NoError(t, err)
+	if assert.NotNil(t, res) {
+		assert.True(t, res.Successful)
+	}
+	assert.Equal(t, int64(1024), reported)
+}
+
+func TestIsPostProcessingLine(t *testing.T) {
+	assert.True(t, isPostProcessingLine(`[Merger] Merging formats into "video.mp4"`))
+	assert.True(t, isPostProcessingLine(`[FixupM3u8] Fixing MPEG-TS in MP4 container of "video.mp4"`))
+	assert.False(t, isPostProcessingLine("[download] Destination: video.f137.mp4"))
 }
@@ -11,6 +11,8 @@ import (
 type SyncSummary struct {
 	ClaimID   string
 	ClaimName string
+	// SubtitleClaimIDs are the claims of the subtitles published alongside the video, by language
+	SubtitleClaimIDs map[string]string
 }
 
 func publishAndRetryExistingNames(daemon *jsonrpc.Client, title, filename string, amount float64, options jsonrpc.StreamCreateOptions, namer *namer.Namer, walletLock *sync.RWMutex) (*SyncSummary, error) {
@@ -0,0 +1,123 @@
+package sources
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/downloader"
+	"github.com/lbryio/ytsync/v5/ip_manager"
+	"github.com/lbryio/ytsync/v5/sdk"
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/lbryio/lbry.go/v2/extras/jsonrpc"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+
+	"github.com/vbauerster/mpb/v7"
+)
+
+// Video is an item of a source that can be downloaded and published
+type Video interface {
+	Size() *int64
+	ID() string
+	IDAndNum() string
+	PlaylistPosition() int
+	PublishedAt() time.Time
+	// Item returns the item the video was fetched from, to fetch it again later
+	Item() Item
+	Sync(*jsonrpc.Client, SyncParams, *sdk.SyncedVideo, bool, *sync.RWMutex, *sync.WaitGroup, *mpb.Progress) (*SyncSummary, error)
+}
+
+// Item is an entry listed by a source. URL can be empty for sources that can find an item by its ID alone
+type Item struct {
+	ID  string
+	URL string
+}
+
+// Source is where the videos of a channel come from. Downloading is done by the videos it returns, when they are synced
+type Source interface {
+	// ListItems returns up to maxItems of the most recent items of the channel
+	ListItems(maxItems int) ([]Item, error)
+	// FetchMetadata retrieves the metadata of an item and returns it as a video ready to be synced
+	FetchMetadata(item Item, playlistPosition int64) (Video, error)
+	// MockedVideo returns a video known only by its ID, used to update the claims of items that aren't listed anymore
+	MockedVideo(videoID string) Video
+}
+
+// FeedSource is a source that can also list its latest items cheaply, without the full listing
+type FeedSource interface {
+	Source
+	// ListFeedItems returns the latest items, most recent first. They may not go back far enough to cover what
+	// was uploaded since the last sync
+	ListFeedItems() ([]Item, error)
+}
+
+// SourceParams holds what every source needs to list, fetch and download videos
+type SourceParams struct {
+	VideoDir      string
+	MetadataCache *downloader.MetadataCache
+	Stopper       *stop.Group
+	IPPool        *ip_manager.IPPool
+}
+
+// NewSource returns the source configured for the channel: its youtube channel unless a source URL is set. Source
+// URLs starting with file:// are local directories
+func NewSource(channel *shared.YoutubeChannel, params SourceParams) Source {
+	if strings.HasPrefix(channel.SourceURL, LocalSourcePrefix) {
+		return NewLocalSource(channel.ChannelId, strings.TrimPrefix(channel.SourceURL, LocalSourcePrefix), params)
+	}
+	if channel.SourceURL != "" {
+		return NewYtDlpSource(channel.ChannelId, channel.SourceURL, params)
+	}
+	return NewYoutubeSource(channel.ChannelId, params)
+}
+
+// YoutubeSource lists the videos, shorts and streams of a youtube channel
+type YoutubeSource struct {
+	channelID string
+	params    SourceParams
+}
+
+func NewYoutubeSource(channelID string, params SourceParams) *YoutubeSource {
+	return &YoutubeSource{channelID: channelID, params: params}
+}
+
+func (s *YoutubeSource) ListItems(maxItems int) ([]Item, error) {
+	videoIDs, err := downloader.GetPlaylistVideoIDs(s.channelID, maxItems, s.params.Stopper.Ch(), s.params.IPPool)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(videoIDs))
+	for _, id := range videoIDs {
+		// youtube IDs are 11 characters long, anything much shorter is garbage from the listing
+		if len(id) < 5 {
+			continue
+		}
+		items = append(items, Item{ID: id})
+	}
+	return items, nil
+}
+
+func (s *YoutubeSource) FetchMetadata(item Item, playlistPosition int64) (Video, error) {
+	info, err := downloader.GetVideoInformation(item.ID, s.params.MetadataCache, s.params.Stopper.Ch(), s.params.IPPool)
+	if err != nil {
+		return nil, err
+	}
+	return NewYoutubeVideo(s.params.VideoDir, s.params.MetadataCache, info, playlistPosition, s.params.Stopper, s.params.IPPool)
+}
+
+func (s *YoutubeSource) MockedVideo(videoID string) Video {
+	return NewMockedVideo(s.params.VideoDir, videoID, s.channelID, s.params.Stopper, s.params.IPPool)
+}
+
+func (s *YoutubeSource) ListFeedItems() ([]Item, error) {
+	entries, err := downloader.GetChannelFeed(s.channelID, s.params.Stopper.Ch(), s.params.IPPool)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, Item{ID: e.VideoID})
+	}
+	return items, nil
+}
@@ -0,0 +1,174 @@
+package sources
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lbryio/ytsync/v5/downloader/ytdl"
+	"github.com/lbryio/ytsync/v5/shared"
+	logUtils "github.com/lbryio/ytsync/v5/util"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/jsonrpc"
+	"github.com/lbryio/lbry.go/v2/extras/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// liveChatLanguage is how yt-dlp lists the chat replay of streams among the subtitles
+const liveChatLanguage = "live_chat"
+
+// SubtitlesTag is the tag of the companion claims holding the subtitles of a video
+const SubtitlesTag = "subtitles"
+
+// originalCaptionSuffix marks the automatic captions in the language spoken in the video, the others are translations
+const originalCaptionSuffix = "-orig"
+
+// subtitlesMode returns how the subtitles are published, SubtitlesEmbed unless SubtitlesCompanion is configured
+func subtitlesMode(cfg *shared.SubtitlesConfig) string {
+	if cfg != nil && cfg.Mode == shared.SubtitlesCompanion {
+		return shared.SubtitlesCompanion
+	}
+	return shared.SubtitlesEmbed
+}
+
+// subtitleLanguages returns the sorted subtitle languages of a video that cfg asks for
+func subtitleLanguages(info *ytdl.YtdlVideo, cfg *shared.SubtitlesConfig) []string {
+	if info == nil || cfg == nil {
+		return nil
+	}
+	wanted := func(language string) bool {
+		if len(cfg.Languages) == 0 {
+			return true
+		}
+		for _, l := range cfg.Languages {
+			if l == language {
+				return true
+			}
+		}
+		return false
+	}
+	found := make(map[string]bool)
+	for language, tracks := range info.Subtitles {
+		if language == liveChatLanguage || len(tracks) == 0 || !wanted(language) {
+			continue
+		}
+		found[language] = true
+	}
+	if cfg.AutoCaptions {
+		for language, tracks := range info.AutomaticCaptions {
+			if len(tracks) == 0 {
+				continue
+			}
+			if len(cfg.Languages) == 0 {
+				// every language is offered as a machine translation, only the original one is worth syncing
+				if !strings.HasSuffix(language, originalCaptionSuffix) {
+					continue
+				}
+				language = strings.TrimSuffix(language, originalCaptionSuffix)
+			}
+			if wanted(language) {
+				found[language] = true
+			}
+		}
+	}
+	languages := make([]string, 0, len(found))
+	for language := range found {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// subtitleArgs returns the yt-dlp arguments that fetch the given subtitle languages as WebVTT
+func subtitleArgs(languages []string, cfg *shared.SubtitlesConfig) []string {
+	if len(languages) == 0 {
+		return nil
+	}
+	args := []string{
+		"--write-subs",
+		"--sub-langs",
+		strings.Join(languages, ","),
+		"--sub-format",
+		"vtt/best",
+		"--convert-subs",
+		"vtt",
+	}
+	if cfg.AutoCaptions {
+		// the captions uploaded by the creator are preferred when both exist
+		args = append(args, "--write-auto-subs")
+	}
+	if subtitlesMode(cfg) == shared.SubtitlesEmbed {
+		args = append(args, "--embed-subs")
+	}
+	return args
+}
+
+// subtitlePath returns where yt-dlp writes the subtitles of a language, next to the video
+func (v *YoutubeVideo) subtitlePath(language string) string {
+	return strings.TrimSuffix(v.getFullPath(), ".mp4") + "." + language + ".vtt"
+}
+
+// downloadedSubtitleLanguages returns the subtitle languages that were asked for and actually downloaded by yt-dlp
+func (v *YoutubeVideo) downloadedSubtitleLanguages() []string {
+	var languages []string
+	for _, language := range subtitleLanguages(v.youtubeInfo, v.subtitles) {
+		if _, err := os.Stat(v.subtitlePath(language)); err != nil {
+			log.Infof("%s: no %s subtitles were downloaded", v.id, language)
+			continue
+		}
+		languages = append(languages, language)
+	}
+	return languages
+}
+
+// deleteSubtitles removes the subtitle files left next to the video
+func (v *YoutubeVideo) deleteSubtitles() {
+	files, err := filepath.Glob(strings.TrimSuffix(v.getFullPath(), ".mp4") + ".*.vtt")
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	for _, f := range files {
+		err = os.Remove(f)
+		if err != nil {
+			log.Errorf("failed to delete subtitles %s: %s", f, err.Error())
+		}
+	}
+}
+
+// publishSubtitles publishes each downloaded subtitle file as a claim linking to the video claim, and records their
+// claim IDs in the summary. They have no thumbnail so that they aren't mistaken for the claims of videos. The video is
+// already published, so failures are only reported
+func (v *YoutubeVideo) publishSubtitles(daemon *jsonrpc.Client, params SyncParams, video *SyncSummary) {
+	for _, language := range v.downloadedSubtitleLanguages() {
+		title := fmt.Sprintf("%s (%s subtitles)", v.title, language)
+		options := jsonrpc.StreamCreateOptions{
+			ClaimCreateOptions: jsonrpc.ClaimCreateOptions{
+				Title:        &title,
+				Description:  util.PtrToString(fmt.Sprintf("%s subtitles for lbry://%s#%s", language, video.ClaimName, video.ClaimID)),
+				ClaimAddress: &params.ClaimAddress,
+				Languages:    []string{language},
+				Tags:         []string{SubtitlesTag},
+				FundingAccountIDs: []string{
+					params.DefaultAccount,
+				},
+			},
+			License:     util.PtrToString("Copyrighted (contact publisher)"),
+			ReleaseTime: util.PtrToInt64(v.publishedAt.Unix()),
+			ChannelID:   &v.lbryChannelID,
+		}
+		summary, err := publishAndRetryExistingNames(daemon, title, v.subtitlePath(language), params.Amount, options, params.Namer, v.walletLock)
+		if err != nil {
+			logUtils.SendErrorToSlack("failed to publish the %s subtitles of %s: %s", language, v.id, errors.FullTrace(err))
+			continue
+		}
+		if video.SubtitleClaimIDs == nil {
+			video.SubtitleClaimIDs = make(map[string]string)
+		}
+		video.SubtitleClaimIDs[language] = summary.ClaimID
+	}
+}
@@ -0,0 +1,66 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lbryio/ytsync/v5/downloader/ytdl"
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubtitleLanguages(t *testing.T) {
+	track := []ytdl.SubtitleTrack{{Ext: "vtt", URL: "https://example.com/subs"}}
+	info := &ytdl.YtdlVideo{
+		Subtitles: map[string][]ytdl.SubtitleTrack{
+			"fr":        track,
+			"en":        track,
+			"live_chat": {{Ext: "json"}},
+			"de":        nil,
+		},
+		AutomaticCaptions: map[string][]ytdl.SubtitleTrack{
+			"es-orig": track,
+			"es":      track,
+			"en":      track,
+			"it":      track,
+		},
+	}
+
+	assert.Nil(t, subtitleLanguages(info, nil))
+	assert.Equal(t, []string{"en", "fr"}, subtitleLanguages(info, &shared.SubtitlesConfig{}))
+	assert.Equal(t, []string{"en", "es", "fr"}, subtitleLanguages(info, &shared.SubtitlesConfig{AutoCaptions: true}))
+	assert.Equal(t, []string{"fr"}, subtitleLanguages(info, &shared.SubtitlesConfig{Languages: []string{"fr", "it"}}))
+	assert.Equal(t, []string{"fr", "it"}, subtitleLanguages(info, &shared.SubtitlesConfig{Languages: []string{"fr", "it"}, AutoCaptions: true}))
+}
+
+func TestSubtitleArgs(t *testing.T) {
+	assert.Nil(t, subtitleArgs(nil, &shared.SubtitlesConfig{}))
+
+	args := subtitleArgs([]string{"en", "fr"}, &shared.SubtitlesConfig{})
+	assert.Contains(t, args, "en,fr")
+	assert.Contains(t, args, "--embed-subs")
+	assert.NotContains(t, args, "--write-auto-subs")
+
+	args = subtitleArgs([]string{"en"}, &shared.SubtitlesConfig{AutoCaptions: true, Mode: shared.SubtitlesCompanion})
+	assert.Contains(t, args, "--write-auto-subs")
+	assert.NotContains(t, args, "--embed-subs")
+}
+
+func TestDownloadedSubtitleLanguages(t *testing.T) {
+	track := []ytdl.SubtitleTrack{{Ext: "vtt", URL: "https://example.com/subs"}}
+	v := &YoutubeVideo{
+		id:        "abc",
+		title:     "a video",
+		dir:       t.TempDir(),
+		subtitles: &shared.SubtitlesConfig{Mode: shared.SubtitlesCompanion},
+		youtubeInfo: &ytdl.YtdlVideo{
+			Subtitles: map[string][]ytdl.SubtitleTrack{"en": track, "fr": track},
+		},
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Dir(v.subtitlePath("fr")), 0755))
+	assert.NoError(t, os.WriteFile(v.subtitlePath("fr"), []byte("WEBVTT"), 0644))
+	// yt-dlp couldn't fetch the english subtitles
+	assert.Equal(t, []string{"fr"}, v.downloadedSubtitleLanguages())
+}
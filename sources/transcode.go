@@ -0,0 +1,141 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/ip_manager"
+	"github.com/lbryio/ytsync/v5/progress"
+	"github.com/lbryio/ytsync/v5/transcoder"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vbauerster/mpb/v7"
+	"github.com/vbauerster/mpb/v7/decor"
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+// anyCodecFormat selects the best streams whatever their codecs, for videos that are transcoded after the download
+func anyCodecFormat(maxHeight string) string {
+	return "bestvideo[height<=" + maxHeight + "]+bestaudio/best[height<=" + maxHeight + "]"
+}
+
+// preferredCodecFormat selects H.264/AAC streams when the site has them, and the best streams whatever their codecs
+// otherwise, for sites serving a single stream or other codecs only
+func preferredCodecFormat(maxHeight string) string {
+	return "bestvideo[vcodec^=avc1][height<=" + maxHeight + "]+bestaudio[acodec^=mp4a]/best[vcodec^=avc1][acodec^=mp4a][height<=" + maxHeight + "]/" + anyCodecFormat(maxHeight)
+}
+
+// capQualities drops the qualities above maxHeight, which would be scaled down by the transcode anyway. The lowest
+// quality is always kept
+func capQualities(qualities []string, maxHeight int) []string {
+	if maxHeight <= 0 {
+		return qualities
+	}
+	var capped []string
+	for _, q := range qualities {
+		height, err := strconv.Atoi(q)
+		if err == nil && height <= maxHeight {
+			capped = append(capped, q)
+		}
+	}
+	if len(capped) == 0 {
+		return qualities[len(qualities)-1:]
+	}
+	return capped
+}
+
+// transcode runs the downloaded video through the transcode profile of the channel when it isn't already H.264/AAC
+// within the caps of the profile, then validates the result
+func (v *YoutubeVideo) transcode() error {
+	start := time.Now()
+	defer func(start time.Time) {
+		v.timings.TimedComponent("transcode").Add(time.Since(start))
+	}(start)
+
+	downloadedPath, err := v.getDownloadedPath()
+	if err != nil {
+		return err
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancelFn()
+	data, err := ffprobe.ProbeURL(ctx, downloadedPath)
+	if err != nil {
+		log.Warnf("%s: failed to probe the video before transcoding it: %s", v.id, err.Error())
+		data = nil
+	}
+	duration := time.Duration(v.youtubeInfo.Duration) * time.Second
+	if v.transcodeProfile.NeedsTranscode(data) {
+		update, done := v.trackTranscodeProgress(duration)
+		err = v.transcodeProfile.Transcode(downloadedPath, v.getFullPath(), duration, v.stopGroup.Ch(), update)
+		done()
+		if errors.Is(err, transcoder.ErrInterrupted) {
+			return errors.Err(ip_manager.ErrInterruptedByUser)
+		}
+		if err != nil {
+			return err
+		}
+		if downloadedPath != v.getFullPath() {
+			err = os.Remove(downloadedPath)
+			if err != nil {
+				return errors.Err(err)
+			}
+			downloadedPath = v.getFullPath()
+		}
+	} else {
+		log.Debugf("%s: already fits the %s transcode profile", v.id, v.transcodeProfile.Name)
+	}
+	// the download was only partially validated as the container and the codecs were going to change
+	err = validateVideoFile(downloadedPath, duration, true, true)
+	if err != nil {
+		return errors.Prefix("invalid video", err)
+	}
+	fi, err := os.Stat(downloadedPath)
+	if err != nil {
+		return errors.Err(err)
+	}
+	videoSize := fi.Size()
+	v.size = &videoSize
+	return nil
+}
+
+// trackTranscodeProgress returns the callback feeding the progress reported by ffmpeg to the progress bar of the video
+// and to the status endpoint, along with the function removing the bar once the transcode is over
+func (v *YoutubeVideo) trackTranscodeProgress(duration time.Duration) (func(time.Duration), func()) {
+	videoProgress := progress.ForVideo(v.id)
+	var bar *mpb.Bar
+	if v.progressBars != nil && duration > 0 {
+		v.progressBarWg.Add(1)
+		bar = v.progressBars.AddBar(int64(duration.Seconds()),
+			mpb.PrependDecorators(
+				decor.Name(fmt.Sprintf("id: %s transcode (%s) ", v.id, v.transcodeProfile.Name)),
+				decor.Percentage(decor.WCSyncSpace),
+			),
+			mpb.AppendDecorators(
+				decor.OnComplete(decor.EwmaETA(decor.ET_STYLE_GO, 60), "done"),
+			),
+			mpb.BarRemoveOnComplete(),
+		)
+	}
+	lastUpdate := time.Now()
+	update := func(done time.Duration) {
+		videoProgress.SetTranscoded(done, duration)
+		if bar != nil {
+			bar.SetCurrent(int64(done.Seconds()))
+			bar.DecoratorEwmaUpdate(time.Since(lastUpdate))
+			lastUpdate = time.Now()
+		}
+	}
+	done := func() {
+		if bar != nil {
+			defer v.progressBarWg.Done()
+			bar.Completed()
+			bar.Abort(true)
+		}
+	}
+	return update, done
+}
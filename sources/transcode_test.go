@@ -0,0 +1,45 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lbryio/ytsync/v5/transcoder"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapQualities(t *testing.T) {
+	qualities := []string{"1080", "720", "480", "360"}
+	assert.Equal(t, qualities, capQualities(qualities, 0))
+	assert.Equal(t, []string{"720", "480", "360"}, capQualities(qualities, 720))
+	assert.Equal(t, []string{"480", "360"}, capQualities(qualities, 600))
+	assert.Equal(t, []string{"360"}, capQualities(qualities, 240))
+}
+
+func TestPreferredCodecFormat(t *testing.T) {
+	format := preferredCodecFormat("720")
+	// H.264/AAC first, then whatever the site has
+	assert.True(t, strings.HasPrefix(format, "bestvideo[vcodec^=avc1][height<=720]+bestaudio[acodec^=mp4a]/"))
+	assert.True(t, strings.HasSuffix(format, "/"+anyCodecFormat("720")))
+}
+
+func TestDownloadedVideoPath(t *testing.T) {
+	v := &YoutubeVideo{id: "abc", title: "a video", dir: t.TempDir()}
+	videoPath, err := v.downloadedVideoPath()
+	assert.NoError(t, err)
+	assert.Equal(t, v.getFullPath(), videoPath)
+
+	// videos that are transcoded keep the container they were downloaded in
+	v.transcodeProfile = &transcoder.Profile{Name: "test"}
+	_, err = v.downloadedVideoPath()
+	assert.Error(t, err)
+	assert.NoError(t, os.MkdirAll(v.videoDir(), 0755))
+	webm := filepath.Join(v.videoDir(), "a-video.webm")
+	assert.NoError(t, os.WriteFile(webm, []byte("webm"), 0644))
+	videoPath, err = v.downloadedVideoPath()
+	assert.NoError(t, err)
+	assert.Equal(t, webm, videoPath)
+}
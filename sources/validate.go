@@ -0,0 +1,165 @@
+package sources
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+// the validation errors are transient so that the video is downloaded again at another quality
+var (
+	ProbeFailedErr        error = shared.NewClassifiedError(shared.CategoryTransient, errors.Base("the downloaded video can't be probed"))
+	NotMP4Err             error = shared.NewClassifiedError(shared.CategoryTransient, errors.Base("the downloaded video is not an mp4"))
+	NotFaststartErr       error = shared.NewClassifiedError(shared.CategoryTransient, errors.Base("the downloaded video is not faststart"))
+	TruncatedFileErr      error = shared.NewClassifiedError(shared.CategoryTransient, errors.Base("the downloaded video is truncated"))
+	MissingVideoStreamErr error = shared.NewClassifiedError(shared.CategoryTransient, errors.Base("the downloaded video has no video stream"))
+	MissingAudioStreamErr error = shared.NewClassifiedError(shared.CategoryTransient, errors.Base("the downloaded video has no audio stream"))
+	CodecNotAllowedErr    error = shared.NewClassifiedError(shared.CategoryTransient, errors.Base("the downloaded video has a codec that isn't allowed"))
+	DurationMismatchErr   error = shared.NewClassifiedError(shared.CategoryTransient, errors.Base("the downloaded video duration doesn't match the original"))
+)
+
+var (
+	allowedVideoCodecs = map[string]bool{"h264": true, "hevc": true}
+	allowedAudioCodecs = map[string]bool{"aac": true, "mp3": true}
+)
+
+const (
+	probeTimeout = 30 * time.Second
+	// the downloaded duration may differ from the rounded duration reported by youtube by the largest of these
+	minDurationTolerance      = 3 * time.Second
+	relativeDurationTolerance = 0.01
+)
+
+// validateVideoFile checks that a downloaded video is complete and playable before it gets published. Without strict,
+// the container isn't checked as the video is transcoded afterwards. The codecs are only checked with codecs.
+// expectedDuration isn't checked when it's unknown
+func validateVideoFile(filePath string, expectedDuration time.Duration, strict bool, codecs bool) error {
+	if strict {
+		err := checkMP4Layout(filePath)
+		if err != nil {
+			return err
+		}
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancelFn()
+	data, err := ffprobe.ProbeURL(ctx, filePath)
+	if err != nil {
+		return errors.Prefix(err.Error(), ProbeFailedErr)
+	}
+	return checkProbeData(data, expectedDuration, strict, codecs)
+}
+
+func checkProbeData(data *ffprobe.ProbeData, expectedDuration time.Duration, strict bool, codecs bool) error {
+	if data.Format == nil {
+		return errors.Err(ProbeFailedErr)
+	}
+	if strict && !strings.Contains(data.Format.FormatName, "mp4") {
+		return errors.Prefix(data.Format.FormatName, NotMP4Err)
+	}
+	video := data.FirstVideoStream()
+	if video == nil {
+		return errors.Err(MissingVideoStreamErr)
+	}
+	audio := data.FirstAudioStream()
+	if audio == nil {
+		return errors.Err(MissingAudioStreamErr)
+	}
+	if codecs && !allowedVideoCodecs[video.CodecName] {
+		return errors.Prefix("video codec "+video.CodecName, CodecNotAllowedErr)
+	}
+	if codecs && !allowedAudioCodecs[audio.CodecName] {
+		return errors.Prefix("audio codec "+audio.CodecName, CodecNotAllowedErr)
+	}
+	if expectedDuration > 0 {
+		tolerance := time.Duration(float64(expectedDuration) * relativeDurationTolerance)
+		if tolerance < minDurationTolerance {
+			tolerance = minDurationTolerance
+		}
+		diff := data.Format.Duration() - expectedDuration
+		if diff > tolerance || -diff > tolerance {
+			return errors.Prefix(data.Format.Duration().String()+" instead of "+expectedDuration.String(), DurationMismatchErr)
+		}
+	}
+	return nil
+}
+
+// checkMP4Layout walks the top level boxes of an mp4: it must start with ftyp, have its moov before its mdat so that
+// it can be played while it's downloaded, and no box may extend past the end of the file
+func checkMP4Layout(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return errors.Err(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return errors.Err(err)
+	}
+	fileSize := fi.Size()
+
+	var boxes []string
+	header := make([]byte, 16)
+	for offset := int64(0); offset < fileSize; {
+		if fileSize-offset < 8 {
+			return errors.Prefix("incomplete box header", TruncatedFileErr)
+		}
+		_, err = f.ReadAt(header[:8], offset)
+		if err != nil {
+			return errors.Err(err)
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			// the last box extends to the end of the file
+			size = fileSize - offset
+		case 1:
+			_, err = f.ReadAt(header[8:16], offset+8)
+			if err == io.EOF {
+				return errors.Prefix("incomplete box header", TruncatedFileErr)
+			} else if err != nil {
+				return errors.Err(err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if len(boxes) == 0 && boxType != "ftyp" {
+			return errors.Prefix("starts with "+boxType, NotMP4Err)
+		}
+		if size < headerSize || offset+size > fileSize {
+			return errors.Prefix(boxType+" box extends past the end of the file", TruncatedFileErr)
+		}
+		boxes = append(boxes, boxType)
+		offset += size
+	}
+
+	moov, mdat := -1, -1
+	for i, box := range boxes {
+		if box == "moov" && moov < 0 {
+			moov = i
+		}
+		if box == "mdat" && mdat < 0 {
+			mdat = i
+		}
+	}
+	if moov < 0 {
+		return errors.Prefix("no moov box", TruncatedFileErr)
+	}
+	if mdat < 0 {
+		return errors.Prefix("no mdat box", TruncatedFileErr)
+	}
+	if mdat < moov {
+		return errors.Err(NotFaststartErr)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package sources
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+func box(boxType string, payload int) []byte {
+	b := make([]byte, 8+payload)
+	binary.BigEndian.PutUint32(b, uint32(8+payload))
+	copy(b[4:], boxType)
+	return b
+}
+
+func writeBoxes(t *testing.T, boxes ...[]byte) string {
+	var content []byte
+	for _, b := range boxes {
+		content = append(content, b...)
+	}
+	p := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(p, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCheckMP4Layout(t *testing.T) {
+	assert.NoError(t, checkMP4Layout(writeBoxes(t, box("ftyp", 16), box("moov", 100), box("mdat", 1000))))
+
+	err := checkMP4Layout(writeBoxes(t, box("ftyp", 16), box("mdat", 1000), box("moov", 100)))
+	assert.True(t, errors.Is(err, NotFaststartErr))
+
+	err = checkMP4Layout(writeBoxes(t, box("moov", 100), box("mdat", 1000)))
+	assert.True(t, errors.Is(err, NotMP4Err))
+
+	truncated := box("mdat", 1000)[:500]
+	err = checkMP4Layout(writeBoxes(t, box("ftyp", 16), box("moov", 100), truncated))
+	assert.True(t, errors.Is(err, TruncatedFileErr))
+
+	err = checkMP4Layout(writeBoxes(t, box("ftyp", 16), box("mdat", 1000)))
+	assert.True(t, errors.Is(err, TruncatedFileErr))
+
+	err = checkMP4Layout(writeBoxes(t))
+	assert.True(t, errors.Is(err, TruncatedFileErr))
+
+	// a size of 0 means the box extends to the end of the file
+	lastBox := box("mdat", 1000)
+	binary.BigEndian.PutUint32(lastBox, 0)
+	assert.NoError(t, checkMP4Layout(writeBoxes(t, box("ftyp", 16), box("moov", 100), lastBox)))
+
+	// a size of 1 means the size is in the 64 bits following the type
+	largeBox := box("mdat", 1008)
+	binary.BigEndian.PutUint32(largeBox, 1)
+	binary.BigEndian.PutUint64(largeBox[8:], uint64(len(largeBox)))
+	assert.NoError(t, checkMP4Layout(writeBoxes(t, box("ftyp", 16), box("moov", 100), largeBox)))
+}
+
+func TestCheckProbeData(t *testing.T) {
+	probe := func(container, videoCodec, audioCodec string, duration float64) *ffprobe.ProbeData {
+		data := &ffprobe.ProbeData{Format: &ffprobe.Format{FormatName: container, DurationSeconds: duration}}
+		if videoCodec != "" {
+			data.Streams = append(data.Streams, &ffprobe.Stream{CodecType: "video", CodecName: videoCodec})
+		}
+		if audioCodec != "" {
+			data.Streams = append(data.Streams, &ffprobe.Stream{CodecType: "audio", CodecName: audioCodec})
+		}
+		return data
+	}
+	const mp4 = "mov,mp4,m4a,3gp,3g2,mj2"
+	tenMinutes := 10 * time.Minute
+
+	assert.NoError(t, checkProbeData(probe(mp4, "h264", "aac", 600.4), tenMinutes, true, true))
+	assert.NoError(t, checkProbeData(probe(mp4, "h264", "aac", 605), tenMinutes, true, true))
+	assert.NoError(t, checkProbeData(probe(mp4, "h264", "aac", 100), 0, true, true))
+	assert.True(t, errors.Is(checkProbeData(probe(mp4, "h264", "aac", 300), tenMinutes, true, true), DurationMismatchErr))
+	assert.True(t, errors.Is(checkProbeData(probe("matroska,webm", "h264", "aac", 600), tenMinutes, true, true), NotMP4Err))
+	assert.True(t, errors.Is(checkProbeData(probe(mp4, "", "aac", 600), tenMinutes, true, true), MissingVideoStreamErr))
+	assert.True(t, errors.Is(checkProbeData(probe(mp4, "h264", "", 600), tenMinutes, true, true), MissingAudioStreamErr))
+	assert.True(t, errors.Is(checkProbeData(probe(mp4, "vp9", "aac", 600), tenMinutes, true, true), CodecNotAllowedErr))
+	assert.True(t, errors.Is(checkProbeData(probe(mp4, "h264", "opus", 600), tenMinutes, true, true), CodecNotAllowedErr))
+
+	// the codecs aren't checked for the sites whose formats can't be selected by codec
+	assert.NoError(t, checkProbeData(probe(mp4, "vp9", "opus", 600), tenMinutes, true, false))
+	assert.True(t, errors.Is(checkProbeData(probe("matroska,webm", "vp9", "opus", 600), tenMinutes, true, false), NotMP4Err))
+
+	// the codecs and the container are only checked after the transcode
+	assert.NoError(t, checkProbeData(probe("matroska,webm", "vp9", "opus", 600), tenMinutes, false, false))
+	assert.True(t, errors.Is(checkProbeData(probe("matroska,webm", "vp9", "", 600), tenMinutes, false, false), MissingAudioStreamErr))
+}
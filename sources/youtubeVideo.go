@@ -2,11 +2,8 @@ package sources
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -15,14 +12,17 @@ import (
 	"sync"
 	"time"
 
+	"github.com/lbryio/ytsync/v5/downloader"
 	"github.com/lbryio/ytsync/v5/downloader/ytdl"
 	"github.com/lbryio/ytsync/v5/ip_manager"
 	"github.com/lbryio/ytsync/v5/namer"
+	"github.com/lbryio/ytsync/v5/progress"
 	"github.com/lbryio/ytsync/v5/sdk"
 	"github.com/lbryio/ytsync/v5/shared"
 	"github.com/lbryio/ytsync/v5/tags_manager"
 	"github.com/lbryio/ytsync/v5/thumbs"
 	"github.com/lbryio/ytsync/v5/timing"
+	"github.com/lbryio/ytsync/v5/transcoder"
 	logUtils "github.com/lbryio/ytsync/v5/util"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
@@ -38,6 +38,8 @@ import (
 	"gopkg.in/vansante/go-ffprobe.v2"
 )
 
+// YoutubeVideo is a video downloaded with yt-dlp. Despite its name it can come from any site yt-dlp supports, what
+// differs between sites is held by its profile
 type YoutubeVideo struct {
 	id               string
 	title            string
@@ -48,6 +50,7 @@ type YoutubeVideo struct {
 	maxVideoLength   time.Duration
 	publishedAt      time.Time
 	dir              string
+	metadataCache    *downloader.MetadataCache
 	youtubeInfo      *ytdl.YtdlVideo
 	youtubeChannelID string
 	tags             []string
@@ -59,6 +62,85 @@ type YoutubeVideo struct {
 	pool             *ip_manager.IPPool
 	progressBars     *mpb.Progress
 	progressBarWg    *sync.WaitGroup
+	profile          *videoProfile
+	// sourceFile is the media file of videos imported from a local directory
+	sourceFile string
+	// subtitles is the subtitles configuration of the channel, nil when they aren't synced
+	subtitles *shared.SubtitlesConfig
+	// transcodeProfile is the transcode profile of the channel, nil when the videos are published as downloaded
+	transcodeProfile *transcoder.Profile
+	// timings are the component timings of the pipeline syncing the video
+	timings *timing.Timings
+}
+
+// videoProfile holds what differs between the sites videos are downloaded from
+type videoProfile struct {
+	// extraArgs are passed to yt-dlp for every download
+	extraArgs []string
+	// formatSelector returns the yt-dlp format for videos up to maxHeight pixels high
+	formatSelector func(maxHeight string) string
+	// anyCodec means that formatSelector can fall back to codecs the LBRY apps can't play, the codecs of the downloads
+	// are then only checked when they are transcoded
+	anyCodec bool
+	// requirePublic rejects videos that aren't known to be public. Most sites don't report the availability
+	requirePublic bool
+	// webpageURL is the link to the original video appended to the description
+	webpageURL func(v *YoutubeVideo) string
+	// thumbnailURLs returns the thumbnail to mirror and a fallback if mirroring it fails
+	thumbnailURLs func(info *ytdl.YtdlVideo) (string, string, error)
+	// mirrorThumbnail uploads the thumbnail returned by thumbnailURLs and returns its public URL, and whether it differs
+	// from the one mirrored before
+	mirrorThumbnail func(location string, name string) (string, bool, error)
+	// download puts the video file in the video directory
+	download func(v *YoutubeVideo) error
+}
+
+var youtubeProfile = &videoProfile{
+	extraArgs: []string{"--extractor-args", "youtube:player_client=android"},
+	formatSelector: func(maxHeight string) string {
+		return "bestvideo[ext=mp4][vcodec!*=av01][vcodec!*=vp09][height<=" + maxHeight + "]+bestaudio[ext!=webm][format_id!*=258][format_id!*=380][format_id!*=251][format_id!*=256][format_id!*=327][format_id!*=328][format_id!*=380]"
+	},
+	requirePublic: true,
+	webpageURL: func(v *YoutubeVideo) string {
+		return "https://www.youtube.com/watch?v=" + v.id
+	},
+	thumbnailURLs: func(info *ytdl.YtdlVideo) (string, string, error) {
+		thumbnail := thumbs.GetBestThumbnail(info.Thumbnails)
+		if thumbnail.Width == 0 {
+			return "", "", errors.Err("default youtube thumbnail found")
+		}
+		return thumbnail.URL, info.GetThumbnailUrl(), nil
+	},
+	mirrorThumbnail: thumbs.MirrorThumbnail,
+	download:        (*YoutubeVideo).Download,
+}
+
+var genericProfile = &videoProfile{
+	// many sites serve audio and video in a single stream
+	formatSelector: preferredCodecFormat,
+	anyCodec:       true,
+	webpageURL: func(v *YoutubeVideo) string {
+		if v.youtubeInfo == nil {
+			return ""
+		}
+		return v.youtubeInfo.WebpageURL
+	},
+	thumbnailURLs: func(info *ytdl.YtdlVideo) (string, string, error) {
+		best := thumbs.GetBestThumbnail(info.Thumbnails).URL
+		if best == "" && len(info.Thumbnails) > 0 {
+			// thumbnails without sizes are listed from the worst to the best
+			best = info.Thumbnails[len(info.Thumbnails)-1].URL
+		}
+		if best == "" {
+			best = info.Thumbnail
+		}
+		if best == "" {
+			return "", "", errors.Err("no thumbnail found")
+		}
+		return best, info.Thumbnail, nil
+	},
+	mirrorThumbnail: thumbs.MirrorThumbnail,
+	download:        (*YoutubeVideo).Download,
 }
 
 var youtubeCategories = map[string]string{
@@ -96,7 +178,7 @@ var youtubeCategories = map[string]string{
 	"44": "trailers",
 }
 
-func NewYoutubeVideo(directory string, videoData *ytdl.YtdlVideo, playlistPosition int64, stopGroup *stop.Group, pool *ip_manager.IPPool) (*YoutubeVideo, error) {
+func NewYoutubeVideo(directory string, metadataCache *downloader.MetadataCache, videoData *ytdl.YtdlVideo, playlistPosition int64, stopGroup *stop.Group, pool *ip_manager.IPPool) (*YoutubeVideo, error) {
 	// youtube-dl returns times in local timezone sometimes. this could break in the future
 	// maybe we can file a PR to choose the timezone we want from youtube-dl
 	return &YoutubeVideo{
@@ -106,11 +188,13 @@ func NewYoutubeVideo(directory string, videoData *ytdl.YtdlVideo, playlistPositi
 		playlistPosition: playlistPosition,
 		publishedAt:      videoData.GetUploadTime(),
 		dir:              directory,
+		metadataCache:    metadataCache,
 		youtubeInfo:      videoData,
 		mocked:           false,
 		youtubeChannelID: videoData.ChannelID,
 		stopGroup:        stopGroup,
 		pool:             pool,
+		profile:          youtubeProfile,
 	}, nil
 }
 
@@ -123,6 +207,7 @@ func NewMockedVideo(directory string, videoID string, youtubeChannelID string, s
 		youtubeChannelID: youtubeChannelID,
 		stopGroup:        stopGroup,
 		pool:             pool,
+		profile:          youtubeProfile,
 	}
 }
 
@@ -130,6 +215,13 @@ func (v *YoutubeVideo) ID() string {
 	return v.id
 }
 
+func (v *YoutubeVideo) Item() Item {
+	if v.sourceFile != "" {
+		return Item{ID: v.id, URL: v.sourceFile}
+	}
+	return Item{ID: v.id, URL: v.profile.webpageURL(v)}
+}
+
 func (v *YoutubeVideo) PlaylistPosition() int {
 	return int(v.playlistPosition)
 }
@@ -175,234 +267,71 @@ func (v *YoutubeVideo) getFullPath() string {
 func (v *YoutubeVideo) getAbbrevDescription() string {
 	maxLength := 6500
 	description := strings.TrimSpace(v.description)
-	additionalDescription := "\nhttps://www.youtube.com/watch?v=" + v.id
+	additionalDescription := ""
+	if webpageURL := v.profile.webpageURL(v); webpageURL != "" {
+		additionalDescription = "\n" + webpageURL
+	}
 	khanAcademyClaimID := "5fc52291980268b82413ca4c0ace1b8d749f3ffb"
 	if v.lbryChannelID == khanAcademyClaimID {
 		additionalDescription = additionalDescription + "\nNote: All Khan Academy content is available for free at (www.khanacademy.org)"
 	}
+	chapters := ""
+	if v.youtubeInfo != nil && !hasChapterList(description) {
+		// the chapters get at most half of the budget so that they never push out the whole description
+		chapters = renderChapters(v.youtubeInfo.Chapters, maxLength/2)
+	}
+	maxLength -= len(chapters)
 	if len(description) > maxLength {
 		description = description[:maxLength]
 	}
-	return description + "\n..." + additionalDescription
-}
-func checkCookiesIntegrity() error {
-	fi, err := os.Stat("cookies.txt")
-	if err != nil {
-		return errors.Err(err)
-	}
-	if fi.Size() == 0 {
-		log.Errorf("cookies were cleared out. Attempting a restore from cookies-backup.txt")
-		input, err := os.ReadFile("cookies-backup.txt")
-		if err != nil {
-			return errors.Err(err)
-		}
-
-		err = os.WriteFile("cookies.txt", input, 0644)
-		if err != nil {
-			return errors.Err(err)
-		}
-	}
-	return nil
+	return description + chapters + "\n..." + additionalDescription
 }
 
-func (v *YoutubeVideo) trackProgressBar(argsWithFilters []string, metadata *ytMetadata, done *stop.Group, sourceAddress string) {
+// trackProgress returns the callback feeding the progress reported by yt-dlp to the progress bar of the video and to
+// the status endpoint, along with the function removing the bar once the download is over
+func (v *YoutubeVideo) trackProgress(sourceAddress string) (func(downloader.DownloadProgress), func()) {
 	v.progressBarWg.Add(1)
-	defer v.progressBarWg.Done()
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-	//attempt getting size of the video before downloading
-	cmd := exec.Command("yt-dlp", append(argsWithFilters, "-s")...)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Errorf("error while getting final file size: %s", errors.FullTrace(err))
-		return
-	}
-
-	if err := cmd.Start(); err != nil {
-		log.Errorf("error while getting final file size: %s", errors.FullTrace(err))
-		return
-	}
-	outLog, _ := io.ReadAll(stdout)
-	err = cmd.Wait()
-	output := string(outLog)
-	parts := strings.Split(output, ": ")
-	if len(parts) != 3 {
-		log.Errorf("couldn't parse audio and video parts from the output (%s)", output)
-		return
-	}
-	formats := strings.Split(parts[2], "+")
-	if len(formats) != 2 {
-		log.Errorf("couldn't parse formats from the output (%s)", output)
-		return
-	}
-	log.Debugf("'%s'", output)
-	videoFormat := formats[0]
-	audioFormat := strings.Replace(formats[1], "\n", "", -1)
-
-	videoSize := 0
-	audioSize := 0
-	if metadata != nil {
-		for _, f := range metadata.Formats {
-			if f.FormatID == videoFormat {
-				videoSize = f.Filesize
-			}
-			if f.FormatID == audioFormat {
-				audioSize = f.Filesize
-			}
-		}
-	}
-
-	log.Debugf("(%s) - videoSize: %d (%s), audiosize: %d (%s)", v.id, videoSize, videoFormat, audioSize, audioFormat)
-	bar := v.progressBars.AddBar(int64(videoSize+audioSize),
-		mpb.PrependDecorators(
-			decor.CountersKibiByte("% .2f / % .2f "),
-			// simple name decorator
-			decor.Name(fmt.Sprintf("id: %s src-ip: (%s)", v.id, sourceAddress)),
-			// decor.DSyncWidth bit enables column width synchronization
-			decor.Percentage(decor.WCSyncSpace),
-		),
-		mpb.AppendDecorators(
-			decor.EwmaETA(decor.ET_STYLE_GO, 90),
-			decor.Name(" ] "),
-			decor.EwmaSpeed(decor.UnitKiB, "% .2f ", 60),
-			decor.OnComplete(
-				// ETA decorator with ewma age of 60
-				decor.EwmaETA(decor.ET_STYLE_GO, 60), "done",
-			),
-		),
-		mpb.BarRemoveOnComplete(),
-	)
-	defer func() {
-		bar.Completed()
-		bar.Abort(true)
-	}()
-	origSize := int64(0)
+	videoProgress := progress.ForVideo(v.id)
+	var bar *mpb.Bar
 	lastUpdate := time.Now()
-	for {
-		select {
-		case <-done.Ch():
-			return
-		case <-ticker.C:
-			var err error
-			size, err := logUtils.DirSize(v.videoDir())
-			if err != nil {
-				log.Errorf("error while getting size of download directory: %s", errors.FullTrace(err))
-				continue
-			}
-			if size > origSize {
-				origSize = size
-				bar.SetCurrent(size)
-				if size > int64(videoSize+audioSize) {
-					bar.SetTotal(size+2048, false)
-				}
-				bar.DecoratorEwmaUpdate(time.Since(lastUpdate))
-				lastUpdate = time.Now()
-			}
+	update := func(p downloader.DownloadProgress) {
+		videoProgress.SetExpectedBytes(p.Total)
+		videoProgress.SetDownloadedBytes(p.Bytes)
+		if bar == nil {
+			bar = v.progressBars.AddBar(p.Total,
+				mpb.PrependDecorators(
+					decor.CountersKibiByte("% .2f / % .2f "),
+					// simple name decorator
+					decor.Name(fmt.Sprintf("id: %s src-ip: (%s)", v.id, sourceAddress)),
+					// decor.DSyncWidth bit enables column width synchronization
+					decor.Percentage(decor.WCSyncSpace),
+				),
+				mpb.AppendDecorators(
+					decor.EwmaETA(decor.ET_STYLE_GO, 90),
+					decor.Name(" ] "),
+					decor.EwmaSpeed(decor.UnitKiB, "% .2f ", 60),
+					decor.OnComplete(
+						// ETA decorator with ewma age of 60
+						decor.EwmaETA(decor.ET_STYLE_GO, 60), "done",
+					),
+				),
+				mpb.BarRemoveOnComplete(),
+			)
+		}
+		// the total grows when yt-dlp moves on to the audio
+		bar.SetTotal(p.Total+2048, false)
+		bar.SetCurrent(p.Bytes)
+		bar.DecoratorEwmaUpdate(time.Since(lastUpdate))
+		lastUpdate = time.Now()
+	}
+	done := func() {
+		defer v.progressBarWg.Done()
+		if bar != nil {
+			bar.Completed()
+			bar.Abort(true)
 		}
 	}
-}
-
-type ytMetadata struct {
-	ID      string `json:"id"`
-	Title   string `json:"title"`
-	Formats []struct {
-		Asr               int         `json:"asr"`
-		Filesize          int         `json:"filesize"`
-		FormatID          string      `json:"format_id"`
-		FormatNote        string      `json:"format_note"`
-		Fps               interface{} `json:"fps"`
-		Height            interface{} `json:"height"`
-		Quality           int         `json:"quality"`
-		Tbr               float64     `json:"tbr"`
-		URL               string      `json:"url"`
-		Width             interface{} `json:"width"`
-		Ext               string      `json:"ext"`
-		Vcodec            string      `json:"vcodec"`
-		Acodec            string      `json:"acodec"`
-		Abr               float64     `json:"abr,omitempty"`
-		DownloaderOptions struct {
-			HTTPChunkSize int `json:"http_chunk_size"`
-		} `json:"downloader_options,omitempty"`
-		Container   string `json:"container,omitempty"`
-		Format      string `json:"format"`
-		Protocol    string `json:"protocol"`
-		HTTPHeaders struct {
-			UserAgent      string `json:"User-Agent"`
-			AcceptCharset  string `json:"Accept-Charset"`
-			Accept         string `json:"Accept"`
-			AcceptEncoding string `json:"Accept-Encoding"`
-			AcceptLanguage string `json:"Accept-Language"`
-		} `json:"http_headers"`
-		Vbr float64 `json:"vbr,omitempty"`
-	} `json:"formats"`
-	Thumbnails []struct {
-		Height     int    `json:"height"`
-		URL        string `json:"url"`
-		Width      int    `json:"width"`
-		Resolution string `json:"resolution"`
-		ID         string `json:"id"`
-	} `json:"thumbnails"`
-	Description        string        `json:"description"`
-	UploadDate         string        `json:"upload_date"`
-	Uploader           string        `json:"uploader"`
-	UploaderID         string        `json:"uploader_id"`
-	UploaderURL        string        `json:"uploader_url"`
-	ChannelID          string        `json:"channel_id"`
-	ChannelURL         string        `json:"channel_url"`
-	Duration           int           `json:"duration"`
-	ViewCount          int           `json:"view_count"`
-	AverageRating      float64       `json:"average_rating"`
-	AgeLimit           int           `json:"age_limit"`
-	WebpageURL         string        `json:"webpage_url"`
-	Categories         []string      `json:"categories"`
-	Tags               []interface{} `json:"tags"`
-	IsLive             interface{}   `json:"is_live"`
-	LikeCount          int           `json:"like_count"`
-	DislikeCount       int           `json:"dislike_count"`
-	Channel            string        `json:"channel"`
-	Extractor          string        `json:"extractor"`
-	WebpageURLBasename string        `json:"webpage_url_basename"`
-	ExtractorKey       string        `json:"extractor_key"`
-	Playlist           interface{}   `json:"playlist"`
-	PlaylistIndex      interface{}   `json:"playlist_index"`
-	Thumbnail          string        `json:"thumbnail"`
-	DisplayID          string        `json:"display_id"`
-	Format             string        `json:"format"`
-	FormatID           string        `json:"format_id"`
-	Width              int           `json:"width"`
-	Height             int           `json:"height"`
-	Resolution         interface{}   `json:"resolution"`
-	Fps                int           `json:"fps"`
-	Vcodec             string        `json:"vcodec"`
-	Vbr                float64       `json:"vbr"`
-	StretchedRatio     interface{}   `json:"stretched_ratio"`
-	Acodec             string        `json:"acodec"`
-	Abr                float64       `json:"abr"`
-	Ext                string        `json:"ext"`
-	Fulltitle          string        `json:"fulltitle"`
-	Filename           string        `json:"_filename"`
-}
-
-func parseVideoMetadata(metadataPath string) (*ytMetadata, error) {
-	f, err := os.Open(metadataPath)
-	if err != nil {
-		return nil, errors.Err(err)
-	}
-	// defer the closing of our jsonFile so that we can parse it later on
-	defer f.Close()
-	// read our opened jsonFile as a byte array.
-	byteValue, _ := io.ReadAll(f)
-
-	// we initialize our Users array
-	var m ytMetadata
-
-	// we unmarshal our byteArray which contains our
-	// jsonFile's content into 'users' which we defined above
-	err = json.Unmarshal(byteValue, &m)
-	if err != nil {
-		return nil, errors.Err(err)
-	}
-	return &m, nil
+	return update, done
 }
 
 func (v *YoutubeVideo) videoDir() string {
@@ -436,6 +365,7 @@ func (v *YoutubeVideo) delete(reason string) error {
 		return err
 	}
 	err = os.Remove(videoPath)
+	v.deleteSubtitles()
 	log.Debugf("%s deleted from disk for '%s' (%s)", v.id, reason, videoPath)
 
 	if err != nil {
@@ -448,21 +378,30 @@ func (v *YoutubeVideo) delete(reason string) error {
 }
 
 func (v *YoutubeVideo) triggerThumbnailSave() (err error) {
-	thumbnail := thumbs.GetBestThumbnail(v.youtubeInfo.Thumbnails)
-	if thumbnail.Width == 0 {
-		return errors.Err("default youtube thumbnail found")
+	thumbnailURL, fallbackURL, err := v.profile.thumbnailURLs(v.youtubeInfo)
+	if err == nil {
+		v.thumbnailURL, _, err = v.profile.mirrorThumbnail(thumbnailURL, v.ID())
+		if err != nil && fallbackURL != "" {
+			v.thumbnailURL, _, err = v.profile.mirrorThumbnail(fallbackURL, v.ID())
+		}
 	}
-	v.thumbnailURL, err = thumbs.MirrorThumbnail(thumbnail.URL, v.ID())
-	if err != nil {
-		v.thumbnailURL, err = thumbs.MirrorThumbnail(v.youtubeInfo.GetThumbnailUrl(), v.ID())
+	if err == nil {
+		return nil
+	}
+	// the video is only on disk when it's being published, not when its claim is upgraded
+	videoPath, pathErr := v.getDownloadedPath()
+	if pathErr != nil {
+		return err
 	}
+	log.Infof("%s: using a frame of the video as thumbnail: %s", v.id, err.Error())
+	v.thumbnailURL, _, err = thumbs.MirrorVideoFrame(videoPath, v.youtubeInfo.Duration, v.ID())
 	return err
 }
 
 func (v *YoutubeVideo) publish(daemon *jsonrpc.Client, params SyncParams) (*SyncSummary, error) {
 	start := time.Now()
 	defer func(start time.Time) {
-		timing.TimedComponent("publish").Add(time.Since(start))
+		v.timings.TimedComponent("publish").Add(time.Since(start))
 	}(start)
 	languages, locations, tags := v.getMetadata()
 	var fee *jsonrpc.Fee
@@ -488,6 +427,11 @@ func (v *YoutubeVideo) publish(daemon *jsonrpc.Client, params SyncParams) (*Sync
 		language := info2.Lang.Iso6391()
 		languages = []string{language}
 	}
+	for _, language := range v.downloadedSubtitleLanguages() {
+		if !util.InSlice(language, languages) {
+			languages = append(languages, language)
+		}
+	}
 	options := jsonrpc.StreamCreateOptions{
 		ClaimCreateOptions: jsonrpc.ClaimCreateOptions{
 			Title:        &v.title,
@@ -526,6 +470,9 @@ type SyncParams struct {
 	MaxVideoLength time.Duration
 	Fee            *shared.Fee
 	DefaultAccount string
+	Subtitles      *shared.SubtitlesConfig
+	Transcode      *transcoder.Profile
+	Timings        *timing.Timings
 }
 
 func (v *YoutubeVideo) Sync(daemon *jsonrpc.Client, params SyncParams, existingVideoData *sdk.SyncedVideo, reprocess bool, walletLock *sync.RWMutex, pbWg *sync.WaitGroup, pb *mpb.Progress) (*SyncSummary, error) {
@@ -535,11 +482,15 @@ func (v *YoutubeVideo) Sync(daemon *jsonrpc.Client, params SyncParams, existingV
 	v.walletLock = walletLock
 	v.progressBars = pb
 	v.progressBarWg = pbWg
+	v.subtitles = params.Subtitles
+	v.transcodeProfile = params.Transcode
+	v.timings = params.Timings
 	if reprocess && existingVideoData != nil && existingVideoData.Published {
 		summary, err := v.reprocess(daemon, params, existingVideoData)
-		return summary, errors.Prefix("upgrade failed", err)
+		return summary, shared.Classify(errors.Prefix("upgrade failed", err))
 	}
-	return v.downloadAndPublish(daemon, params)
+	summary, err := v.downloadAndPublish(daemon, params)
+	return summary, shared.Classify(err)
 }
 
 func (v *YoutubeVideo) downloadAndPublish(daemon *jsonrpc.Client, params SyncParams) (*SyncSummary, error) {
@@ -552,10 +503,17 @@ func (v *YoutubeVideo) downloadAndPublish(daemon *jsonrpc.Client, params SyncPar
 	dur := time.Duration(v.youtubeInfo.Duration) * time.Second
 	minDuration := 7 * time.Second
 
+	if v.youtubeInfo.LiveStatus == "is_upcoming" {
+		var releaseTime time.Time
+		if v.youtubeInfo.ReleaseTimestamp != nil && *v.youtubeInfo.ReleaseTimestamp > 0 {
+			releaseTime = time.Unix(*v.youtubeInfo.ReleaseTimestamp, 0)
+		}
+		return nil, shared.NewDeferredError(errors.Err("video is an upcoming premiere or live event"), releaseTime)
+	}
 	if v.youtubeInfo.IsLive == true {
-		return nil, errors.Err("video is a live stream and hasn't completed yet")
+		return nil, shared.NewDeferredError(errors.Err("video is a live stream and hasn't completed yet"), time.Time{})
 	}
-	if v.youtubeInfo.Availability != "public" && v.youtubeInfo.Availability != "needs_auth" {
+	if v.profile.requirePublic && v.youtubeInfo.Availability != "public" && v.youtubeInfo.Availability != "needs_auth" {
 		return nil, errors.Err("video is not public")
 	}
 	if dur > v.maxVideoLength {
@@ -569,10 +527,10 @@ func (v *YoutubeVideo) downloadAndPublish(daemon *jsonrpc.Client, params SyncPar
 
 	buggedLivestream := v.youtubeInfo.LiveStatus == "post_live"
 	if buggedLivestream && dur >= 2*time.Hour {
-		return nil, errors.Err("livestream is likely bugged as it was recently published and has a length of %s which is more than 2 hours", dur.String())
+		return nil, shared.NewDeferredError(errors.Err("livestream is likely bugged as it was recently published and has a length of %s which is more than 2 hours", dur.String()), time.Time{})
 	}
 	for {
-		err = v.Download()
+		err = v.profile.download(v)
 		if err != nil && strings.Contains(err.Error(), "HTTP Error 429") {
 			continue
 		} else if err != nil {
@@ -581,6 +539,15 @@ func (v *YoutubeVideo) downloadAndPublish(daemon *jsonrpc.Client, params SyncPar
 		break
 	}
 
+	videoProgress := progress.ForVideo(v.id)
+	if v.transcodeProfile != nil {
+		videoProgress.SetStage(progress.StageTranscode)
+		err = v.transcode()
+		if err != nil {
+			return nil, errors.Prefix("transcode error", err)
+		}
+	}
+
 	ctx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelFn()
 
@@ -592,13 +559,18 @@ func (v *YoutubeVideo) downloadAndPublish(daemon *jsonrpc.Client, params SyncPar
 			return nil, errors.Err("video is too short to process")
 		}
 	}
+	videoProgress.SetStage(progress.StageThumbnail)
 	err = v.triggerThumbnailSave()
 	if err != nil {
 		return nil, errors.Prefix("thumbnail error", err)
 	}
 	log.Debugln("Created thumbnail for " + v.id)
 
+	videoProgress.SetStage(progress.StagePublish)
 	summary, err := v.publish(daemon, params)
+	if err == nil && v.subtitles != nil && subtitlesMode(v.subtitles) == shared.SubtitlesCompanion {
+		v.publishSubtitles(daemon, params, summary)
+	}
 	//delete the video in all cases (and ignore the error)
 	_ = v.delete("finished download and publish")
 
@@ -657,22 +629,20 @@ func (v *YoutubeVideo) reprocess(daemon *jsonrpc.Client, params SyncParams, exis
 	currentClaim := c.Claims[0]
 	languages, locations, tags := v.getMetadata()
 
+	videoProgress := progress.ForVideo(v.id)
+	videoProgress.SetStage(progress.StageThumbnail)
 	thumbnailURL := ""
 	if currentClaim.Value.GetThumbnail() == nil {
 		if v.mocked {
 			return nil, errors.Err("could not find thumbnail for mocked video")
 		}
-		thumbnail := thumbs.GetBestThumbnail(v.youtubeInfo.Thumbnails)
-		if thumbnail.Width == 0 {
-			return nil, errors.Err("default youtube thumbnail found")
-		}
-		v.thumbnailURL, err = thumbs.MirrorThumbnail(thumbnail.URL, v.ID())
+		err = v.triggerThumbnailSave()
 		if err != nil {
-			v.thumbnailURL, err = thumbs.MirrorThumbnail(v.youtubeInfo.GetThumbnailUrl(), v.ID())
+			return nil, errors.Prefix("thumbnail error", err)
 		}
-		thumbnailURL, err = thumbs.MirrorThumbnail(v.youtubeInfo.GetThumbnailUrl(), v.ID())
+		thumbnailURL = v.thumbnailURL
 	} else {
-		thumbnailURL = thumbs.ThumbnailEndpoint + v.ID()
+		thumbnailURL = thumbs.GetStore().URL(v.ID())
 	}
 
 	videoSize, err := currentClaim.GetStreamSizeByMagic()
@@ -723,6 +693,7 @@ func (v *YoutubeVideo) reprocess(daemon *jsonrpc.Client, params SyncParams, exis
 		ReleaseTime: util.PtrToInt64(v.publishedAt.Unix()),
 	}
 
+	videoProgress.SetStage(progress.StagePublish)
 	v.walletLock.RLock()
 	defer v.walletLock.RUnlock()
 	if v.mocked {
@@ -731,7 +702,7 @@ func (v *YoutubeVideo) reprocess(daemon *jsonrpc.Client, params SyncParams, exis
 			StreamCreateOptions: streamCreateOptions,
 			FileSize:            &videoSize,
 		})
-		timing.TimedComponent("StreamUpdate").Add(time.Since(start))
+		v.timings.TimedComponent("StreamUpdate").Add(time.Since(start))
 		if err != nil {
 			return nil, err
 		}
@@ -754,7 +725,7 @@ func (v *YoutubeVideo) reprocess(daemon *jsonrpc.Client, params SyncParams, exis
 		StreamCreateOptions: streamCreateOptions,
 		FileSize:            &videoSize,
 	})
-	timing.TimedComponent("StreamUpdate").Add(time.Since(start))
+	v.timings.TimedComponent("StreamUpdate").Add(time.Since(start))
 	if err != nil {
 		return nil, err
 	}
@@ -4,6 +4,8 @@ import (
 	"regexp"
 	"testing"
 
+	"github.com/lbryio/ytsync/v5/downloader/ytdl"
+
 	"github.com/abadojack/whatlanggo"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -51,3 +53,20 @@ IG：ctlamusic`
 	assert.True(t, info.Lang.Iso6391() != "")
 	assert.Equal(t, "zh", info.Lang.Iso6391())
 }
+
+func TestGenericProfileThumbnails(t *testing.T) {
+	best, fallback, err := genericProfile.thumbnailURLs(&ytdl.YtdlVideo{
+		Thumbnail:  "https://example.com/default.jpg",
+		Thumbnails: []ytdl.Thumbnail{{URL: "https://example.com/small.jpg"}, {URL: "https://example.com/large.jpg"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/large.jpg", best)
+	assert.Equal(t, "https://example.com/default.jpg", fallback)
+
+	best, _, err = genericProfile.thumbnailURLs(&ytdl.YtdlVideo{Thumbnail: "https://example.com/default.jpg"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/default.jpg", best)
+
+	_, _, err = genericProfile.thumbnailURLs(&ytdl.YtdlVideo{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package sources
+
+import (
+	"strings"
+
+	"github.com/lbryio/ytsync/v5/downloader"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+)
+
+// YtDlpSource lists the videos of a channel or playlist on any site supported by yt-dlp (Vimeo, PeerTube, Rumble...).
+// Its videos go through the same IP pool, cookies and download retries as youtube videos
+type YtDlpSource struct {
+	channelID string
+	url       string
+	params    SourceParams
+}
+
+// NewYtDlpSource returns a source for the playlist at url. channelID is the ID of the channel in the job store
+func NewYtDlpSource(channelID string, url string, params SourceParams) *YtDlpSource {
+	return &YtDlpSource{channelID: channelID, url: url, params: params}
+}
+
+func (s *YtDlpSource) ListItems(maxItems int) ([]Item, error) {
+	playlist, err := downloader.GetPlaylistItems(s.url, maxItems, s.params.Stopper.Ch(), s.params.IPPool)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(playlist))
+	for _, p := range playlist {
+		items = append(items, Item{ID: foreignVideoID(p.Extractor, p.ID), URL: p.URL})
+	}
+	return items, nil
+}
+
+// foreignVideoID prefixes the ID given by a site with its extractor, as IDs are only unique within a site and must
+// never be mistaken for youtube IDs
+func foreignVideoID(extractor string, id string) string {
+	return strings.ToLower(extractor) + ":" + id
+}
+
+func (s *YtDlpSource) FetchMetadata(item Item, playlistPosition int64) (Video, error) {
+	if item.URL == "" {
+		return nil, errors.Err("the URL of %s is unknown", item.ID)
+	}
+	info, err := downloader.GetVideoInformationFromURL(item.ID, item.URL, s.params.MetadataCache, s.params.Stopper.Ch(), s.params.IPPool)
+	if err != nil {
+		return nil, err
+	}
+	video, err := NewYoutubeVideo(s.params.VideoDir, s.params.MetadataCache, info, playlistPosition, s.params.Stopper, s.params.IPPool)
+	if err != nil {
+		return nil, err
+	}
+	video.profile = genericProfile
+	video.id = item.ID
+	// the uploader ID of other sites means nothing to the tag manager
+	video.youtubeChannelID = s.channelID
+	return video, nil
+}
+
+func (s *YtDlpSource) MockedVideo(videoID string) Video {
+	video := NewMockedVideo(s.params.VideoDir, videoID, s.channelID, s.params.Stopper, s.params.IPPool)
+	video.profile = genericProfile
+	return video
+}
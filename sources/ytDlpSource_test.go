@@ -0,0 +1,42 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/lbryio/ytsync/v5/downloader"
+	"github.com/lbryio/ytsync/v5/ip_manager"
+	"github.com/lbryio/ytsync/v5/shared"
+
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYtDlpSourceListItems(t *testing.T) {
+	replayYtDlp(t, "vimeo_playlist")
+	stopGroup := stop.New()
+	defer stopGroup.Stop()
+	pool, err := ip_manager.GetIPPool(stopGroup)
+	if !assert.NoError(t, err) {
+		return
+	}
+	source := NewSource(&shared.YoutubeChannel{ChannelId: "UCabc", SourceURL: "https://vimeo.com/channels/staffpicks"}, SourceParams{VideoDir: t.TempDir(), Stopper: stopGroup, IPPool: pool})
+	items, err := source.ListItems(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []Item{
+		{ID: "vimeo:1017406920", URL: "https://vimeo.com/1017406920"},
+		{ID: "vimeo:1015891765", URL: "https://vimeo.com/1015891765"},
+	}, items)
+	// yt-dlp is only asked for the items that are needed
+	runs := downloader.GetYtDlpRunner().(*downloader.FixtureRunner).Runs
+	if assert.Len(t, runs, 1) {
+		assert.Subset(t, runs[0], []string{"--playlist-end", "2"})
+	}
+}
+
+func TestForeignVideoID(t *testing.T) {
+	assert.Equal(t, "vimeo:1017406920", foreignVideoID("Vimeo", "1017406920"))
+	assert.Equal(t, "peertube:9c9de5e8-0a1e-484a-b099-e80766180a6d", foreignVideoID("PeerTube", "9c9de5e8-0a1e-484a-b099-e80766180a6d"))
+	// IDs keep their case, only the extractor is lowered
+	assert.Equal(t, "generic:AbC", foreignVideoID("Generic", "AbC"))
+}
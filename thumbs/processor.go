@@ -0,0 +1,94 @@
+package thumbs
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxThumbnailSize is the largest width or height of the mirrored thumbnails, larger images are scaled down
+const maxThumbnailSize = 1920
+
+// maxErrorOutput is how much of the end of the ffmpeg output is kept in errors
+const maxErrorOutput = 1000
+
+var (
+	ErrPlaceholderThumbnail = errors.Base("youtube thumbnail is empty - too soon?")
+	ErrNotAnImage           = errors.Base("the thumbnail is not an image")
+)
+
+// detectImageFormat returns the MIME type of an image from its content, as the extension and the content type
+// returned by the server can't be trusted (youtube serves WebP as .jpg)
+func detectImageFormat(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return "", errors.Prefix(contentType, ErrNotAnImage)
+	}
+	return contentType, nil
+}
+
+// needsConversion tells whether an image has to be converted to a JPEG of at most maxThumbnailSize pixels
+func needsConversion(data []byte, contentType string) bool {
+	if contentType != "image/jpeg" {
+		return true
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		// ffmpeg is more forgiving with broken files
+		return true
+	}
+	return cfg.Width > maxThumbnailSize || cfg.Height > maxThumbnailSize
+}
+
+// scaleFilter scales images down to fit maxThumbnailSize while keeping their aspect ratio, and never scales them up
+var scaleFilter = fmt.Sprintf(`scale=w=min(iw\,%d):h=min(ih\,%d):force_original_aspect_ratio=decrease`, maxThumbnailSize, maxThumbnailSize)
+
+// processThumbnail writes the image data to dst as a JPEG of at most maxThumbnailSize pixels. JPEGs that are small
+// enough are written as is
+func processThumbnail(data []byte, dst string) error {
+	contentType, err := detectImageFormat(data)
+	if err != nil {
+		return err
+	}
+	if !needsConversion(data, contentType) {
+		return errors.Err(os.WriteFile(dst, data, 0666))
+	}
+	log.Debugf("converting %s thumbnail %s to jpeg", contentType, dst)
+	src := dst + ".orig"
+	err = os.WriteFile(src, data, 0666)
+	if err != nil {
+		return errors.Err(err)
+	}
+	defer os.Remove(src)
+	return runFFmpeg("-i", src, "-vf", scaleFilter, "-frames:v", "1", "-update", "1", "-q:v", "2", "-f", "image2", dst)
+}
+
+// extractFrame writes a representative frame of a video to dst as a JPEG, for videos without a usable thumbnail. The
+// frame is taken at a tenth of the video to skip intros and fades from black
+func extractFrame(videoPath string, durationSeconds int, dst string) error {
+	position := durationSeconds / 10
+	return runFFmpeg("-ss", fmt.Sprintf("%d", position), "-i", videoPath, "-vf", scaleFilter, "-frames:v", "1", "-update", "1", "-q:v", "2", "-f", "image2", dst)
+}
+
+func runFFmpeg(args ...string) error {
+	args = append([]string{"-hide_banner", "-nostdin", "-loglevel", "error", "-y"}, args...)
+	out, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		if len(out) > maxErrorOutput {
+			out = out[len(out)-maxErrorOutput:]
+		}
+		return errors.Prefix("ffmpeg failed: "+string(out), err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package thumbs
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestDetectImageFormat(t *testing.T) {
+	contentType, err := detectImageFormat(encodeJPEG(t, 16, 9))
+	assert.NoError(t, err)
+	assert.Equal(t, "image/jpeg", contentType)
+
+	var b bytes.Buffer
+	assert.NoError(t, png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 16, 9))))
+	contentType, err = detectImageFormat(b.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+
+	webp := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), make([]byte, 16)...)
+	contentType, err = detectImageFormat(webp)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/webp", contentType)
+
+	_, err = detectImageFormat([]byte("<html><body>not found</body></html>"))
+	assert.True(t, errors.Is(err, ErrNotAnImage))
+}
+
+func TestNeedsConversion(t *testing.T) {
+	assert.False(t, needsConversion(encodeJPEG(t, 1280, 720), "image/jpeg"))
+	assert.True(t, needsConversion(encodeJPEG(t, 2560, 1440), "image/jpeg"))
+	assert.True(t, needsConversion([]byte("broken"), "image/jpeg"))
+	assert.True(t, needsConversion(nil, "image/webp"))
+}
+
+func TestProcessThumbnailKeepsSmallJPEG(t *testing.T) {
+	data := encodeJPEG(t, 1280, 720)
+	dst := filepath.Join(t.TempDir(), "thumb")
+	assert.NoError(t, processThumbnail(data, dst))
+	written, err := os.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, data, written)
+}
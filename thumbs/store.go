@@ -0,0 +1,374 @@
+package thumbs
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/configs"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// ThumbnailStore keeps the mirrored thumbnails and tells the URL they are served from
+type ThumbnailStore interface {
+	// Put stores the JPEG at localPath under name. sourceMD5 is the hash of the thumbnail it was made from, empty when
+	// it's a video frame
+	Put(name string, localPath string, sourceMD5 string) error
+	// IsCurrent tells whether the thumbnail stored under name was made from a thumbnail whose hash is sourceMD5
+	IsCurrent(name string, sourceMD5 string) (bool, error)
+	// URL returns the public URL of the thumbnail stored under name
+	URL(name string) string
+}
+
+const (
+	DefaultPublicURL    = "https://thumbnails.lbry.com/"
+	defaultBucket       = "thumbnails.lbry.com"
+	defaultCacheControl = "public, max-age=2592000"
+	// sourceMD5Metadata is the S3 metadata holding the hash of the thumbnail a stored thumbnail was made from
+	sourceMD5Metadata = "source-md5"
+)
+
+// LegacyV1Host is where the thumbnails of the claims published with the first version of the metadata are
+const LegacyV1Host = "berk.ninja/thumbnails/"
+
+// historicalHosts are where ytsync mirrored thumbnails in the past, the claims using them were published by ytsync
+var historicalHosts = []string{
+	LegacyV1Host,
+	DefaultPublicURL,
+}
+
+var (
+	storeMux    sync.RWMutex
+	store       ThumbnailStore
+	legacyHosts []string
+)
+
+// SetStore replaces the store the thumbnails are mirrored to. legacyHosts are the base URLs of the stores used before
+func SetStore(s ThumbnailStore, legacy []string) {
+	storeMux.Lock()
+	defer storeMux.Unlock()
+	store = s
+	legacyHosts = legacy
+}
+
+// GetStore returns the store the thumbnails are mirrored to, the S3 bucket of thumbnails_s3_config unless SetStore was
+// called
+func GetStore() ThumbnailStore {
+	storeMux.RLock()
+	defer storeMux.RUnlock()
+	if store == nil {
+		return NewS3Store(configs.Configuration.ThumbnailsS3Config, "", "")
+	}
+	return store
+}
+
+// KnownHosts returns the base URLs of the thumbnails mirrored by ytsync: the current store, the legacy hosts of the
+// configuration and the hosts used by previous versions
+func KnownHosts() []string {
+	hosts := []string{GetStore().URL("")}
+	storeMux.RLock()
+	defer storeMux.RUnlock()
+	for _, known := range [][]string{legacyHosts, historicalHosts} {
+		for _, h := range known {
+			if h != "" && !contains(hosts, h) {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+	return hosts
+}
+
+// IsMirroredThumbnail tells whether a thumbnail URL points to one of the KnownHosts
+func IsMirroredThumbnail(thumbnailURL string) bool {
+	for _, h := range KnownHosts() {
+		if strings.Contains(thumbnailURL, h) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// StoreFromConfig builds the store described by the thumbnail_store section of config.json
+func StoreFromConfig(c configs.ThumbnailStoreConfig, s3Config configs.S3Configs) (ThumbnailStore, error) {
+	switch c.Type {
+	case "", "s3":
+		return NewS3Store(s3Config, c.PublicURL, c.CacheControl), nil
+	case "local":
+		if c.Dir == "" || c.PublicURL == "" {
+			return nil, errors.Err("the local thumbnail store needs a dir and a public_url")
+		}
+		return NewLocalStore(c.Dir, c.PublicURL), nil
+	case "http":
+		if c.UploadURL == "" {
+			return nil, errors.Err("the http thumbnail store needs an upload_url")
+		}
+		return NewHTTPStore(c.UploadURL, c.PublicURL, c.CacheControl, c.Headers), nil
+	}
+	return nil, errors.Err("unknown thumbnail store type %s", c.Type)
+}
+
+// withSlash makes sure that the names appended to a base URL are in its path
+func withSlash(baseURL string) string {
+	if strings.HasSuffix(baseURL, "/") {
+		return baseURL
+	}
+	return baseURL + "/"
+}
+
+// S3Store uploads the thumbnails to an S3 compatible bucket
+type S3Store struct {
+	config       configs.S3Configs
+	bucket       string
+	publicURL    string
+	cacheControl string
+}
+
+// NewS3Store returns a store uploading to the bucket of s3Config. The public URL and the cache control have defaults
+// when they're empty
+func NewS3Store(s3Config configs.S3Configs, publicURL string, cacheControl string) *S3Store {
+	s := &S3Store{
+		config:       s3Config,
+		bucket:       s3Config.Bucket,
+		publicURL:    publicURL,
+		cacheControl: cacheControl,
+	}
+	if s.bucket == "" {
+		s.bucket = defaultBucket
+	}
+	if s.publicURL == "" {
+		s.publicURL = DefaultPublicURL
+	}
+	if s.cacheControl == "" {
+		s.cacheControl = defaultCacheControl
+	}
+	s.publicURL = withSlash(s.publicURL)
+	return s
+}
+
+func (s *S3Store) Put(name string, localPath string, sourceMD5 string) error {
+	thumb, err := os.Open(localPath)
+	if err != nil {
+		return errors.Err(err)
+	}
+	defer thumb.Close()
+
+	s3Session, err := session.NewSession(s.config.GetS3AWSConfig())
+	if err != nil {
+		return errors.Err(err)
+	}
+	input := &s3manager.UploadInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(name),
+		Body:         thumb,
+		ACL:          aws.String("public-read"),
+		ContentType:  aws.String("image/jpeg"),
+		CacheControl: aws.String(s.cacheControl),
+	}
+	if sourceMD5 != "" {
+		input.Metadata = map[string]*string{sourceMD5Metadata: aws.String(sourceMD5)}
+	}
+	_, err = s3manager.NewUploader(s3Session).Upload(input)
+	return errors.Err(err)
+}
+
+// IsCurrent compares sourceMD5 with the metadata of the object. The objects uploaded before the metadata existed are
+// stale: they can be the source itself, a WebP for instance, rather than a JPEG made from it
+func (s *S3Store) IsCurrent(name string, sourceMD5 string) (bool, error) {
+	s3Session, err := session.NewSession(s.config.GetS3AWSConfig())
+	if err != nil {
+		return false, errors.Err(err)
+	}
+	head, err := s3.New(s3Session).HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+			return false, nil
+		}
+		return false, errors.Err(err)
+	}
+	for k, v := range head.Metadata {
+		if strings.EqualFold(k, sourceMD5Metadata) {
+			return aws.StringValue(v) == sourceMD5, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *S3Store) URL(name string) string {
+	return s.publicURL + name
+}
+
+// LocalStore writes the thumbnails to a directory served by a web server
+type LocalStore struct {
+	dir       string
+	publicURL string
+}
+
+func NewLocalStore(dir string, publicURL string) *LocalStore {
+	return &LocalStore{dir: dir, publicURL: withSlash(publicURL)}
+}
+
+// sourceMD5Path is where the hash of the thumbnail a stored thumbnail was made from is kept, out of the served files
+func (s *LocalStore) sourceMD5Path(name string) string {
+	return filepath.Join(s.dir, ".source-md5", name)
+}
+
+func (s *LocalStore) Put(name string, localPath string, sourceMD5 string) error {
+	err := os.MkdirAll(filepath.Dir(s.sourceMD5Path(name)), 0755)
+	if err != nil {
+		return errors.Err(err)
+	}
+	// a stale hash would make the next run skip the upload of a thumbnail that changed
+	err = os.Remove(s.sourceMD5Path(name))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Err(err)
+	}
+	err = s.put(name, localPath)
+	if err != nil || sourceMD5 == "" {
+		return err
+	}
+	return errors.Err(os.WriteFile(s.sourceMD5Path(name), []byte(sourceMD5), 0644))
+}
+
+func (s *LocalStore) IsCurrent(name string, sourceMD5 string) (bool, error) {
+	stored, err := os.ReadFile(s.sourceMD5Path(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Err(err)
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, name)); err != nil {
+		return false, nil
+	}
+	return string(stored) == sourceMD5, nil
+}
+
+func (s *LocalStore) put(name string, localPath string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return errors.Err(err)
+	}
+	defer src.Close()
+	// the thumbnail is swapped in at once so that it's never served half written
+	dst := filepath.Join(s.dir, name)
+	tmp, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return errors.Err(err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Err(err)
+	}
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return errors.Err(err)
+	}
+	return errors.Err(os.Rename(tmp.Name(), dst))
+}
+
+func (s *LocalStore) URL(name string) string {
+	return s.publicURL + name
+}
+
+// HTTPStore uploads the thumbnails with PUT requests, e.g. to a WebDAV server or an object storage gateway
+type HTTPStore struct {
+	uploadURL    string
+	publicURL    string
+	cacheControl string
+	headers      map[string]string
+	client       *http.Client
+}
+
+// NewHTTPStore returns a store PUTting the thumbnails under uploadURL. They are served from uploadURL unless
+// publicURL is set
+func NewHTTPStore(uploadURL string, publicURL string, cacheControl string, headers map[string]string) *HTTPStore {
+	if publicURL == "" {
+		publicURL = uploadURL
+	}
+	if cacheControl == "" {
+		cacheControl = defaultCacheControl
+	}
+	return &HTTPStore{
+		uploadURL:    withSlash(uploadURL),
+		publicURL:    withSlash(publicURL),
+		cacheControl: cacheControl,
+		headers:      headers,
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *HTTPStore) Put(name string, localPath string, sourceMD5 string) error {
+	thumb, err := os.Open(localPath)
+	if err != nil {
+		return errors.Err(err)
+	}
+	defer thumb.Close()
+	fi, err := thumb.Stat()
+	if err != nil {
+		return errors.Err(err)
+	}
+	req, err := http.NewRequest(http.MethodPut, s.uploadURL+name, thumb)
+	if err != nil {
+		return errors.Err(err)
+	}
+	req.ContentLength = fi.Size()
+	req.Header.Set("Content-Type", "image/jpeg")
+	req.Header.Set("Cache-Control", s.cacheControl)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Err(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 500))
+		return errors.Err("thumbnail upload of %s failed with status %d: %s", name, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// IsCurrent compares sourceMD5 with the ETag of the thumbnail, which servers usually set to the MD5 of the content.
+// Only thumbnails that weren't converted are found current
+func (s *HTTPStore) IsCurrent(name string, sourceMD5 string) (bool, error) {
+	resp, err := s.client.Head(s.URL(name))
+	if err != nil {
+		return false, errors.Err(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, nil
+	}
+	return strings.Trim(resp.Header.Get("ETag"), `"`) == sourceMD5, nil
+}
+
+func (s *HTTPStore) URL(name string) string {
+	return s.publicURL + name
+}
@@ -0,0 +1,91 @@
+package thumbs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lbryio/ytsync/v5/configs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeThumbnail(t *testing.T, content string) string {
+	p := filepath.Join(t.TempDir(), "thumb.jpg")
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestStoreFromConfig(t *testing.T) {
+	s, err := StoreFromConfig(configs.ThumbnailStoreConfig{}, configs.S3Configs{})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://thumbnails.lbry.com/abc", s.URL("abc"))
+	assert.Equal(t, defaultBucket, s.(*S3Store).bucket)
+
+	s, err = StoreFromConfig(configs.ThumbnailStoreConfig{PublicURL: "https://cdn.example.com/thumbs"}, configs.S3Configs{Bucket: "thumbs"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/thumbs/abc", s.URL("abc"))
+	assert.Equal(t, "thumbs", s.(*S3Store).bucket)
+
+	_, err = StoreFromConfig(configs.ThumbnailStoreConfig{Type: "local"}, configs.S3Configs{})
+	assert.Error(t, err)
+	_, err = StoreFromConfig(configs.ThumbnailStoreConfig{Type: "http"}, configs.S3Configs{})
+	assert.Error(t, err)
+	_, err = StoreFromConfig(configs.ThumbnailStoreConfig{Type: "ftp"}, configs.S3Configs{})
+	assert.Error(t, err)
+}
+
+func TestLocalStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "thumbnails")
+	s := NewLocalStore(dir, "https://example.com/thumbnails")
+	assert.NoError(t, s.Put("abc", writeThumbnail(t, "jpeg bytes"), ""))
+	content, err := os.ReadFile(filepath.Join(dir, "abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg bytes", string(content))
+	assert.Equal(t, "https://example.com/thumbnails/abc", s.URL("abc"))
+}
+
+func TestHTTPStore(t *testing.T) {
+	uploaded := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "image/jpeg", r.Header.Get("Content-Type"))
+		assert.Equal(t, defaultCacheControl, r.Header.Get("Cache-Control"))
+		body, _ := io.ReadAll(r.Body)
+		uploaded[r.URL.Path] = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	s := NewHTTPStore(server.URL+"/thumbs", "", "", map[string]string{"Authorization": "Bearer secret"})
+	assert.NoError(t, s.Put("abc", writeThumbnail(t, "jpeg bytes"), ""))
+	assert.Equal(t, "jpeg bytes", uploaded["/thumbs/abc"])
+	assert.Equal(t, server.URL+"/thumbs/abc", s.URL("abc"))
+
+	s = NewHTTPStore(server.URL+"/thumbs", "https://cdn.example.com", "", nil)
+	assert.Error(t, s.Put("abc", writeThumbnail(t, "jpeg bytes"), ""))
+	assert.Equal(t, "https://cdn.example.com/abc", s.URL("abc"))
+}
+
+func TestKnownHosts(t *testing.T) {
+	defer SetStore(nil, nil)
+	SetStore(NewLocalStore(t.TempDir(), "https://example.com/thumbnails/"), []string{"https://old.example.com/"})
+	assert.Equal(t, []string{
+		"https://example.com/thumbnails/",
+		"https://old.example.com/",
+		LegacyV1Host,
+		DefaultPublicURL,
+	}, KnownHosts())
+	assert.True(t, IsMirroredThumbnail("https://example.com/thumbnails/abc"))
+	assert.True(t, IsMirroredThumbnail("https://old.example.com/abc"))
+	assert.True(t, IsMirroredThumbnail("https://thumbnails.lbry.com/abc"))
+	assert.False(t, IsMirroredThumbnail("https://i.ytimg.com/vi/abc/hqdefault.jpg"))
+}
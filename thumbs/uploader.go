@@ -9,56 +9,75 @@ import (
 	"os"
 	"strings"
 
-	"github.com/lbryio/ytsync/v5/configs"
 	"github.com/lbryio/ytsync/v5/downloader/ytdl"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	log "github.com/sirupsen/logrus"
 )
 
 type thumbnailUploader struct {
 	name        string
 	originalUrl string
+	localPath   string
+	// videoPath is the video a frame is extracted from when there's no thumbnail to mirror
+	videoPath     string
+	videoDuration int
+	// sourceMD5 is the hash of the downloaded thumbnail, before it's converted. It's empty for video frames
+	sourceMD5   string
 	mirroredUrl string
-	s3Config    aws.Config
+	// unchanged is set when the store already has the thumbnail made from the same source
+	unchanged bool
 }
 
 const thumbnailPath = "/tmp/ytsync_thumbnails/"
-const ThumbnailEndpoint = "https://thumbnails.lbry.com/"
 const EmptyThumbnailHash = "e2ddfee11ae7edcae257da47f3a78a70"
 
 func (u *thumbnailUploader) downloadThumbnail() error {
 	_ = os.Mkdir(thumbnailPath, 0777)
-	img, err := os.Create("/tmp/ytsync_thumbnails/" + u.name)
-	if err != nil {
-		return errors.Err(err)
-	}
-	defer img.Close()
-	if strings.HasPrefix(u.originalUrl, "//") {
-		u.originalUrl = "https:" + u.originalUrl
+	if u.videoPath != "" {
+		return extractFrame(u.videoPath, u.videoDuration, thumbnailPath+u.name)
 	}
-	resp, err := http.Get(u.originalUrl)
-	if err != nil {
-		return errors.Err(err)
+	var body io.Reader
+	if u.localPath != "" {
+		f, err := os.Open(u.localPath)
+		if err != nil {
+			return errors.Err(err)
+		}
+		defer f.Close()
+		body = f
+	} else {
+		if strings.HasPrefix(u.originalUrl, "//") {
+			u.originalUrl = "https:" + u.originalUrl
+		}
+		resp, err := http.Get(u.originalUrl)
+		if err != nil {
+			return errors.Err(err)
+		}
+		defer resp.Body.Close()
+		body = resp.Body
 	}
-	defer resp.Body.Close()
 
-	reader, thumbnailHash, err := getMD5(resp.Body)
+	reader, thumbnailHash, err := getMD5(body)
 	if err != nil {
 		return errors.Err(err)
 	}
 	if thumbnailHash == EmptyThumbnailHash {
-		return errors.Err("youtube thumbnail is empty - too soon?")
+		return errors.Err(ErrPlaceholderThumbnail)
 	}
-	_, err = io.Copy(img, reader)
+	u.sourceMD5 = thumbnailHash
+	current, err := GetStore().IsCurrent(u.name, thumbnailHash)
+	if err != nil {
+		log.Warnf("could not check whether thumbnail %s changed, uploading it again: %s", u.name, err.Error())
+	} else if current {
+		u.unchanged = true
+		return nil
+	}
+	data, err := io.ReadAll(reader)
 	if err != nil {
 		return errors.Err(err)
 	}
-	return nil
+	return processThumbnail(data, thumbnailPath+u.name)
 }
 
 func getMD5(body io.Reader) (io.Reader, string, error) {
@@ -75,57 +94,61 @@ func getMD5(body io.Reader) (io.Reader, string, error) {
 }
 
 func (u *thumbnailUploader) uploadThumbnail() error {
-	key := &u.name
-	thumb, err := os.Open("/tmp/ytsync_thumbnails/" + u.name)
-	if err != nil {
-		return errors.Err(err)
-	}
-	defer thumb.Close()
-
-	s3Session, err := session.NewSession(&u.s3Config)
-	if err != nil {
-		return errors.Err(err)
+	store := GetStore()
+	u.mirroredUrl = store.URL(u.name)
+	if u.unchanged {
+		log.Debugf("thumbnail %s is unchanged, skipping the upload", u.name)
+		return nil
 	}
-
-	uploader := s3manager.NewUploader(s3Session)
-
-	_, err = uploader.Upload(&s3manager.UploadInput{
-		Bucket:       aws.String("thumbnails.lbry.com"),
-		Key:          key,
-		Body:         thumb,
-		ACL:          aws.String("public-read"),
-		ContentType:  aws.String("image/jpeg"),
-		CacheControl: aws.String("public, max-age=2592000"),
-	})
-
-	u.mirroredUrl = ThumbnailEndpoint + u.name
-	return errors.Err(err)
+	return store.Put(u.name, thumbnailPath+u.name, u.sourceMD5)
 }
 
 func (u *thumbnailUploader) deleteTmpFile() {
-	err := os.Remove("/tmp/ytsync_thumbnails/" + u.name)
-	if err != nil {
+	err := os.Remove(thumbnailPath + u.name)
+	if err != nil && !os.IsNotExist(err) {
 		log.Infof("failed to delete local thumbnail file: %s", err.Error())
 	}
 }
-func MirrorThumbnail(url string, name string) (string, error) {
-	tu := thumbnailUploader{
+
+// MirrorThumbnail uploads the thumbnail at url under name and returns its public URL. The upload is skipped when the
+// store already has the same thumbnail, changed is false then
+func MirrorThumbnail(url string, name string) (mirroredURL string, changed bool, err error) {
+	return mirror(thumbnailUploader{
 		originalUrl: url,
 		name:        name,
-		s3Config:    *configs.Configuration.ThumbnailsS3Config.GetS3AWSConfig(),
-	}
+	})
+}
+
+// MirrorVideoFrame uploads a frame of the video stored at videoPath, for videos without a usable thumbnail
+func MirrorVideoFrame(videoPath string, durationSeconds int, name string) (mirroredURL string, changed bool, err error) {
+	return mirror(thumbnailUploader{
+		videoPath:     videoPath,
+		videoDuration: durationSeconds,
+		name:          name,
+	})
+}
+
+// MirrorLocalThumbnail uploads the thumbnail stored at filePath, for videos that don't come from a website
+func MirrorLocalThumbnail(filePath string, name string) (mirroredURL string, changed bool, err error) {
+	return mirror(thumbnailUploader{
+		localPath: filePath,
+		name:      name,
+	})
+}
+
+func mirror(tu thumbnailUploader) (string, bool, error) {
+	defer tu.deleteTmpFile()
 	err := tu.downloadThumbnail()
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
-	defer tu.deleteTmpFile()
 
 	err = tu.uploadThumbnail()
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
-	return tu.mirroredUrl, nil
+	return tu.mirroredUrl, !tu.unchanged, nil
 }
 
 // GetBestThumbnail returns the thumbnail URL for a given video ID
@@ -0,0 +1,147 @@
+package thumbs
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lbryio/ytsync/v5/configs"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type s3Object struct {
+	body     []byte
+	metadata http.Header
+}
+
+// fakeS3 is an S3 compatible stand-in keeping the objects of path style PUT requests in memory
+type fakeS3 struct {
+	mux     sync.Mutex
+	objects map[string]s3Object
+	puts    int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string]s3Object)}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		metadata := make(http.Header)
+		for k, v := range r.Header {
+			if strings.HasPrefix(strings.ToLower(k), "x-amz-meta-") {
+				metadata[k] = v
+			}
+		}
+		f.objects[r.URL.Path] = s3Object{body: body, metadata: metadata}
+		f.puts++
+		w.Header().Set("ETag", `"`+md5Hex(body)+`"`)
+	case http.MethodHead:
+		o, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for k, v := range o.metadata {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", `"`+md5Hex(o.body)+`"`)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func md5Hex(b []byte) string {
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestMirrorSkipsUnchangedThumbnails(t *testing.T) {
+	s3 := newFakeS3()
+	server := httptest.NewServer(s3)
+	defer server.Close()
+	defer SetStore(nil, nil)
+	SetStore(NewS3Store(configs.S3Configs{ID: "id", Secret: "secret", Region: "us-east-1", Bucket: "thumbs", Endpoint: server.URL}, "https://cdn.example.com/", ""), nil)
+
+	thumbnail := filepath.Join(t.TempDir(), "thumb.jpg")
+	original := encodeJPEG(t, 64, 36)
+	assert.NoError(t, os.WriteFile(thumbnail, original, 0644))
+
+	url, changed, err := MirrorLocalThumbnail(thumbnail, "ytsync-test-video")
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "https://cdn.example.com/ytsync-test-video", url)
+	assert.Equal(t, 1, s3.puts)
+	assert.Equal(t, original, s3.objects["/thumbs/ytsync-test-video"].body)
+
+	url, changed, err = MirrorLocalThumbnail(thumbnail, "ytsync-test-video")
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "https://cdn.example.com/ytsync-test-video", url)
+	assert.Equal(t, 1, s3.puts)
+
+	assert.NoError(t, os.WriteFile(thumbnail, encodeJPEG(t, 32, 18), 0644))
+	_, changed, err = MirrorLocalThumbnail(thumbnail, "ytsync-test-video")
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 2, s3.puts)
+}
+
+func TestS3StoreIsCurrent(t *testing.T) {
+	s3 := newFakeS3()
+	server := httptest.NewServer(s3)
+	defer server.Close()
+	store := NewS3Store(configs.S3Configs{ID: "id", Secret: "secret", Region: "us-east-1", Bucket: "thumbs", Endpoint: server.URL}, "", "")
+
+	current, err := store.IsCurrent("missing", "abc")
+	assert.NoError(t, err)
+	assert.False(t, current)
+
+	// the thumbnails uploaded before the metadata existed are uploaded again, even when they are the source itself
+	s3.objects["/thumbs/legacy"] = s3Object{body: []byte("webp bytes")}
+	current, err = store.IsCurrent("legacy", md5Hex([]byte("webp bytes")))
+	assert.NoError(t, err)
+	assert.False(t, current)
+
+	// converted thumbnails are recognised by the hash of their source
+	s3.objects["/thumbs/converted"] = s3Object{body: []byte("jpeg bytes"), metadata: http.Header{"X-Amz-Meta-Source-Md5": {"webp-md5"}}}
+	current, err = store.IsCurrent("converted", "webp-md5")
+	assert.NoError(t, err)
+	assert.True(t, current)
+	current, err = store.IsCurrent("converted", md5Hex([]byte("jpeg bytes")))
+	assert.NoError(t, err)
+	assert.False(t, current)
+}
+
+func TestLocalStoreIsCurrent(t *testing.T) {
+	store := NewLocalStore(t.TempDir(), "https://example.com/")
+	current, err := store.IsCurrent("abc", "md5")
+	assert.NoError(t, err)
+	assert.False(t, current)
+
+	assert.NoError(t, store.Put("abc", writeThumbnail(t, "jpeg bytes"), "md5"))
+	current, err = store.IsCurrent("abc", "md5")
+	assert.NoError(t, err)
+	assert.True(t, current)
+	current, err = store.IsCurrent("abc", "other")
+	assert.NoError(t, err)
+	assert.False(t, current)
+
+	// frames have no source, they are never current
+	assert.NoError(t, store.Put("abc", writeThumbnail(t, "frame bytes"), ""))
+	current, err = store.IsCurrent("abc", "md5")
+	assert.NoError(t, err)
+	assert.False(t, current)
+}
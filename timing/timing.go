@@ -17,13 +17,35 @@ type Timing struct {
 	invocations  int32
 }
 
-var timings *sync.Map
+// Timings is a set of component timings. Every sync pipeline keeps its own so that concurrent channels neither mix
+// nor clear each other's timings
+type Timings struct {
+	components sync.Map
+}
+
+// defaultTimings holds the timings of the components that don't run as part of a pipeline
+var defaultTimings = &Timings{}
+
+func NewTimings() *Timings {
+	return &Timings{}
+}
 
+// TimedComponent returns the timing of a component from the default set
 func TimedComponent(component string) *Timing {
-	if timings == nil {
-		timings = &sync.Map{}
+	return defaultTimings.TimedComponent(component)
+}
+
+// Report logs the timings of the default set
+func Report() {
+	defaultTimings.Report()
+}
+
+// TimedComponent returns the timing of a component, a nil set falls back to the default one
+func (ts *Timings) TimedComponent(component string) *Timing {
+	if ts == nil {
+		ts = defaultTimings
 	}
-	stored, _ := timings.LoadOrStore(component, &Timing{
+	stored, _ := ts.components.LoadOrStore(component, &Timing{
 		component:    component,
 		milliseconds: 0,
 		min:          int64(99999999),
@@ -32,23 +54,16 @@ func TimedComponent(component string) *Timing {
 	return t
 }
 
-func ClearTimings() {
-	if timings == nil {
-		return
+func (ts *Timings) Report() {
+	if ts == nil {
+		ts = defaultTimings
 	}
-	timings.Range(func(key interface{}, value interface{}) bool {
-		timings.Delete(key)
-		return true
-	})
-}
-
-func Report() {
 	var totalTime time.Duration
-	timings.Range(func(key interface{}, value interface{}) bool {
+	ts.components.Range(func(key interface{}, value interface{}) bool {
 		totalTime += value.(*Timing).Get()
 		return true
 	})
-	timings.Range(func(key interface{}, value interface{}) bool {
+	ts.components.Range(func(key interface{}, value interface{}) bool {
 		component := key
 		componentRuntime := value.(*Timing).Get().String()
 		percentTime := float64(value.(*Timing).Get()) / float64(totalTime) * 100
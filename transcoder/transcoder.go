@@ -0,0 +1,272 @@
+package transcoder
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/configs"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+const (
+	defaultCRF          = 23
+	defaultPreset       = "veryfast"
+	defaultAudioBitrate = 160_000
+	// maxErrorOutput is how much of the end of the ffmpeg output is kept in errors
+	maxErrorOutput = 2000
+	// a transcode is given timeoutFactor times the duration of the video, and at least minTimeout
+	timeoutFactor = 4
+	minTimeout    = 15 * time.Minute
+)
+
+// ErrInterrupted is returned by a transcode stopped through its stop channel
+var ErrInterrupted = errors.Base("transcode interrupted")
+
+// Profile is a set of ffmpeg settings producing H.264/AAC mp4 videos
+type Profile struct {
+	Name      string
+	MaxHeight int
+	// VideoBitrate caps the video bitrate in bits per second, 0 only relies on the CRF
+	VideoBitrate int64
+	AudioBitrate int64
+	CRF          int
+	Preset       string
+	Threads      int
+	Always       bool
+}
+
+// Profiles are the transcode profiles channels can use, keyed by name
+type Profiles map[string]*Profile
+
+// ProfilesFromConfig validates the transcode profiles of config.json and fills in the defaults
+func ProfilesFromConfig(cfg map[string]configs.TranscodeProfileConfig) (Profiles, error) {
+	profiles := make(Profiles, len(cfg))
+	for name, c := range cfg {
+		p := &Profile{
+			Name:         name,
+			MaxHeight:    c.MaxHeight,
+			AudioBitrate: defaultAudioBitrate,
+			CRF:          c.CRF,
+			Preset:       c.Preset,
+			Threads:      c.Threads,
+			Always:       c.Always,
+		}
+		var err error
+		if c.VideoBitrate != "" {
+			p.VideoBitrate, err = parseBitrate(c.VideoBitrate)
+			if err != nil {
+				return nil, errors.Prefix("transcode profile "+name, err)
+			}
+		}
+		if c.AudioBitrate != "" {
+			p.AudioBitrate, err = parseBitrate(c.AudioBitrate)
+			if err != nil {
+				return nil, errors.Prefix("transcode profile "+name, err)
+			}
+		}
+		if p.CRF == 0 {
+			p.CRF = defaultCRF
+		}
+		if p.Preset == "" {
+			p.Preset = defaultPreset
+		}
+		if p.MaxHeight < 0 || p.Threads < 0 || p.CRF < 0 || p.CRF > 51 {
+			return nil, errors.Err("transcode profile %s: max_height, threads and crf (0-51) can't be negative", name)
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// Get returns the profile called name, nil when name is empty as the channel isn't transcoded
+func (p Profiles) Get(name string) (*Profile, error) {
+	if name == "" {
+		return nil, nil
+	}
+	profile, ok := p[name]
+	if !ok {
+		return nil, errors.Err("unknown transcode profile %s", name)
+	}
+	return profile, nil
+}
+
+// parseBitrate parses bitrates like ffmpeg does: bits per second with an optional k or M suffix
+func parseBitrate(bitrate string) (int64, error) {
+	multiplier := int64(1)
+	value := strings.TrimSpace(bitrate)
+	switch {
+	case strings.HasSuffix(value, "k"), strings.HasSuffix(value, "K"):
+		multiplier = 1000
+		value = value[:len(value)-1]
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1000 * 1000
+		value = value[:len(value)-1]
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed <= 0 {
+		return 0, errors.Err("invalid bitrate %q", bitrate)
+	}
+	return int64(parsed * float64(multiplier)), nil
+}
+
+// NeedsTranscode tells whether a probed video has to go through the profile: it isn't H.264/AAC or it exceeds the
+// resolution or bitrate caps
+func (p *Profile) NeedsTranscode(data *ffprobe.ProbeData) bool {
+	if p.Always || data == nil || data.Format == nil {
+		return true
+	}
+	if data.Format.FormatName != "" && !strings.Contains(data.Format.FormatName, "mp4") {
+		return true
+	}
+	video := data.FirstVideoStream()
+	if video == nil || video.CodecName != "h264" {
+		return true
+	}
+	if audio := data.FirstAudioStream(); audio != nil && audio.CodecName != "aac" {
+		return true
+	}
+	if p.MaxHeight > 0 && video.Height > p.MaxHeight {
+		return true
+	}
+	if p.VideoBitrate > 0 {
+		// a few percents over the cap aren't worth a transcode
+		bitrate, err := strconv.ParseInt(video.BitRate, 10, 64)
+		if err == nil && bitrate > p.VideoBitrate*11/10 {
+			return true
+		}
+	}
+	return false
+}
+
+// Args returns the ffmpeg arguments transcoding input into the mp4 output
+func (p *Profile) Args(input string, output string) []string {
+	args := []string{
+		"-hide_banner",
+		"-nostdin",
+		"-y",
+		"-i", input,
+		"-map", "0:v:0",
+		"-map", "0:a:0?",
+		"-c:v", "libx264",
+		"-preset", p.Preset,
+		"-crf", strconv.Itoa(p.CRF),
+		"-pix_fmt", "yuv420p",
+	}
+	if p.VideoBitrate > 0 {
+		args = append(args,
+			"-maxrate", strconv.FormatInt(p.VideoBitrate, 10),
+			"-bufsize", strconv.FormatInt(p.VideoBitrate*2, 10),
+		)
+	}
+	if p.MaxHeight > 0 {
+		// never upscale, and keep the width even as libx264 requires
+		args = append(args, "-vf", fmt.Sprintf(`scale=-2:min(ih\,%d)`, p.MaxHeight))
+	}
+	args = append(args,
+		"-c:a", "aac",
+		"-b:a", strconv.FormatInt(p.AudioBitrate, 10),
+		"-movflags", "+faststart",
+	)
+	if p.Threads > 0 {
+		args = append(args, "-threads", strconv.Itoa(p.Threads))
+	}
+	return append(args, output)
+}
+
+// Timeout returns how long the transcode of a video lasting duration may run, duration is 0 when it isn't known
+func Timeout(duration time.Duration) time.Duration {
+	if duration*timeoutFactor > minTimeout {
+		return duration * timeoutFactor
+	}
+	return minTimeout
+}
+
+// parseProgress reads the key=value lines written by "ffmpeg -progress" and calls onProgress with how much of the
+// video was transcoded so far
+func parseProgress(r io.Reader, onProgress func(done time.Duration)) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		// out_time_ms is in microseconds too, it's only read from the versions of ffmpeg without out_time_us
+		if !ok || (key != "out_time_us" && key != "out_time_ms") {
+			continue
+		}
+		us, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || us < 0 {
+			continue
+		}
+		if onProgress != nil {
+			onProgress(time.Duration(us) * time.Microsecond)
+		}
+	}
+	// keep draining the pipe so that ffmpeg never blocks on it
+	_, _ = io.Copy(io.Discard, r)
+}
+
+// Transcode runs ffmpeg to transcode input, a video lasting duration, into output. The output is written next to it
+// first so that an interrupted transcode never leaves a partial file behind. ffmpeg is killed once Timeout(duration)
+// is over. onProgress, when set, is called with how much of the video was transcoded so far
+func (p *Profile) Transcode(input string, output string, duration time.Duration, stopChan stop.Chan, onProgress func(done time.Duration)) error {
+	tmpOutput := strings.TrimSuffix(output, ".mp4") + ".transcoding.mp4"
+	args := append([]string{"-progress", "pipe:1", "-nostats"}, p.Args(input, tmpOutput)...)
+	log.Infof("running ffmpeg cmd: ffmpeg %s", strings.Join(args, " "))
+	cmd := exec.Command("ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Err(err)
+	}
+	err = cmd.Start()
+	if err != nil {
+		return errors.Err(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		parseProgress(stdout, onProgress)
+		done <- cmd.Wait()
+	}()
+	timeout := Timeout(duration)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopChan:
+		err = cmd.Process.Kill()
+		<-done
+		_ = os.Remove(tmpOutput)
+		if err != nil {
+			return errors.Prefix("failed to kill ffmpeg after stopper cancellation", err)
+		}
+		return errors.Err(ErrInterrupted)
+	case <-timer.C:
+		err = cmd.Process.Kill()
+		<-done
+		_ = os.Remove(tmpOutput)
+		if err != nil {
+			return errors.Prefix("failed to kill ffmpeg after it timed out", err)
+		}
+		return errors.Err("the transcode timed out after %s", timeout.String())
+	case err = <-done:
+	}
+	if err != nil {
+		_ = os.Remove(tmpOutput)
+		out := stderr.String()
+		if len(out) > maxErrorOutput {
+			out = out[len(out)-maxErrorOutput:]
+		}
+		return errors.Prefix("ffmpeg failed: "+out, err)
+	}
+	return errors.Err(os.Rename(tmpOutput, output))
+}
@@ -0,0 +1,95 @@
+package transcoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/configs"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+func TestProfilesFromConfig(t *testing.T) {
+	profiles, err := ProfilesFromConfig(map[string]configs.TranscodeProfileConfig{
+		"720p": {MaxHeight: 720, VideoBitrate: "2.5M", AudioBitrate: "128k", Threads: 2},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	p, err := profiles.Get("720p")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2_500_000), p.VideoBitrate)
+	assert.Equal(t, int64(128_000), p.AudioBitrate)
+	assert.Equal(t, defaultCRF, p.CRF)
+	assert.Equal(t, defaultPreset, p.Preset)
+
+	p, err = profiles.Get("")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+	_, err = profiles.Get("4k")
+	assert.Error(t, err)
+
+	_, err = ProfilesFromConfig(map[string]configs.TranscodeProfileConfig{"bad": {VideoBitrate: "fast"}})
+	assert.Error(t, err)
+	_, err = ProfilesFromConfig(map[string]configs.TranscodeProfileConfig{"bad": {Threads: -1}})
+	assert.Error(t, err)
+}
+
+func probeData(container, videoCodec, audioCodec string, height int, videoBitrate string) *ffprobe.ProbeData {
+	return &ffprobe.ProbeData{
+		Format: &ffprobe.Format{FormatName: container},
+		Streams: []*ffprobe.Stream{
+			{CodecType: "video", CodecName: videoCodec, Height: height, BitRate: videoBitrate},
+			{CodecType: "audio", CodecName: audioCodec},
+		},
+	}
+}
+
+func TestNeedsTranscode(t *testing.T) {
+	p := &Profile{MaxHeight: 720, VideoBitrate: 2_000_000}
+	assert.False(t, p.NeedsTranscode(probeData("mov,mp4,m4a,3gp,3g2,mj2", "h264", "aac", 720, "1900000")))
+	assert.True(t, p.NeedsTranscode(probeData("mov,mp4,m4a,3gp,3g2,mj2", "vp9", "aac", 720, "1900000")))
+	assert.True(t, p.NeedsTranscode(probeData("mov,mp4,m4a,3gp,3g2,mj2", "h264", "opus", 720, "1900000")))
+	assert.True(t, p.NeedsTranscode(probeData("mov,mp4,m4a,3gp,3g2,mj2", "h264", "aac", 1080, "1900000")))
+	assert.True(t, p.NeedsTranscode(probeData("mov,mp4,m4a,3gp,3g2,mj2", "h264", "aac", 720, "4000000")))
+	assert.True(t, p.NeedsTranscode(probeData("matroska,webm", "h264", "aac", 720, "1900000")))
+	assert.True(t, p.NeedsTranscode(nil))
+
+	p.Always = true
+	assert.True(t, p.NeedsTranscode(probeData("mov,mp4,m4a,3gp,3g2,mj2", "h264", "aac", 720, "1900000")))
+}
+
+func TestArgs(t *testing.T) {
+	p := &Profile{MaxHeight: 720, VideoBitrate: 2_000_000, AudioBitrate: 128_000, CRF: 23, Preset: "veryfast", Threads: 2}
+	args := p.Args("in.webm", "out.mp4")
+	assert.Equal(t, "in.webm", args[4])
+	assert.Equal(t, "out.mp4", args[len(args)-1])
+	assert.Contains(t, args, `scale=-2:min(ih\,720)`)
+	assert.Contains(t, args, "libx264")
+	assert.Contains(t, args, "+faststart")
+	assert.Contains(t, args, "4000000")
+	assert.Contains(t, args, "-threads")
+
+	args = (&Profile{AudioBitrate: 128_000, CRF: 23, Preset: "veryfast"}).Args("in.webm", "out.mp4")
+	assert.NotContains(t, args, "-vf")
+	assert.NotContains(t, args, "-maxrate")
+	assert.NotContains(t, args, "-threads")
+}
+
+func TestTimeout(t *testing.T) {
+	assert.Equal(t, minTimeout, Timeout(0))
+	assert.Equal(t, minTimeout, Timeout(time.Minute))
+	assert.Equal(t, 8*time.Hour, Timeout(2*time.Hour))
+}
+
+func TestParseProgress(t *testing.T) {
+	output := "frame=120\nfps=30.0\nout_time_us=4000000\nout_time=00:00:04.000000\nprogress=continue\n" +
+		"out_time_us=N/A\nout_time_ms=6500000\nprogress=end\n"
+	var reported []time.Duration
+	parseProgress(strings.NewReader(output), func(done time.Duration) {
+		reported = append(reported, done)
+	})
+	assert.Equal(t, []time.Duration{4 * time.Second, 6500 * time.Millisecond}, reported)
+}
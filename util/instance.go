@@ -0,0 +1,331 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/ytsync/v5/timing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/mitchellh/go-ps"
+	log "github.com/sirupsen/logrus"
+)
+
+// Instance groups the lbrynet daemon, wallet and scratch directories owned by a single sync pipeline.
+// Instance 0 is the historical single daemon setup and is driven by the unsuffixed environment variables
+// (LBRYNET_ADDRESS, LBRYNET_DIR, LBRYUM_DIR, BLOBS_DIRECTORY), the lbrynet.service unit and the lbrynet container.
+// Instance N reads the same variables suffixed with _N and controls the lbrynet@N.service unit or the lbrynet-N container.
+type Instance struct {
+	ID int
+	// Timings collects the daemon timings of the pipeline using the instance, nil uses the default set
+	Timings *timing.Timings
+}
+
+// DefaultInstance is the daemon used when channels are synced one at a time
+var DefaultInstance = Instance{}
+
+func (i Instance) getenv(key string) string {
+	if i.ID == 0 {
+		return os.Getenv(key)
+	}
+	return os.Getenv(fmt.Sprintf("%s_%d", key, i.ID))
+}
+
+// Validate makes sure that a numbered instance has its own daemon and directories configured so that it never
+// falls back to the paths of another pipeline
+func (i Instance) Validate() error {
+	if i.ID == 0 {
+		return nil
+	}
+	for _, key := range []string{"LBRYNET_ADDRESS", "LBRYNET_DIR", "LBRYUM_DIR"} {
+		if i.getenv(key) == "" {
+			return errors.Err("%s_%d must be set to run instance %d", key, i.ID, i.ID)
+		}
+	}
+	return nil
+}
+
+func (i Instance) String() string {
+	return fmt.Sprintf("instance %d", i.ID)
+}
+
+func (i Instance) LBRYNetAddress() string {
+	return i.getenv("LBRYNET_ADDRESS")
+}
+
+func (i Instance) GetBlobsDir() string {
+	blobsDir := i.getenv("BLOBS_DIRECTORY")
+	if blobsDir == "" {
+		if i.ID != 0 {
+			return i.GetLBRYNetDir() + "blobfiles/"
+		}
+		usr, err := user.Current()
+		if err != nil {
+			log.Error(err.Error())
+			return ""
+		}
+		blobsDir = usr.HomeDir + "/.lbrynet/blobfiles/"
+	}
+
+	return blobsDir
+}
+
+func (i Instance) GetLBRYNetDir() string {
+	lbrynetDir := i.getenv("LBRYNET_DIR")
+	if lbrynetDir == "" {
+		usr, err := user.Current()
+		if err != nil {
+			log.Errorln(err.Error())
+			return ""
+		}
+		return usr.HomeDir + "/.lbrynet/"
+	}
+	return lbrynetDir
+}
+
+func (i Instance) GetLbryumDir() string {
+	lbryumDir := i.getenv("LBRYUM_DIR")
+	if lbryumDir == "" {
+		usr, err := user.Current()
+		if err != nil {
+			log.Errorln(err.Error())
+			return ""
+		}
+		return usr.HomeDir + "/.lbryum/"
+	}
+	return lbryumDir + "/"
+}
+
+func (i Instance) GetDefaultWalletPath() string {
+	defaultWalletDir := os.Getenv("HOME") + "/.lbryum/wallets/default_wallet"
+	if IsRegTest() {
+		defaultWalletDir = os.Getenv("HOME") + "/.lbryum_regtest/wallets/default_wallet"
+	}
+
+	walletPath := i.getenv("LBRYUM_DIR")
+	if walletPath != "" {
+		defaultWalletDir = walletPath + "/wallets/default_wallet"
+	}
+	return defaultWalletDir
+}
+
+func (i Instance) GetBlockchainDBPath() string {
+	lbryumDir := i.getenv("LBRYUM_DIR")
+	if lbryumDir == "" {
+		if IsRegTest() {
+			lbryumDir = os.Getenv("HOME") + "/.lbryum_regtest"
+		} else {
+			lbryumDir = os.Getenv("HOME") + "/.lbryum"
+		}
+	}
+	return lbryumDir + "/" + GetBlockchainDirectoryName() + "/blockchain.db"
+}
+
+func (i Instance) containerName() string {
+	if i.ID == 0 {
+		return "lbrynet"
+	}
+	// docker matches names as a regular expression: anchor it so lbrynet-1 doesn't match lbrynet-10
+	return fmt.Sprintf("^/lbrynet-%d$", i.ID)
+}
+
+func (i Instance) unitName() string {
+	if i.ID == 0 {
+		return "lbrynet.service"
+	}
+	return fmt.Sprintf("lbrynet@%d.service", i.ID)
+}
+
+func (i Instance) GetLBRYNetContainer(all bool) (*types.Container, error) {
+	return getDockerContainer(i.containerName(), all)
+}
+
+func (i Instance) IsLbrynetRunning() (bool, error) {
+	if IsUsingDocker() {
+		c, err := i.GetLBRYNetContainer(ONLINE)
+		if err != nil {
+			return false, err
+		}
+		return c != nil, nil
+	}
+	if i.ID != 0 {
+		// more than one lbrynet process runs on the host, ask systemd about the one belonging to this instance
+		out, err := exec.Command("/bin/systemctl", "show", "--property=MainPID", "--value", i.unitName()).Output()
+		if err != nil {
+			return true, errors.Err(err)
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+		if err != nil {
+			return true, errors.Err(err)
+		}
+		return pid != 0, nil
+	}
+
+	processes, err := ps.Processes()
+	if err != nil {
+		return true, errors.Err(err)
+	}
+	var daemonProcessId = -1
+	for _, p := range processes {
+		if p.Executable() == "lbrynet" {
+			daemonProcessId = p.Pid()
+			break
+		}
+	}
+
+	running := daemonProcessId != -1
+	return running, nil
+}
+
+func (i Instance) CleanupLbrynet() error {
+	//make sure lbrynet is off
+	log.Printf("cleaning up lbrynet directories for %s...", i)
+	running, err := i.IsLbrynetRunning()
+	if err != nil {
+		return err
+	}
+	if running {
+		return errors.Prefix("cannot cleanup lbrynet as the daemon is running", err)
+	}
+	lbrynetDir := i.GetLBRYNetDir()
+	files, err := filepath.Glob(lbrynetDir + "lbrynet.sqlite*")
+	if err != nil {
+		return errors.Err(err)
+	}
+	for _, f := range files {
+		err = os.Remove(f)
+		if err != nil {
+			return errors.Err(err)
+		}
+	}
+	blobsDir := i.GetBlobsDir()
+	err = os.RemoveAll(blobsDir)
+	if err != nil {
+		return errors.Err(err)
+	}
+	err = os.Mkdir(blobsDir, 0777)
+	if err != nil {
+		return errors.Err(err)
+	}
+
+	lbryumDir := i.GetLbryumDir() + GetBlockchainDirectoryName()
+
+	files, err = filepath.Glob(lbryumDir + "/blockchain.db*")
+	if err != nil {
+		return errors.Err(err)
+	}
+	for _, f := range files {
+		err = os.Remove(f)
+		if err != nil {
+			return errors.Err(err)
+		}
+	}
+	return nil
+}
+
+func (i Instance) StartDaemon() error {
+	start := time.Now()
+	defer func(start time.Time) {
+		i.Timings.TimedComponent("startDaemon").Add(time.Since(start))
+	}(start)
+	if IsUsingDocker() {
+		return i.startDaemonViaDocker()
+	}
+	return i.systemctl("start")
+}
+
+func (i Instance) RestartDaemon() error {
+	start := time.Now()
+	defer func(start time.Time) {
+		i.Timings.TimedComponent("startDaemon").Add(time.Since(start))
+	}(start)
+	if IsUsingDocker() {
+		return i.restartDaemonViaDocker()
+	}
+	return i.systemctl("restart")
+}
+
+func (i Instance) StopDaemon() error {
+	log.Printf("Stopping daemon (%s)", i)
+	start := time.Now()
+	defer func(start time.Time) {
+		i.Timings.TimedComponent("stopDaemon").Add(time.Since(start))
+	}(start)
+	if IsUsingDocker() {
+		return i.stopDaemonViaDocker()
+	}
+	return i.systemctl("stop")
+}
+
+func (i Instance) startDaemonViaDocker() error {
+	c, err := i.GetLBRYNetContainer(true)
+	if err != nil {
+		return err
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		panic(err)
+	}
+
+	err = cli.ContainerStart(context.Background(), c.ID, container.StartOptions{})
+	if err != nil {
+		return errors.Err(err)
+	}
+	return nil
+}
+
+func (i Instance) restartDaemonViaDocker() error {
+	c, err := i.GetLBRYNetContainer(true)
+	if err != nil {
+		return err
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		panic(err)
+	}
+
+	err = cli.ContainerRestart(context.Background(), c.ID, container.StopOptions{})
+	if err != nil {
+		return errors.Err(err)
+	}
+	return nil
+}
+
+func (i Instance) stopDaemonViaDocker() error {
+	c, err := i.GetLBRYNetContainer(ONLINE)
+	if err != nil {
+		return err
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		panic(err)
+	}
+
+	err = cli.ContainerStop(context.Background(), c.ID, container.StopOptions{})
+	if err != nil {
+		return errors.Err(err)
+	}
+
+	return nil
+}
+
+func (i Instance) systemctl(action string) error {
+	err := exec.Command("/usr/bin/sudo", "/bin/systemctl", action, i.unitName()).Run()
+	if err != nil {
+		return errors.Err(err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceEnvironment(t *testing.T) {
+	t.Setenv("LBRYNET_ADDRESS", "http://localhost:5279")
+	t.Setenv("LBRYNET_ADDRESS_2", "http://localhost:5282")
+	t.Setenv("LBRYNET_DIR_2", "/tmp/lbrynet-2/")
+	t.Setenv("LBRYUM_DIR_2", "/tmp/lbryum-2")
+	t.Setenv("BLOBS_DIRECTORY_2", "")
+
+	assert.Equal(t, "http://localhost:5279", DefaultInstance.LBRYNetAddress())
+
+	second := Instance{ID: 2}
+	assert.NoError(t, second.Validate())
+	assert.Equal(t, "http://localhost:5282", second.LBRYNetAddress())
+	assert.Equal(t, "/tmp/lbrynet-2/blobfiles/", second.GetBlobsDir())
+	assert.Equal(t, "/tmp/lbryum-2/wallets/default_wallet", second.GetDefaultWalletPath())
+	assert.Equal(t, "/tmp/lbryum-2/"+GetBlockchainDirectoryName()+"/blockchain.db", second.GetBlockchainDBPath())
+	assert.Equal(t, "lbrynet@2.service", second.unitName())
+	assert.Equal(t, "^/lbrynet-2$", second.containerName())
+
+	assert.Error(t, Instance{ID: 3}.Validate())
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"os"
 	"os/exec"
-	"os/user"
 	"path/filepath"
 	"strconv"
 	"time"
@@ -12,28 +11,16 @@ import (
 	"github.com/lbryio/lbry.go/v2/extras/errors"
 	"github.com/lbryio/lbry.go/v2/lbrycrd"
 	"github.com/lbryio/ytsync/v5/configs"
-	"github.com/lbryio/ytsync/v5/timing"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
-	"github.com/mitchellh/go-ps"
 	log "github.com/sirupsen/logrus"
 )
 
 func GetBlobsDir() string {
-	blobsDir := os.Getenv("BLOBS_DIRECTORY")
-	if blobsDir == "" {
-		usr, err := user.Current()
-		if err != nil {
-			log.Error(err.Error())
-			return ""
-		}
-		blobsDir = usr.HomeDir + "/.lbrynet/blobfiles/"
-	}
-
-	return blobsDir
+	return DefaultInstance.GetBlobsDir()
 }
 
 func IsBlobReflectionOff() bool {
@@ -41,36 +28,18 @@ func IsBlobReflectionOff() bool {
 }
 
 func GetLBRYNetDir() string {
-	lbrynetDir := os.Getenv("LBRYNET_DIR")
-	if lbrynetDir == "" {
-		usr, err := user.Current()
-		if err != nil {
-			log.Errorln(err.Error())
-			return ""
-		}
-		return usr.HomeDir + "/.lbrynet/"
-	}
-	return lbrynetDir
+	return DefaultInstance.GetLBRYNetDir()
 }
 
 func GetLbryumDir() string {
-	lbryumDir := os.Getenv("LBRYUM_DIR")
-	if lbryumDir == "" {
-		usr, err := user.Current()
-		if err != nil {
-			log.Errorln(err.Error())
-			return ""
-		}
-		return usr.HomeDir + "/.lbryum/"
-	}
-	return lbryumDir + "/"
+	return DefaultInstance.GetLbryumDir()
 }
 
 const ALL = true
 const ONLINE = false
 
 func GetLBRYNetContainer(all bool) (*types.Container, error) {
-	return getDockerContainer("lbrynet", all)
+	return DefaultInstance.GetLBRYNetContainer(all)
 }
 
 func getDockerContainer(name string, all bool) (*types.Container, error) {
@@ -142,28 +111,7 @@ func ShouldCleanOnStartup() bool {
 }
 
 func IsLbrynetRunning() (bool, error) {
-	if IsUsingDocker() {
-		c, err := GetLBRYNetContainer(ONLINE)
-		if err != nil {
-			return false, err
-		}
-		return c != nil, nil
-	}
-
-	processes, err := ps.Processes()
-	if err != nil {
-		return true, errors.Err(err)
-	}
-	var daemonProcessId = -1
-	for _, p := range processes {
-		if p.Executable() == "lbrynet" {
-			daemonProcessId = p.Pid()
-			break
-		}
-	}
-
-	running := daemonProcessId != -1
-	return running, nil
+	return DefaultInstance.IsLbrynetRunning()
 }
 
 func StopVpn() error {
@@ -241,75 +189,7 @@ func CleanForStartup() error {
 }
 
 func CleanupLbrynet() error {
-	//make sure lbrynet is off
-	log.Println("cleaning up lbrynet directories...")
-	running, err := IsLbrynetRunning()
-	if err != nil {
-		return err
-	}
-	if running {
-		return errors.Prefix("cannot cleanup lbrynet as the daemon is running", err)
-	}
-	lbrynetDir := GetLBRYNetDir()
-	files, err := filepath.Glob(lbrynetDir + "lbrynet.sqlite*")
-	if err != nil {
-		return errors.Err(err)
-	}
-	for _, f := range files {
-		err = os.Remove(f)
-		if err != nil {
-			return errors.Err(err)
-		}
-	}
-	blobsDir := GetBlobsDir()
-	err = os.RemoveAll(blobsDir)
-	if err != nil {
-		return errors.Err(err)
-	}
-	err = os.Mkdir(blobsDir, 0777)
-	if err != nil {
-		return errors.Err(err)
-	}
-
-	lbryumDir := GetLbryumDir()
-	ledger := "lbc_mainnet"
-	if IsRegTest() {
-		ledger = "lbc_regtest"
-	}
-	lbryumDir = lbryumDir + ledger
-
-	files, err = filepath.Glob(lbryumDir + "/blockchain.db*")
-	if err != nil {
-		return errors.Err(err)
-	}
-	for _, f := range files {
-		err = os.Remove(f)
-		if err != nil {
-			return errors.Err(err)
-		}
-	}
-	return nil
-}
-
-var metadataDirInitialized = false
-
-func GetVideoMetadataDir() string {
-	dir := "./videos_metadata"
-	if !metadataDirInitialized {
-		metadataDirInitialized = true
-		_ = os.MkdirAll(dir, 0755)
-	}
-	return dir
-}
-
-func CleanupMetadata() error {
-	dir := GetVideoMetadataDir()
-	err := os.RemoveAll(dir)
-	if err != nil {
-		return errors.Err(err)
-	}
-	metadataDirInitialized = false
-	return nil
+	return DefaultInstance.CleanupLbrynet()
 }
 
 func SleepUntilQuotaReset() {
@@ -326,145 +206,25 @@ func SleepUntilQuotaReset() {
 }
 
 func StartDaemon() error {
-	start := time.Now()
-	defer func(start time.Time) {
-		timing.TimedComponent("startDaemon").Add(time.Since(start))
-	}(start)
-	if IsUsingDocker() {
-		return startDaemonViaDocker()
-	}
-	return startDaemonViaSystemd()
+	return DefaultInstance.StartDaemon()
 }
 
 func RestartDaemon() error {
-	start := time.Now()
-	defer func(start time.Time) {
-		timing.TimedComponent("startDaemon").Add(time.Since(start))
-	}(start)
-	if IsUsingDocker() {
-		return restartDaemonViaDocker()
-	}
-	return restartDaemonViaSystemd()
+	return DefaultInstance.RestartDaemon()
 }
 
 func StopDaemon() error {
-	log.Println("Stopping daemon")
-	start := time.Now()
-	defer func(start time.Time) {
-		timing.TimedComponent("stopDaemon").Add(time.Since(start))
-	}(start)
-	if IsUsingDocker() {
-		return stopDaemonViaDocker()
-	}
-	return stopDaemonViaSystemd()
-}
-
-func startDaemonViaDocker() error {
-	c, err := GetLBRYNetContainer(true)
-	if err != nil {
-		return err
-	}
-
-	cli, err := client.NewClientWithOpts(client.FromEnv)
-	if err != nil {
-		panic(err)
-	}
-
-	err = cli.ContainerStart(context.Background(), c.ID, container.StartOptions{})
-	if err != nil {
-		return errors.Err(err)
-	}
-	return nil
-}
-
-func restartDaemonViaDocker() error {
-	c, err := GetLBRYNetContainer(true)
-	if err != nil {
-		return err
-	}
-
-	cli, err := client.NewClientWithOpts(client.FromEnv)
-	if err != nil {
-		panic(err)
-	}
-
-	err = cli.ContainerRestart(context.Background(), c.ID, container.StopOptions{})
-	if err != nil {
-		return errors.Err(err)
-	}
-	return nil
-}
-
-func stopDaemonViaDocker() error {
-	c, err := GetLBRYNetContainer(ONLINE)
-	if err != nil {
-		return err
-	}
-
-	cli, err := client.NewClientWithOpts(client.FromEnv)
-	if err != nil {
-		panic(err)
-	}
-
-	err = cli.ContainerStop(context.Background(), c.ID, container.StopOptions{})
-	if err != nil {
-		return errors.Err(err)
-	}
-
-	return nil
-}
-
-func startDaemonViaSystemd() error {
-	err := exec.Command("/usr/bin/sudo", "/bin/systemctl", "start", "lbrynet.service").Run()
-	if err != nil {
-		return errors.Err(err)
-	}
-	return nil
-}
-
-func restartDaemonViaSystemd() error {
-	err := exec.Command("/usr/bin/sudo", "/bin/systemctl", "restart", "lbrynet.service").Run()
-	if err != nil {
-		return errors.Err(err)
-	}
-	return nil
-}
-
-func stopDaemonViaSystemd() error {
-	err := exec.Command("/usr/bin/sudo", "/bin/systemctl", "stop", "lbrynet.service").Run()
-	if err != nil {
-		return errors.Err(err)
-	}
-	return nil
+	return DefaultInstance.StopDaemon()
 }
 
 func GetDefaultWalletPath() string {
-	defaultWalletDir := os.Getenv("HOME") + "/.lbryum/wallets/default_wallet"
-	if IsRegTest() {
-		defaultWalletDir = os.Getenv("HOME") + "/.lbryum_regtest/wallets/default_wallet"
-	}
-
-	walletPath := os.Getenv("LBRYUM_DIR")
-	if walletPath != "" {
-		defaultWalletDir = walletPath + "/wallets/default_wallet"
-	}
-	return defaultWalletDir
+	return DefaultInstance.GetDefaultWalletPath()
 }
+
 func GetBlockchainDBPath() string {
-	lbryumDir := os.Getenv("LBRYUM_DIR")
-	if lbryumDir == "" {
-		if IsRegTest() {
-			lbryumDir = os.Getenv("HOME") + "/.lbryum_regtest"
-		} else {
-			lbryumDir = os.Getenv("HOME") + "/.lbryum"
-		}
-	}
-	defaultDB := lbryumDir + "/lbc_mainnet/blockchain.db"
-	if IsRegTest() {
-		defaultDB = lbryumDir + "/lbc_regtest/blockchain.db"
-	}
-	return defaultDB
+	return DefaultInstance.GetBlockchainDBPath()
 }
+
 func GetBlockchainDirectoryName() string {
 	ledger := "lbc_mainnet"
 	if IsRegTest() {
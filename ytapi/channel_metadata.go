@@ -0,0 +1,210 @@
+package ytapi
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lbryio/ytsync/v5/downloader"
+	"github.com/lbryio/ytsync/v5/ip_manager"
+	logUtils "github.com/lbryio/ytsync/v5/util"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+	"github.com/lbryio/lbry.go/v2/extras/stop"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ScraperProviderName = "scraper"
+	YtDlpProviderName   = "yt-dlp"
+)
+
+// DefaultChannelMetadataProviders is the order used when channel_metadata_providers isn't configured
+var DefaultChannelMetadataProviders = []string{ScraperProviderName, YtDlpProviderName}
+
+// ErrNoChannelAvatar is returned for channels without an avatar, which a LBRY channel can't be created without
+var ErrNoChannelAvatar = errors.Base("no thumbnails found for channel")
+
+// ChannelMetadata is what is needed from a youtube channel to create or update its LBRY channel
+type ChannelMetadata struct {
+	Title       string
+	Description string
+	AvatarURL   string
+	// BannerURL is empty for channels without a banner
+	BannerURL string
+	// Country is a two letters country code, it is often unknown
+	Country string
+	// Handle is the @handle of the channel, when it has one
+	Handle string
+}
+
+// ChannelMetadataProvider gets the metadata of youtube channels
+type ChannelMetadataProvider interface {
+	Name() string
+	ChannelMetadata(channelID string) (*ChannelMetadata, error)
+}
+
+// NewChannelMetadataProvider returns a provider trying the named providers in order, DefaultChannelMetadataProviders
+// when names is empty
+func NewChannelMetadataProvider(names []string, stopChan stop.Chan, pool *ip_manager.IPPool) (ChannelMetadataProvider, error) {
+	if len(names) == 0 {
+		names = DefaultChannelMetadataProviders
+	}
+	providers := make(chainProvider, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case ScraperProviderName:
+			providers = append(providers, &ScraperProvider{Pool: pool})
+		case YtDlpProviderName:
+			providers = append(providers, &YtDlpProvider{Pool: pool, StopChan: stopChan})
+		default:
+			return nil, errors.Err("unknown channel metadata provider %s", name)
+		}
+	}
+	return providers, nil
+}
+
+// chainProvider returns the metadata of the first provider that succeeds
+type chainProvider []ChannelMetadataProvider
+
+func (c chainProvider) Name() string {
+	names := make([]string, 0, len(c))
+	for _, p := range c {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (c chainProvider) ChannelMetadata(channelID string) (*ChannelMetadata, error) {
+	var lastErr error
+	for _, p := range c {
+		metadata, err := p.ChannelMetadata(channelID)
+		if err == nil && metadata.AvatarURL == "" {
+			err = errors.Err(ErrNoChannelAvatar)
+		}
+		if err == nil {
+			return metadata, nil
+		}
+		log.Warnf("the %s provider couldn't get the metadata of channel %s: %s", p.Name(), channelID, err.Error())
+		lastErr = errors.Prefix(p.Name(), err)
+	}
+	if lastErr == nil {
+		return nil, errors.Err("no channel metadata provider configured")
+	}
+	return nil, lastErr
+}
+
+// ScraperProvider reads the ytInitialData of the about page of the channel
+type ScraperProvider struct {
+	Pool *ip_manager.IPPool
+}
+
+func (p *ScraperProvider) Name() string {
+	return ScraperProviderName
+}
+
+func (p *ScraperProvider) ChannelMetadata(channelID string) (*ChannelMetadata, error) {
+	channelInfo, err := ChannelInfo(channelID, 0, p.Pool)
+	if err != nil {
+		if !strings.Contains(err.Error(), "invalid character 'e' looking for beginning of value") {
+			return nil, err
+		}
+		logUtils.SendInfoToSlack("failed to get channel data for %s. Waiting 1 minute to retry", channelID)
+		time.Sleep(1 * time.Minute)
+		channelInfo, err = ChannelInfo(channelID, 1, p.Pool)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return channelInfo.ChannelMetadata(), nil
+}
+
+// ChannelMetadata normalizes the decoded about page
+func (r *YoutubeStatsResponse) ChannelMetadata() *ChannelMetadata {
+	metadata := &ChannelMetadata{
+		Title:       r.Microformat.MicroformatDataRenderer.Title,
+		Description: r.Metadata.ChannelMetadataRenderer.Description,
+		Country:     r.Topbar.DesktopTopbarRenderer.CountryCode,
+	}
+	if avatars := r.Header.C4TabbedHeaderRenderer.Avatar.Thumbnails; len(avatars) > 0 {
+		metadata.AvatarURL = avatars[len(avatars)-1].URL
+	}
+	if banners := r.Header.C4TabbedHeaderRenderer.Banner.Thumbnails; len(banners) > 0 {
+		metadata.BannerURL = banners[len(banners)-1].URL
+	}
+	if i := strings.LastIndex(r.Metadata.ChannelMetadataRenderer.VanityChannelURL, "/@"); i >= 0 {
+		metadata.Handle = r.Metadata.ChannelMetadataRenderer.VanityChannelURL[i+1:]
+	}
+	return metadata
+}
+
+// YtDlpProvider asks yt-dlp for the metadata of the channel, it keeps working when youtube changes its page layout
+type YtDlpProvider struct {
+	Pool     *ip_manager.IPPool
+	StopChan stop.Chan
+}
+
+func (p *YtDlpProvider) Name() string {
+	return YtDlpProviderName
+}
+
+func (p *YtDlpProvider) ChannelMetadata(channelID string) (*ChannelMetadata, error) {
+	data, err := downloader.GetChannelJSON(channelID, p.StopChan, p.Pool)
+	if err != nil {
+		return nil, err
+	}
+	return parseYtDlpChannel(data)
+}
+
+type ytDlpChannel struct {
+	Channel     string `json:"channel"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	UploaderID  string `json:"uploader_id"`
+	Thumbnails  []struct {
+		ID     string `json:"id"`
+		URL    string `json:"url"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+	} `json:"thumbnails"`
+}
+
+func parseYtDlpChannel(data []byte) (*ChannelMetadata, error) {
+	var c ytDlpChannel
+	err := json.Unmarshal(data, &c)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	metadata := &ChannelMetadata{
+		Title:       c.Channel,
+		Description: c.Description,
+	}
+	if metadata.Title == "" {
+		metadata.Title = c.Title
+	}
+	if strings.HasPrefix(c.UploaderID, "@") {
+		metadata.Handle = c.UploaderID
+	}
+	// the uncropped images are the originals, the others are resized: square ones are avatars and wide ones banners
+	bannerWidth := 0
+	avatarWidth := 0
+	for _, t := range c.Thumbnails {
+		switch {
+		case t.ID == "avatar_uncropped":
+			metadata.AvatarURL = t.URL
+			avatarWidth = math.MaxInt
+		case t.ID == "banner_uncropped":
+			metadata.BannerURL = t.URL
+			bannerWidth = math.MaxInt
+		case t.Width > 0 && t.Width == t.Height && t.Width > avatarWidth:
+			metadata.AvatarURL = t.URL
+			avatarWidth = t.Width
+		case t.Width > t.Height && t.Width > bannerWidth:
+			metadata.BannerURL = t.URL
+			bannerWidth = t.Width
+		}
+	}
+	return metadata, nil
+}
@@ -0,0 +1,96 @@
+package ytapi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lbryio/lbry.go/v2/extras/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChannelPage(t *testing.T) {
+	for _, fixture := range []string{"channel_about_c4.html", "channel_about_page_header.html"} {
+		page, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if !assert.NoError(t, err) {
+			return
+		}
+		info, err := parseChannelPage(string(page))
+		if !assert.NoError(t, err, fixture) {
+			continue
+		}
+		metadata := info.ChannelMetadata()
+		assert.Equal(t, "Example Channel", metadata.Title, fixture)
+		assert.Equal(t, "Videos about examples.", metadata.Description, fixture)
+		assert.Contains(t, metadata.AvatarURL, "https://yt3.ggpht.com/avatar=s", fixture)
+		assert.Equal(t, "https://yt3.ggpht.com/banner=w2560", metadata.BannerURL, fixture)
+		assert.Equal(t, "CA", metadata.Country, fixture)
+		assert.Equal(t, "@example", metadata.Handle, fixture)
+	}
+	_, err := parseChannelPage("<html><body>nothing here</body></html>")
+	assert.Error(t, err)
+}
+
+func TestParseYtDlpChannel(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "channel_ytdlp.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	metadata, err := parseYtDlpChannel(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &ChannelMetadata{
+		Title:       "Example Channel",
+		Description: "Videos about examples.",
+		AvatarURL:   "https://yt3.ggpht.com/avatar=s0",
+		BannerURL:   "https://yt3.ggpht.com/banner=s0",
+		Handle:      "@example",
+	}, metadata)
+
+	// without the uncropped images the biggest square and wide ones are used
+	metadata, err = parseYtDlpChannel([]byte(`{"channel": "c", "thumbnails": [{"url": "a88", "width": 88, "height": 88}, {"url": "a900", "width": 900, "height": 900}, {"url": "b", "width": 2560, "height": 424}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "a900", metadata.AvatarURL)
+	assert.Equal(t, "b", metadata.BannerURL)
+
+	_, err = parseYtDlpChannel([]byte("not json"))
+	assert.Error(t, err)
+}
+
+type fakeProvider struct {
+	name     string
+	metadata *ChannelMetadata
+	err      error
+	calls    int
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+
+func (f *fakeProvider) ChannelMetadata(channelID string) (*ChannelMetadata, error) {
+	f.calls++
+	return f.metadata, f.err
+}
+
+func TestChainProvider(t *testing.T) {
+	broken := &fakeProvider{name: "broken", err: errors.Err("layout changed")}
+	noAvatar := &fakeProvider{name: "no-avatar", metadata: &ChannelMetadata{Title: "t"}}
+	working := &fakeProvider{name: "working", metadata: &ChannelMetadata{Title: "t", AvatarURL: "a"}}
+	unused := &fakeProvider{name: "unused", metadata: &ChannelMetadata{Title: "other", AvatarURL: "b"}}
+
+	chain := chainProvider{broken, noAvatar, working, unused}
+	metadata, err := chain.ChannelMetadata("UCabc")
+	assert.NoError(t, err)
+	assert.Equal(t, working.metadata, metadata)
+	assert.Equal(t, 0, unused.calls)
+	assert.Equal(t, "broken,no-avatar,working,unused", chain.Name())
+
+	_, err = chainProvider{broken, noAvatar}.ChannelMetadata("UCabc")
+	assert.True(t, errors.Is(err, ErrNoChannelAvatar))
+
+	_, err = NewChannelMetadataProvider([]string{"yt-dlp", "scraper"}, nil, nil)
+	assert.NoError(t, err)
+	_, err = NewChannelMetadataProvider([]string{"youtube-api"}, nil, nil)
+	assert.Error(t, err)
+}
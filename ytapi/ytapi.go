@@ -13,30 +13,21 @@ import (
 	"time"
 
 	"github.com/lbryio/ytsync/v5/downloader"
-	"github.com/lbryio/ytsync/v5/downloader/ytdl"
 	"github.com/lbryio/ytsync/v5/ip_manager"
+	"github.com/lbryio/ytsync/v5/metrics"
 	"github.com/lbryio/ytsync/v5/sdk"
 	"github.com/lbryio/ytsync/v5/shared"
 	"github.com/lbryio/ytsync/v5/sources"
 	logUtils "github.com/lbryio/ytsync/v5/util"
 
 	"github.com/lbryio/lbry.go/v2/extras/errors"
-	"github.com/lbryio/lbry.go/v2/extras/jsonrpc"
 	"github.com/lbryio/lbry.go/v2/extras/stop"
-	"github.com/lbryio/lbry.go/v2/extras/util"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/vbauerster/mpb/v7"
 )
 
-type Video interface {
-	Size() *int64
-	ID() string
-	IDAndNum() string
-	PlaylistPosition() int
-	PublishedAt() time.Time
-	Sync(*jsonrpc.Client, sources.SyncParams, *sdk.SyncedVideo, bool, *sync.RWMutex, *sync.WaitGroup, *mpb.Progress) (*sources.SyncSummary, error)
-}
+// Video is kept as an alias so that callers don't need to know about sources
+type Video = sources.Video
 
 type byPublishedAt []Video
 
@@ -44,83 +35,137 @@ func (a byPublishedAt) Len() int           { return len(a) }
 func (a byPublishedAt) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a byPublishedAt) Less(i, j int) bool { return a[i].PublishedAt().Before(a[j].PublishedAt()) }
 
-type VideoParams struct {
-	VideoDir string
-	Stopper  *stop.Group
-	IPPool   *ip_manager.IPPool
-}
-
 var mostRecentlyFailedChannel string // TODO: fix this hack!
+var mostRecentlyFailedChannelMux sync.Mutex
 
-func GetVideosToSync(channelID string, syncedVideos map[string]sdk.SyncedVideo, quickSync bool, maxVideos int, videoParams VideoParams, lastUploadedVideo string) ([]Video, error) {
+// GetVideosToSync returns the videos of the source that still have to be synced or upgraded. With useFeed the feed of
+// the source is used to find new uploads when possible, it reports whether the full listing ran instead. Deferred
+// videos are left out until they are due, and then queued even if they aren't listed anymore. Age restricted videos
+// are only queued by ageRestrictedRun. The videos that can't be fetched are marked as failed through the outbox
+func GetVideosToSync(source sources.Source, channelID string, syncedVideos map[string]sdk.SyncedVideo, quickSync bool, maxVideos int, stopChan stop.Chan, pool *ip_manager.IPPool, lastUploadedVideo string, useFeed bool, deferred *sdk.DeferredVideos, ageRestrictedRun bool, outbox *sdk.StatusOutbox) ([]Video, bool, error) {
 	newMetadataVersion := int8(2)
 	if quickSync && maxVideos > 50 {
 		maxVideos = 50
 	}
-	allVideos, err := downloader.GetPlaylistVideoIDs(channelID, maxVideos, videoParams.Stopper.Ch(), videoParams.IPPool)
+	allItems, fullScan, err := listItems(source, syncedVideos, lastUploadedVideo, maxVideos, useFeed)
 	if err != nil {
-		return nil, errors.Err(err)
+		return nil, false, errors.Err(err)
 	}
-	videoIDs := make([]string, 0, len(allVideos))
-	for _, video := range allVideos {
-		sv, ok := syncedVideos[video]
-		if ok && (util.SubstringInSlice(sv.FailureReason, shared.NeverRetryFailures) || sv.Published && sv.MetadataVersion == newMetadataVersion) {
+	due := make(map[string]sdk.DeferredVideo)
+	for _, v := range deferred.Due(channelID, time.Now()) {
+		due[v.VideoID] = v
+	}
+	items := make([]sources.Item, 0, len(allItems))
+	for _, item := range allItems {
+		sv, ok := syncedVideos[item.ID]
+		if ok && (shared.ShouldSkipFailedVideo(sv.FailureReason, ageRestrictedRun) || sv.Published && sv.MetadataVersion == newMetadataVersion) {
+			continue
+		}
+		if _, isDue := due[item.ID]; deferred.IsDeferred(channelID, item.ID) && !isDue {
 			continue
 		}
-		videoIDs = append(videoIDs, video)
+		items = append(items, item)
 	}
-	log.Infof("Got info for %d videos from youtube downloader", len(videoIDs))
+	log.Infof("Got info for %d videos from youtube downloader", len(items))
 
 	playlistMap := make(map[string]int64)
-	for i, videoID := range videoIDs {
-		playlistMap[videoID] = int64(i)
+	for i, item := range items {
+		playlistMap[item.ID] = int64(i)
 	}
 	//this will ensure that we at least try to sync the video that was marked as last uploaded video in the database.
 	sv, ok := syncedVideos[lastUploadedVideo]
-	shouldNotQueue := ok && (util.SubstringInSlice(sv.FailureReason, shared.NeverRetryFailures) || sv.Published && sv.MetadataVersion == newMetadataVersion)
+	shouldNotQueue := ok && (shared.ShouldSkipFailedVideo(sv.FailureReason, ageRestrictedRun) || sv.Published && sv.MetadataVersion == newMetadataVersion)
 	if lastUploadedVideo != "" && !shouldNotQueue {
 		_, ok := playlistMap[lastUploadedVideo]
 		if !ok {
 			playlistMap[lastUploadedVideo] = 0
-			videoIDs = append(videoIDs, lastUploadedVideo)
+			items = append(items, sources.Item{ID: lastUploadedVideo})
 		}
 	}
 
-	if len(videoIDs) < 1 {
-		if channelID == mostRecentlyFailedChannel {
-			return nil, errors.Err("playlist items not found")
+	for id, v := range due {
+		if _, ok := playlistMap[id]; !ok {
+			playlistMap[id] = 0
+			items = append(items, sources.Item{ID: id, URL: v.URL})
 		}
+		metrics.RequeuedDeferredVideos.Inc()
+	}
+
+	// an empty feed listing only means that nothing new was uploaded
+	if len(items) < 1 && fullScan {
+		mostRecentlyFailedChannelMux.Lock()
+		failedBefore := channelID == mostRecentlyFailedChannel
 		mostRecentlyFailedChannel = channelID
+		mostRecentlyFailedChannelMux.Unlock()
+		if failedBefore {
+			return nil, false, errors.Err("playlist items not found")
+		}
 	}
 
-	vids, err := getVideos(channelID, videoIDs, videoParams.Stopper.Ch(), videoParams.IPPool)
+	videos, err := getVideos(sdk.GetJobStore(), outbox, source, channelID, items, playlistMap, stopChan, pool, deferred)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	var videos []Video
-	for _, item := range vids {
-		positionInList := playlistMap[item.ID]
-		videoToAdd, err := sources.NewYoutubeVideo(videoParams.VideoDir, item, positionInList, videoParams.Stopper, videoParams.IPPool)
-		if err != nil {
-			return nil, errors.Err(err)
-		}
-		videos = append(videos, videoToAdd)
-	}
+	videos = append(videos, unlistedUpgrades(source, syncedVideos, playlistMap, newMetadataVersion, fullScan)...)
 
+	sort.Sort(byPublishedAt(videos))
+
+	return videos, fullScan, nil
+}
+
+// unlistedUpgrades returns mocked videos for the published videos with outdated metadata that the source doesn't list
+// anymore, so that their claims are upgraded from what is known about them. Only a full scan tells that a video isn't
+// listed: a feed only holds the latest uploads and the videos it leaves out would lose their tags and categories
+func unlistedUpgrades(source sources.Source, syncedVideos map[string]sdk.SyncedVideo, listed map[string]int64, metadataVersion int8, fullScan bool) []Video {
+	if !fullScan {
+		return nil
+	}
+	var videos []Video
 	for k, v := range syncedVideos {
-		if !v.Published || v.MetadataVersion >= newMetadataVersion {
+		if !v.Published || v.MetadataVersion >= metadataVersion {
 			continue
 		}
 
-		if _, ok := playlistMap[k]; !ok {
-			videos = append(videos, sources.NewMockedVideo(videoParams.VideoDir, k, channelID, videoParams.Stopper, videoParams.IPPool))
+		if _, ok := listed[k]; !ok {
+			videos = append(videos, source.MockedVideo(k))
 		}
 	}
+	return videos
+}
 
-	sort.Sort(byPublishedAt(videos))
+// listItems lists the items that could need syncing. With useFeed the feed of the source is read first and the full
+// listing only runs when the feed doesn't cover every upload since the last sync. It reports whether the full listing ran
+func listItems(source sources.Source, syncedVideos map[string]sdk.SyncedVideo, lastUploadedVideo string, maxVideos int, useFeed bool) ([]sources.Item, bool, error) {
+	if feedSource, ok := source.(sources.FeedSource); ok && useFeed {
+		feed, err := feedSource.ListFeedItems()
+		if err != nil {
+			log.Warnf("could not read the feed, falling back to the full listing: %s", err.Error())
+		} else if feedCoversNewUploads(feed, syncedVideos, lastUploadedVideo) {
+			if len(feed) > maxVideos {
+				feed = feed[:maxVideos]
+			}
+			return feed, false, nil
+		} else {
+			log.Infof("the feed doesn't go back to a known video, falling back to the full listing")
+		}
+	}
+	items, err := source.ListItems(maxVideos)
+	return items, true, err
+}
 
-	return videos, nil
+// feedCoversNewUploads reports whether the feed lists every upload since the last sync, which is the case when one
+// of its entries was seen before. A feed of unknown videos only could be hiding older ones
+func feedCoversNewUploads(feed []sources.Item, syncedVideos map[string]sdk.SyncedVideo, lastUploadedVideo string) bool {
+	for _, item := range feed {
+		if _, ok := syncedVideos[item.ID]; ok {
+			return true
+		}
+		if lastUploadedVideo != "" && item.ID == lastUploadedVideo {
+			return true
+		}
+	}
+	return false
 }
 
 func ChannelInfo(channelID string, attemptNo int, ipPool *ip_manager.IPPool) (*YoutubeStatsResponse, error) {
@@ -167,8 +212,13 @@ func ChannelInfo(channelID string, attemptNo int, ipPool *ip_manager.IPPool) (*Y
 		ipPool.SetThrottled(ip)
 		log.Warnf("we got blocked by youtube on IP %s, waiting %d hour(s) before attempt %d", ip, attemptNo+1, attemptNo+2)
 		time.Sleep(time.Duration(attemptNo) * time.Hour)
-		return ChannelInfo(channelID, attemptNo+1, nil)
+		return ChannelInfo(channelID, attemptNo+1, ipPool)
 	}
+	return parseChannelPage(pageBody)
+}
+
+// parseChannelPage decodes the ytInitialData embedded in the about page of a channel
+func parseChannelPage(pageBody string) (*YoutubeStatsResponse, error) {
 	dataStartIndex := strings.Index(pageBody, "window[\"ytInitialData\"] = ") + 26
 	if dataStartIndex == 25 {
 		dataStartIndex = strings.Index(pageBody, "var ytInitialData = ") + 20
@@ -181,7 +231,7 @@ func ChannelInfo(channelID string, attemptNo int, ipPool *ip_manager.IPPool) (*Y
 	}
 	data := pageBody[dataStartIndex:dataEndIndex]
 	var decodedResponse YoutubeStatsResponse
-	err = json.Unmarshal([]byte(data), &decodedResponse)
+	err := json.Unmarshal([]byte(data), &decodedResponse)
 	if err != nil {
 		return nil, errors.Err(err)
 	}
@@ -195,42 +245,117 @@ func ChannelInfo(channelID string, attemptNo int, ipPool *ip_manager.IPPool) (*Y
 	return &decodedResponse, nil
 }
 
-func getVideos(channelID string, videoIDs []string, stopChan stop.Chan, ipPool *ip_manager.IPPool) ([]*ytdl.YtdlVideo, error) {
-	config := sdk.GetAPIsConfigs()
-	var videos []*ytdl.YtdlVideo
-	for _, videoID := range videoIDs {
-		if len(videoID) < 5 {
+// getVideos fetches the metadata of the items in parallel, with as many workers as there are free IPs. Videos that
+// can't be fetched are marked as failed through the outbox, unless they aren't ready yet in which case they are
+// deferred. The videos are returned in the order of the items
+func getVideos(store sdk.JobStore, outbox *sdk.StatusOutbox, source sources.Source, channelID string, items []sources.Item, playlistMap map[string]int64, stopChan stop.Chan, pool *ip_manager.IPPool, deferred *sdk.DeferredVideos) ([]Video, error) {
+	videoIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		videoIDs = append(videoIDs, item.ID)
+	}
+	if len(videoIDs) == 0 {
+		return nil, nil
+	}
+	states, err := store.VideoStates(videoIDs)
+	if err != nil {
+		return nil, errors.Err(err)
+	}
+	toFetch := make([]sources.Item, 0, len(videoIDs))
+	for _, item := range items {
+		state, ok := states[item.ID]
+		if !ok {
 			continue
 		}
-		select {
-		case <-stopChan:
-			return videos, errors.Err("interrupted by user")
-		default:
-		}
-
-		state, err := config.VideoState(videoID)
-		if err != nil {
-			return nil, errors.Err(err)
-		}
 		if state == "published" {
 			log.Errorf("this should never happen anymore because we check this earlier!")
 			continue
 		}
-		video, err := downloader.GetVideoInformation(videoID, stopChan, ipPool)
-		if err != nil {
-			errSDK := config.MarkVideoStatus(shared.VideoStatus{
-				ChannelID:     channelID,
-				VideoID:       videoID,
-				Status:        "failed",
-				FailureReason: err.Error(),
-			})
-			logUtils.SendErrorToSlack(fmt.Sprintf("Skipping video (%s): %s", videoID, errors.FullTrace(err)))
-			if errSDK != nil {
-				return nil, errors.Err(errSDK)
+		toFetch = append(toFetch, item)
+	}
+
+	workers := 1
+	if pool != nil && pool.FreeIPs() > workers {
+		workers = pool.FreeIPs()
+	}
+	if workers > len(toFetch) {
+		workers = len(toFetch)
+	}
+	log.Infof("fetching the metadata of %d videos with %d workers", len(toFetch), workers)
+
+	results := make([]Video, len(toFetch))
+	jobs := make(chan int)
+	// failing to mark a video as failed stops the other workers
+	aborted := make(chan struct{})
+	var abortOnce sync.Once
+	var markErr error
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				item := toFetch[i]
+				video, err := source.FetchMetadata(item, playlistMap[item.ID])
+				if err == nil {
+					results[i] = video
+					continue
+				}
+				if shared.IsDeferred(err) {
+					now := time.Now()
+					notBefore, errDefer := deferred.Defer(channelID, item.ID, item.URL, err.Error(), shared.ReadyAt(err, now), now)
+					if errDefer != nil {
+						logUtils.SendErrorToSlack("could not defer video %s: %s", item.ID, errDefer.Error())
+					}
+					log.Infof("%s isn't ready yet, deferring it until %s: %s", item.ID, notBefore.Format(time.RFC3339), err.Error())
+					continue
+				}
+				errDefer := deferred.Remove(channelID, item.ID)
+				if errDefer != nil {
+					logUtils.SendErrorToSlack("could not remove video %s from the deferred videos: %s", item.ID, errDefer.Error())
+				}
+				errSDK := outbox.MarkVideoStatus(shared.VideoStatus{
+					ChannelID:     channelID,
+					VideoID:       item.ID,
+					Status:        "failed",
+					FailureReason: err.Error(),
+				})
+				logUtils.SendErrorToSlack(fmt.Sprintf("Skipping video (%s): %s", item.ID, errors.FullTrace(err)))
+				if errSDK != nil {
+					abortOnce.Do(func() {
+						markErr = errSDK
+						close(aborted)
+					})
+				}
 			}
-			continue
+		}()
+	}
+
+	interrupted := false
+dispatch:
+	for i := range toFetch {
+		select {
+		case <-stopChan:
+			interrupted = true
+			break dispatch
+		case <-aborted:
+			break dispatch
+		case jobs <- i:
 		}
-		videos = append(videos, video)
+	}
+	close(jobs)
+	wg.Wait()
+	if markErr != nil {
+		return nil, errors.Err(markErr)
+	}
+
+	videos := make([]Video, 0, len(results))
+	for _, video := range results {
+		if video != nil {
+			videos = append(videos, video)
+		}
+	}
+	if interrupted {
+		return videos, errors.Err(ip_manager.ErrInterruptedByUser)
 	}
 	return videos, nil
 }
@@ -1,10 +1,17 @@
 package ytapi
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/lbryio/lbry.go/v2/extras/errors"
 	"github.com/lbryio/lbry.go/v2/extras/stop"
+	"github.com/lbryio/ytsync/v5/configs"
 	"github.com/lbryio/ytsync/v5/ip_manager"
+	"github.com/lbryio/ytsync/v5/sdk"
+	"github.com/lbryio/ytsync/v5/shared"
+	"github.com/lbryio/ytsync/v5/sources"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,3 +22,160 @@ func TestChannelInfo(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, info)
 }
+
+type fakeFeedSource struct {
+	sources.Source
+	feed     []sources.Item
+	feedErr  error
+	listed   []sources.Item
+	listings int
+}
+
+func (f *fakeFeedSource) ListItems(maxItems int) ([]sources.Item, error) {
+	f.listings++
+	return f.listed, nil
+}
+
+func (f *fakeFeedSource) ListFeedItems() ([]sources.Item, error) {
+	return f.feed, f.feedErr
+}
+
+func TestListItems(t *testing.T) {
+	synced := map[string]sdk.SyncedVideo{"known": {VideoID: "known", Published: true}}
+	source := &fakeFeedSource{
+		feed:   []sources.Item{{ID: "new1"}, {ID: "new2"}, {ID: "known"}},
+		listed: []sources.Item{{ID: "new1"}, {ID: "new2"}, {ID: "known"}, {ID: "old"}},
+	}
+
+	items, fullScan, err := listItems(source, synced, "", 50, true)
+	assert.NoError(t, err)
+	assert.False(t, fullScan)
+	assert.Equal(t, source.feed, items)
+	assert.Equal(t, 0, source.listings)
+
+	items, fullScan, err = listItems(source, synced, "", 2, true)
+	assert.NoError(t, err)
+	assert.False(t, fullScan)
+	assert.Len(t, items, 2)
+
+	// the feed is ignored when a full scan is due
+	items, fullScan, err = listItems(source, synced, "", 50, false)
+	assert.NoError(t, err)
+	assert.True(t, fullScan)
+	assert.Equal(t, source.listed, items)
+
+	// a gap: none of the feed entries is known
+	source.feed = []sources.Item{{ID: "new1"}, {ID: "new2"}}
+	_, fullScan, err = listItems(source, synced, "", 50, true)
+	assert.NoError(t, err)
+	assert.True(t, fullScan)
+
+	// the last uploaded video recorded for the channel counts as known
+	_, fullScan, err = listItems(source, nil, "new2", 50, true)
+	assert.NoError(t, err)
+	assert.False(t, fullScan)
+
+	source.feedErr = errors.Err("feed unavailable")
+	_, fullScan, err = listItems(source, synced, "", 50, true)
+	assert.NoError(t, err)
+	assert.True(t, fullScan)
+	assert.Equal(t, 3, source.listings)
+}
+
+type fakeMetadataSource struct {
+	sources.Source
+	failing   map[string]bool
+	premieres map[string]bool
+	fetched   []string
+}
+
+func (f *fakeMetadataSource) FetchMetadata(item sources.Item, playlistPosition int64) (sources.Video, error) {
+	f.fetched = append(f.fetched, item.ID)
+	if f.failing[item.ID] {
+		return nil, errors.Err("ERROR: [youtube] %s: Private video", item.ID)
+	}
+	if f.premieres[item.ID] {
+		return nil, errors.Err("ERROR: [youtube] %s: Premieres in 3 hours", item.ID)
+	}
+	return sources.NewMockedVideo("", item.ID, "UCabc", nil, nil), nil
+}
+
+func TestGetVideos(t *testing.T) {
+	configs.Configuration = &configs.Configs{}
+	store, err := sdk.NewLocalStore(t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, store.MarkVideoStatus(shared.VideoStatus{ChannelID: "UCabc", VideoID: "published1", Status: sdk.VideoStatusPublished, ClaimID: "claim1", ClaimName: "published-1"}))
+	source := &fakeMetadataSource{failing: map[string]bool{"private1": true}, premieres: map[string]bool{"premiere1": true}}
+	items := []sources.Item{{ID: "video1"}, {ID: "private1"}, {ID: "published1"}, {ID: "v1"}, {ID: "video2"}, {ID: "premiere1", URL: "https://example.com/premiere1"}}
+	deferred, err := sdk.NewDeferredVideos(filepath.Join(t.TempDir(), "deferred.json"), time.Hour, 24*time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	outbox, err := sdk.NewStatusOutbox(filepath.Join(t.TempDir(), "outbox.jsonl"), store)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	videos, err := getVideos(store, outbox, source, "UCabc", items, map[string]int64{}, nil, nil, deferred)
+	assert.NoError(t, err)
+	// short IDs are only filtered out of youtube listings, other sites use them
+	if assert.Len(t, videos, 3) {
+		assert.Equal(t, "video1", videos[0].ID())
+		assert.Equal(t, "v1", videos[1].ID())
+		assert.Equal(t, "video2", videos[2].ID())
+	}
+	assert.ElementsMatch(t, []string{"video1", "private1", "v1", "video2", "premiere1"}, source.fetched)
+	// failures go through the outbox like the other status updates
+	if assert.Len(t, outbox.Pending("UCabc"), 1) {
+		assert.Equal(t, "private1", outbox.Pending("UCabc")[0].VideoID)
+	}
+	assert.NoError(t, outbox.Flush())
+	state, err := store.VideoState("private1")
+	assert.NoError(t, err)
+	assert.Equal(t, sdk.VideoStatusFailed, state)
+	// premieres are put aside instead of failing
+	state, err = store.VideoState("premiere1")
+	assert.NoError(t, err)
+	assert.NotEqual(t, sdk.VideoStatusFailed, state)
+	assert.True(t, deferred.IsDeferred("UCabc", "premiere1"))
+	assert.Empty(t, deferred.Due("UCabc", time.Now()))
+	due := deferred.Due("UCabc", time.Now().Add(3*time.Hour+time.Minute))
+	if assert.Len(t, due, 1) {
+		assert.Equal(t, "premiere1", due[0].VideoID)
+		assert.Equal(t, "https://example.com/premiere1", due[0].URL)
+	}
+
+	stopped := stop.New()
+	stopped.Stop()
+	source.fetched = nil
+	_, err = getVideos(store, outbox, source, "UCabc", items, map[string]int64{}, stopped.Ch(), nil, nil)
+	assert.True(t, errors.Is(err, ip_manager.ErrInterruptedByUser))
+	assert.Empty(t, source.fetched)
+}
+
+type fakeMockingSource struct {
+	sources.Source
+}
+
+func (f *fakeMockingSource) MockedVideo(videoID string) sources.Video {
+	return sources.NewMockedVideo("", videoID, "UCabc", nil, nil)
+}
+
+func TestUnlistedUpgrades(t *testing.T) {
+	synced := map[string]sdk.SyncedVideo{
+		"outdated": {VideoID: "outdated", Published: true, MetadataVersion: 1},
+		"listed":   {VideoID: "listed", Published: true, MetadataVersion: 1},
+		"upgraded": {VideoID: "upgraded", Published: true, MetadataVersion: 2},
+		"failed":   {VideoID: "failed", Published: false, MetadataVersion: 1},
+	}
+	listed := map[string]int64{"listed": 0}
+	videos := unlistedUpgrades(&fakeMockingSource{}, synced, listed, 2, true)
+	if assert.Len(t, videos, 1) {
+		assert.Equal(t, "outdated", videos[0].ID())
+	}
+	// feeds leave out most videos, which doesn't mean they aren't listed anymore
+	assert.Empty(t, unlistedUpgrades(&fakeMockingSource{}, synced, listed, 2, false))
+}